@@ -166,12 +166,44 @@ func priceFeederCmdHandler(cmd *cobra.Command, args []string) error {
 		deviations[deviation.Base] = threshold
 	}
 
+	// create the aggregation of the prices from config file
+	aggregation, err := oracle.NewAggregation(cfg.Aggregation, cfg.ProviderWeights)
+	if err != nil {
+		return err
+	}
+	aggregation.DepegGuards, err = oracle.NewDepegGuards(cfg.StablecoinGuards)
+	if err != nil {
+		return err
+	}
+	aggregation.AdaptiveDeviation, err = oracle.NewAdaptiveDeviation(cfg.Aggregation)
+	if err != nil {
+		return err
+	}
+
+	// create the policy guarding the voted prices from config file
+	votePolicy, err := oracle.NewVotePolicy(cfg)
+	if err != nil {
+		return err
+	}
+
 	// create a map with the endpoitns listed on the config file
 	endpoints := make(map[string]config.ProviderEndpoint, len(cfg.ProviderEndpoints))
 	for _, endpoint := range cfg.ProviderEndpoints {
 		endpoints[endpoint.Name] = endpoint
 	}
 
+	// route every provider without its own proxy through the global proxy
+	if len(cfg.Proxy.URL) > 0 {
+		for providerName := range config.SupportedProviders {
+			endpoint := endpoints[providerName]
+			if len(endpoint.Proxy) == 0 {
+				endpoint.Name = providerName
+				endpoint.Proxy = cfg.Proxy.URL
+				endpoints[providerName] = endpoint
+			}
+		}
+	}
+
 	// create new oracle instance
 	oracle := oracle.New(
 		logger,
@@ -179,6 +211,8 @@ func priceFeederCmdHandler(cmd *cobra.Command, args []string) error {
 		cfg.CurrencyPairs,
 		providerTimeout,
 		deviations,
+		aggregation,
+		votePolicy,
 		endpoints,
 		cfg.Healthchecks,
 	)
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// redacted replaces the secrets of the credentials whenever they are printed.
+const redacted = "[REDACTED]"
+
+type (
+	// ProviderCredentials defines where the API credentials of a provider are
+	// loaded from, either environment variables or a file. The credentials
+	// themselves are never stored in the config file.
+	ProviderCredentials struct {
+		// KeyEnv is the environment variable holding the API key, ex.
+		// "COINBASE_API_KEY"
+		KeyEnv string `toml:"key_env"`
+
+		// SecretEnv is the environment variable holding the API secret
+		SecretEnv string `toml:"secret_env"`
+
+		// PassphraseEnv is the environment variable holding the API passphrase,
+		// only required by some providers
+		PassphraseEnv string `toml:"passphrase_env"`
+
+		// File is a toml file with the key, secret and passphrase fields
+		File string `toml:"file"`
+	}
+
+	// Credentials defines the API credentials of a provider. They are redacted
+	// when printed, logged or marshaled.
+	Credentials struct {
+		Key        string `toml:"key"`
+		Secret     string `toml:"secret"`
+		Passphrase string `toml:"passphrase"`
+	}
+)
+
+// IsSet returns true if the credentials are loaded from any source.
+func (pc ProviderCredentials) IsSet() bool {
+	return len(pc.KeyEnv) > 0 || len(pc.SecretEnv) > 0 || len(pc.PassphraseEnv) > 0 || len(pc.File) > 0
+}
+
+// Validate returns an error if the credentials sources are incomplete or
+// ambiguous.
+func (pc ProviderCredentials) Validate() error {
+	if len(pc.File) > 0 {
+		if len(pc.KeyEnv) > 0 || len(pc.SecretEnv) > 0 || len(pc.PassphraseEnv) > 0 {
+			return errors.New("credentials must be loaded from either a file or environment variables")
+		}
+		return nil
+	}
+
+	if len(pc.KeyEnv) == 0 || len(pc.SecretEnv) == 0 {
+		return errors.New("credentials must set both key_env and secret_env")
+	}
+
+	return nil
+}
+
+// Load reads the credentials from their source. It returns empty credentials
+// if none are configured.
+func (pc ProviderCredentials) Load() (Credentials, error) {
+	if !pc.IsSet() {
+		return Credentials{}, nil
+	}
+
+	if err := pc.Validate(); err != nil {
+		return Credentials{}, err
+	}
+
+	var credentials Credentials
+	if len(pc.File) > 0 {
+		if _, err := toml.DecodeFile(pc.File, &credentials); err != nil {
+			// the decoding error may quote the content of the file
+			return Credentials{}, fmt.Errorf("failed to decode credentials file %s", pc.File)
+		}
+	} else {
+		credentials = Credentials{
+			Key:        os.Getenv(pc.KeyEnv),
+			Secret:     os.Getenv(pc.SecretEnv),
+			Passphrase: os.Getenv(pc.PassphraseEnv),
+		}
+	}
+
+	if len(strings.TrimSpace(credentials.Key)) == 0 || len(strings.TrimSpace(credentials.Secret)) == 0 {
+		return Credentials{}, errors.New("credentials key and secret must not be empty")
+	}
+
+	return credentials, nil
+}
+
+// IsEmpty returns true if no credentials are set.
+func (c Credentials) IsEmpty() bool {
+	return len(c.Key) == 0 && len(c.Secret) == 0 && len(c.Passphrase) == 0
+}
+
+// String implements fmt.Stringer, never exposing the credentials.
+func (c Credentials) String() string {
+	return redacted
+}
+
+// GoString implements fmt.GoStringer, never exposing the credentials.
+func (c Credentials) GoString() string {
+	return redacted
+}
+
+// MarshalJSON implements json.Marshaler, never exposing the credentials.
+func (c Credentials) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
@@ -0,0 +1,30 @@
+package oracle
+
+import (
+	"github.com/hashicorp/go-metrics"
+	"github.com/rs/zerolog"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+// Abstentions holds the reason of every base whose price the feeder abstains
+// from voting, so its missing price does not fail the prices of the others.
+type Abstentions map[string]string
+
+// add abstains from voting the price of the base, keeping its first reason.
+func (a Abstentions) add(base, reason string) {
+	if _, ok := a[base]; !ok {
+		a[base] = reason
+	}
+}
+
+// report logs and counts the abstention from voting every base.
+func (a Abstentions) report(logger zerolog.Logger) {
+	for base, reason := range a {
+		telemetry.IncrCounterWithLabels([]string{"vote", "abstain"}, 1, []metrics.Label{
+			{Name: "base", Value: base},
+			{Name: "reason", Value: reason},
+		})
+		logger.Warn().Str("base", base).Str("reason", reason).Msg("abstaining from voting the price")
+	}
+}
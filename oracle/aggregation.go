@@ -0,0 +1,458 @@
+package oracle
+
+import (
+	"fmt"
+	"sort"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+)
+
+// defaultTrimFraction is the fraction of the lowest and of the highest
+// provider prices dropped by the trimmed mean unless the asset sets its own.
+var defaultTrimFraction = math.LegacyMustNewDecFromStr("0.2")
+
+var (
+	_ AggregationStrategy = TVWAPStrategy{}
+	_ AggregationStrategy = VWAPStrategy{}
+	_ AggregationStrategy = MedianStrategy{}
+	_ AggregationStrategy = WeightedMedianStrategy{}
+	_ AggregationStrategy = TrimmedMeanStrategy{}
+)
+
+type (
+	// AggregationStrategy defines how the prices reported by the providers are
+	// aggregated into a single price by base. The volumes of the providers are
+	// multiplied by their trust weights and capped to the max share.
+	AggregationStrategy interface {
+		// AggregateCandles aggregates the candles of the providers.
+		AggregateCandles(provider.AggregatedProviderCandles, ProviderWeights) (map[string]math.LegacyDec, error)
+		// AggregateTickers aggregates the ticker prices of the providers.
+		AggregateTickers(provider.AggregatedProviderPrices, ProviderWeights) (map[string]math.LegacyDec, error)
+	}
+
+	// Aggregation defines how the prices of the providers are aggregated for
+	// every base.
+	Aggregation struct {
+		// Weights are the trust weights of the providers
+		Weights ProviderWeights
+		// Strategy is the strategy of the bases without their own strategy,
+		// the TVWAPStrategy if nil
+		Strategy AggregationStrategy
+		// Strategies are the strategies by base
+		Strategies map[string]AggregationStrategy
+		// Filter is the deviation filter of the bases without their own
+		// filter, the StdDevFilter if nil
+		Filter DeviationFilter
+		// Filters are the deviation filters by base
+		Filters map[string]DeviationFilter
+		// TwoSourceFilter is the filter of the bases priced by only two
+		// providers without their own, both prices are kept if nil
+		TwoSourceFilter DeviationFilter
+		// TwoSourceFilters are the filters by base when priced by only two
+		// providers, both prices are kept if nil
+		TwoSourceFilters map[string]DeviationFilter
+		// DepegGuards are the depeg guards of the stablecoins converting the
+		// prices to USD
+		DepegGuards DepegGuards
+		// Quorum is the minimum number of providers contributing to the
+		// price of every base after filtering
+		Quorum Quorum
+		// AdaptiveDeviation derives the deviation thresholds of the bases
+		// from their realized volatility, the static thresholds are used if
+		// nil
+		AdaptiveDeviation *AdaptiveDeviation
+		// Volatilities are the realized volatilities of the bases, computed
+		// from their candles on every tick with the adaptive deviation
+		Volatilities map[string]math.LegacyDec
+		// Liquidity excludes the provider routes of the bases below their
+		// minimum 24h USD volume
+		Liquidity LiquidityFilter
+	}
+
+	// TVWAPStrategy aggregates the candles by their TVWAP, and the ticker
+	// prices by their VWAP.
+	TVWAPStrategy struct{}
+
+	// VWAPStrategy aggregates the candles and the ticker prices by their VWAP.
+	VWAPStrategy struct{}
+
+	// MedianStrategy aggregates the prices of the providers by their median,
+	// where the price of a provider is the TVWAP of its candles or its ticker
+	// price.
+	MedianStrategy struct{}
+
+	// WeightedMedianStrategy aggregates the prices of the providers by their
+	// median weighted by the volume of the providers.
+	WeightedMedianStrategy struct{}
+
+	// TrimmedMeanStrategy aggregates the prices of the providers by their mean
+	// after dropping the TrimFraction of the lowest and of the highest prices.
+	TrimmedMeanStrategy struct {
+		TrimFraction math.LegacyDec
+	}
+)
+
+// NewAggregation creates the Aggregation of the config.
+func NewAggregation(
+	aggregationConfig config.Aggregation,
+	providerWeights []config.ProviderWeight,
+) (Aggregation, error) {
+	aggregation := Aggregation{
+		Weights: ProviderWeights{
+			Providers: make(map[string]math.LegacyDec),
+			Assets:    make(map[string]map[string]math.LegacyDec),
+		},
+		Strategies:       make(map[string]AggregationStrategy, len(aggregationConfig.Assets)),
+		Filters:          make(map[string]DeviationFilter, len(aggregationConfig.Assets)),
+		TwoSourceFilters: make(map[string]DeviationFilter, len(aggregationConfig.Assets)),
+		Quorum:           NewQuorum(aggregationConfig),
+	}
+
+	// create the trust weights by provider and base
+	for _, providerWeight := range providerWeights {
+		weight, err := math.LegacyNewDecFromStr(providerWeight.Weight)
+		if err != nil {
+			return Aggregation{}, err
+		}
+
+		if len(providerWeight.Base) == 0 {
+			aggregation.Weights.Providers[providerWeight.Provider] = weight
+			continue
+		}
+		if _, ok := aggregation.Weights.Assets[providerWeight.Provider]; !ok {
+			aggregation.Weights.Assets[providerWeight.Provider] = make(map[string]math.LegacyDec)
+		}
+		aggregation.Weights.Assets[providerWeight.Provider][providerWeight.Base] = weight
+	}
+	if len(aggregationConfig.MaxProviderShare) > 0 {
+		maxShare, err := math.LegacyNewDecFromStr(aggregationConfig.MaxProviderShare)
+		if err != nil {
+			return Aggregation{}, err
+		}
+		aggregation.Weights.MaxShare = maxShare
+	}
+
+	// create the strategies by base
+	strategy, err := NewAggregationStrategy(aggregationConfig.Strategy, "")
+	if err != nil {
+		return Aggregation{}, err
+	}
+	aggregation.Strategy = strategy
+
+	// create the deviation filters by base
+	aggregation.Filter, err = NewDeviationFilter(aggregationConfig.Filter, "", "")
+	if err != nil {
+		return Aggregation{}, err
+	}
+	aggregation.TwoSourceFilter, err = NewTwoSourceFilter(aggregationConfig.TwoSourcePolicy, "")
+	if err != nil {
+		return Aggregation{}, err
+	}
+
+	// create the minimum volumes by base
+	aggregation.Liquidity, err = NewLiquidityFilter(aggregationConfig)
+	if err != nil {
+		return Aggregation{}, err
+	}
+
+	for _, assetAggregation := range aggregationConfig.Assets {
+		strategyName := assetAggregation.Strategy
+		if len(strategyName) == 0 {
+			strategyName = aggregationConfig.Strategy
+		}
+
+		strategy, err := NewAggregationStrategy(strategyName, assetAggregation.TrimFraction)
+		if err != nil {
+			return Aggregation{}, err
+		}
+		aggregation.Strategies[assetAggregation.Base] = strategy
+
+		filterName := assetAggregation.Filter
+		if len(filterName) == 0 {
+			filterName = aggregationConfig.Filter
+		}
+		filter, err := NewDeviationFilter(filterName, assetAggregation.MADThreshold, assetAggregation.Band)
+		if err != nil {
+			return Aggregation{}, err
+		}
+		aggregation.Filters[assetAggregation.Base] = filter
+
+		twoSourcePolicy := assetAggregation.TwoSourcePolicy
+		if len(twoSourcePolicy) == 0 {
+			twoSourcePolicy = aggregationConfig.TwoSourcePolicy
+		}
+		twoSourceFilter, err := NewTwoSourceFilter(twoSourcePolicy, assetAggregation.Band)
+		if err != nil {
+			return Aggregation{}, err
+		}
+		aggregation.TwoSourceFilters[assetAggregation.Base] = twoSourceFilter
+	}
+
+	return aggregation, nil
+}
+
+// NewAggregationStrategy returns the aggregation strategy by its name, the
+// TVWAPStrategy if empty.
+func NewAggregationStrategy(name, trimFraction string) (AggregationStrategy, error) {
+	switch name {
+	case "", config.AggregationTVWAP:
+		return TVWAPStrategy{}, nil
+
+	case config.AggregationVWAP:
+		return VWAPStrategy{}, nil
+
+	case config.AggregationMedian:
+		return MedianStrategy{}, nil
+
+	case config.AggregationWeightedMedian:
+		return WeightedMedianStrategy{}, nil
+
+	case config.AggregationTrimmedMean:
+		strategy := TrimmedMeanStrategy{TrimFraction: defaultTrimFraction}
+		if len(trimFraction) > 0 {
+			var err error
+			strategy.TrimFraction, err = math.LegacyNewDecFromStr(trimFraction)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return strategy, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported aggregation strategy: %s", name)
+	}
+}
+
+// strategy returns the aggregation strategy of the base.
+func (a Aggregation) strategy(base string) AggregationStrategy {
+	if strategy, ok := a.Strategies[base]; ok {
+		return strategy
+	}
+	if a.Strategy != nil {
+		return a.Strategy
+	}
+	return TVWAPStrategy{}
+}
+
+// AggregateCandles aggregates the candles of every base using its strategy.
+func (a Aggregation) AggregateCandles(
+	candles provider.AggregatedProviderCandles,
+) (map[string]math.LegacyDec, error) {
+	// split the candles by the strategy of their base
+	strategyCandles := make(map[AggregationStrategy]provider.AggregatedProviderCandles)
+	for providerName, providerCandles := range candles {
+		for base, cp := range providerCandles {
+			strategy := a.strategy(base)
+			if _, ok := strategyCandles[strategy]; !ok {
+				strategyCandles[strategy] = make(provider.AggregatedProviderCandles)
+			}
+			if _, ok := strategyCandles[strategy][providerName]; !ok {
+				strategyCandles[strategy][providerName] = make(map[string][]provider.CandlePrice)
+			}
+			strategyCandles[strategy][providerName][base] = cp
+		}
+	}
+
+	prices := make(map[string]math.LegacyDec)
+	for strategy, candles := range strategyCandles {
+		strategyPrices, err := strategy.AggregateCandles(candles, a.Weights)
+		if err != nil {
+			return nil, err
+		}
+		for base, price := range strategyPrices {
+			prices[base] = price
+		}
+	}
+
+	return prices, nil
+}
+
+// AggregateTickers aggregates the ticker prices of every base using its
+// strategy.
+func (a Aggregation) AggregateTickers(
+	tickers provider.AggregatedProviderPrices,
+) (map[string]math.LegacyDec, error) {
+	// split the tickers by the strategy of their base
+	strategyTickers := make(map[AggregationStrategy]provider.AggregatedProviderPrices)
+	for providerName, providerTickers := range tickers {
+		for base, tp := range providerTickers {
+			strategy := a.strategy(base)
+			if _, ok := strategyTickers[strategy]; !ok {
+				strategyTickers[strategy] = make(provider.AggregatedProviderPrices)
+			}
+			if _, ok := strategyTickers[strategy][providerName]; !ok {
+				strategyTickers[strategy][providerName] = make(map[string]provider.TickerPrice)
+			}
+			strategyTickers[strategy][providerName][base] = tp
+		}
+	}
+
+	prices := make(map[string]math.LegacyDec)
+	for strategy, tickers := range strategyTickers {
+		strategyPrices, err := strategy.AggregateTickers(tickers, a.Weights)
+		if err != nil {
+			return nil, err
+		}
+		for base, price := range strategyPrices {
+			prices[base] = price
+		}
+	}
+
+	return prices, nil
+}
+
+// AggregateCandles computes the TVWAP of the candles.
+func (TVWAPStrategy) AggregateCandles(
+	candles provider.AggregatedProviderCandles,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return ComputeTVWAP(candles, weights)
+}
+
+// AggregateTickers computes the VWAP of the ticker prices.
+func (TVWAPStrategy) AggregateTickers(
+	tickers provider.AggregatedProviderPrices,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return ComputeVWAP(tickers, weights)
+}
+
+// AggregateCandles computes the VWAP of the candles within the TVWAP period,
+// without decreasing their volume by their age.
+func (VWAPStrategy) AggregateCandles(
+	candles provider.AggregatedProviderCandles,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return candleWeightedPrices(candles, false).vwap(weights), nil
+}
+
+// AggregateTickers computes the VWAP of the ticker prices.
+func (VWAPStrategy) AggregateTickers(
+	tickers provider.AggregatedProviderPrices,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return ComputeVWAP(tickers, weights)
+}
+
+// AggregateCandles computes the median of the TVWAP of the providers.
+func (s MedianStrategy) AggregateCandles(
+	candles provider.AggregatedProviderCandles,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return aggregateProviderPrices(candleWeightedPrices(candles, true), weights, s.aggregate), nil
+}
+
+// AggregateTickers computes the median of the ticker prices.
+func (s MedianStrategy) AggregateTickers(
+	tickers provider.AggregatedProviderPrices,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return aggregateProviderPrices(tickerWeightedPrices(tickers), weights, s.aggregate), nil
+}
+
+// aggregate returns the median of the sorted provider prices, the mean of the
+// two middle prices if their amount is even.
+func (MedianStrategy) aggregate(prices []ProviderPrice) math.LegacyDec {
+	middle := len(prices) / 2
+	if len(prices)%2 == 0 {
+		return prices[middle-1].Price.Add(prices[middle].Price).QuoInt64(2)
+	}
+	return prices[middle].Price
+}
+
+// AggregateCandles computes the volume weighted median of the TVWAP of the
+// providers.
+func (s WeightedMedianStrategy) AggregateCandles(
+	candles provider.AggregatedProviderCandles,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return aggregateProviderPrices(candleWeightedPrices(candles, true), weights, s.aggregate), nil
+}
+
+// AggregateTickers computes the volume weighted median of the ticker prices.
+func (s WeightedMedianStrategy) AggregateTickers(
+	tickers provider.AggregatedProviderPrices,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return aggregateProviderPrices(tickerWeightedPrices(tickers), weights, s.aggregate), nil
+}
+
+// aggregate returns the first sorted provider price where the cumulative
+// volume reaches half of the total volume, the mean with the next price if
+// the half is reached exactly.
+func (WeightedMedianStrategy) aggregate(prices []ProviderPrice) math.LegacyDec {
+	totalVolume := math.LegacyZeroDec()
+	for _, p := range prices {
+		totalVolume = totalVolume.Add(p.Volume)
+	}
+	halfVolume := totalVolume.QuoInt64(2)
+
+	cumulativeVolume := math.LegacyZeroDec()
+	for i, p := range prices {
+		cumulativeVolume = cumulativeVolume.Add(p.Volume)
+		if cumulativeVolume.Equal(halfVolume) && i+1 < len(prices) {
+			return p.Price.Add(prices[i+1].Price).QuoInt64(2)
+		}
+		if cumulativeVolume.GT(halfVolume) {
+			return p.Price
+		}
+	}
+
+	return prices[len(prices)-1].Price
+}
+
+// AggregateCandles computes the trimmed mean of the TVWAP of the providers.
+func (s TrimmedMeanStrategy) AggregateCandles(
+	candles provider.AggregatedProviderCandles,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return aggregateProviderPrices(candleWeightedPrices(candles, true), weights, s.aggregate), nil
+}
+
+// AggregateTickers computes the trimmed mean of the ticker prices.
+func (s TrimmedMeanStrategy) AggregateTickers(
+	tickers provider.AggregatedProviderPrices,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return aggregateProviderPrices(tickerWeightedPrices(tickers), weights, s.aggregate), nil
+}
+
+// aggregate returns the mean of the sorted provider prices without the
+// TrimFraction of the lowest and of the highest prices. At least a price is
+// always kept.
+func (s TrimmedMeanStrategy) aggregate(prices []ProviderPrice) math.LegacyDec {
+	trimmed := s.TrimFraction.MulInt64(int64(len(prices))).TruncateInt64()
+	if 2*trimmed >= int64(len(prices)) {
+		trimmed = int64(len(prices)-1) / 2
+	}
+
+	kept := prices[trimmed : int64(len(prices))-trimmed]
+	sum := math.LegacyZeroDec()
+	for _, p := range kept {
+		sum = sum.Add(p.Price)
+	}
+
+	return sum.QuoInt64(int64(len(kept)))
+}
+
+// aggregateProviderPrices aggregates the prices of the providers of every base,
+// sorted from the lowest to the highest price, using the aggregate function.
+func aggregateProviderPrices(
+	wp weightedPrices,
+	weights ProviderWeights,
+	aggregate func([]ProviderPrice) math.LegacyDec,
+) map[string]math.LegacyDec {
+	prices := make(map[string]math.LegacyDec)
+
+	for base, providerPrices := range wp.providerPrices(weights) {
+		sort.SliceStable(providerPrices, func(i, j int) bool {
+			return providerPrices[i].Price.LT(providerPrices[j].Price)
+		})
+		prices[base] = aggregate(providerPrices)
+	}
+
+	return prices
+}
@@ -0,0 +1,269 @@
+package oracle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+)
+
+func TestAggregationStrategies_Tickers(t *testing.T) {
+	tickerPrice := func(price, volume string) provider.TickerPrice {
+		return provider.TickerPrice{
+			Price:  math.LegacyMustNewDecFromStr(price),
+			Volume: math.LegacyMustNewDecFromStr(volume),
+		}
+	}
+
+	// a single bad print with a large volume
+	badPrint := provider.AggregatedProviderPrices{
+		config.ProviderBinance: {"ATOM": tickerPrice("10", "100")},
+		config.ProviderKraken:  {"ATOM": tickerPrice("11", "100")},
+		config.ProviderOkx:     {"ATOM": tickerPrice("12", "100")},
+		config.ProviderHuobi:   {"ATOM": tickerPrice("13", "100")},
+		config.ProviderGate:    {"ATOM": tickerPrice("100", "1000")},
+	}
+
+	testCases := map[string]struct {
+		strategy AggregationStrategy
+		tickers  provider.AggregatedProviderPrices
+		weights  ProviderWeights
+		expected map[string]math.LegacyDec
+	}{
+		"tvwap": {
+			strategy: TVWAPStrategy{},
+			tickers:  badPrint,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("74.714285714285714286")},
+		},
+		"vwap": {
+			strategy: VWAPStrategy{},
+			tickers:  badPrint,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("74.714285714285714286")},
+		},
+		"median odd": {
+			strategy: MedianStrategy{},
+			tickers:  badPrint,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(12)},
+		},
+		"median even": {
+			strategy: MedianStrategy{},
+			tickers: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "100")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("11", "100")},
+				config.ProviderOkx:     {"ATOM": tickerPrice("12", "100")},
+				config.ProviderHuobi:   {"ATOM": tickerPrice("13", "100")},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("11.5")},
+		},
+		"median skips zero weights": {
+			strategy: MedianStrategy{},
+			tickers:  badPrint,
+			weights: ProviderWeights{
+				Providers: map[string]math.LegacyDec{config.ProviderBinance: math.LegacyZeroDec()},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("12.5")},
+		},
+		"weighted median": {
+			strategy: WeightedMedianStrategy{},
+			tickers:  badPrint,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(100)},
+		},
+		"weighted median with max share": {
+			strategy: WeightedMedianStrategy{},
+			tickers:  badPrint,
+			weights:  ProviderWeights{MaxShare: math.LegacyMustNewDecFromStr("0.2")},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(12)},
+		},
+		"weighted median at half of the volume": {
+			strategy: WeightedMedianStrategy{},
+			tickers: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "100")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("20", "100")},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(15)},
+		},
+		"trimmed mean": {
+			strategy: TrimmedMeanStrategy{TrimFraction: math.LegacyMustNewDecFromStr("0.2")},
+			tickers:  badPrint,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(12)},
+		},
+		"trimmed mean without trim": {
+			strategy: TrimmedMeanStrategy{TrimFraction: math.LegacyZeroDec()},
+			tickers:  badPrint,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("29.2")},
+		},
+		"trimmed mean keeps a price": {
+			strategy: TrimmedMeanStrategy{TrimFraction: math.LegacyMustNewDecFromStr("0.49")},
+			tickers: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "100")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("20", "100")},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(15)},
+		},
+		"empty tickers": {
+			strategy: MedianStrategy{},
+			tickers:  provider.AggregatedProviderPrices{},
+			expected: map[string]math.LegacyDec{},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			prices, err := tc.strategy.AggregateTickers(tc.tickers, tc.weights)
+			require.NoError(t, err)
+			require.Len(t, prices, len(tc.expected))
+
+			for k, v := range tc.expected {
+				require.Equalf(t, v, prices[k], "unexpected price for %s", k)
+			}
+		})
+	}
+}
+
+func TestAggregationStrategies_Candles(t *testing.T) {
+	now := provider.PastUnixTime(0)
+	candlePrice := func(price, volume string, timeStamp int64) provider.CandlePrice {
+		return provider.CandlePrice{
+			Price:     math.LegacyMustNewDecFromStr(price),
+			Volume:    math.LegacyMustNewDecFromStr(volume),
+			TimeStamp: timeStamp,
+		}
+	}
+
+	// the providers price are 17.5, 16 and 100 with the volumes 400, 100 and
+	// 10000
+	candles := provider.AggregatedProviderCandles{
+		config.ProviderBinance: {"ATOM": {candlePrice("10", "100", now), candlePrice("20", "300", now)}},
+		config.ProviderKraken:  {"ATOM": {candlePrice("16", "100", now)}},
+		config.ProviderOkx:     {"ATOM": {candlePrice("100", "10000", now)}},
+	}
+
+	testCases := map[string]struct {
+		strategy AggregationStrategy
+		candles  provider.AggregatedProviderCandles
+		expected map[string]math.LegacyDec
+	}{
+		"tvwap": {
+			strategy: TVWAPStrategy{},
+			candles:  candles,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("96.057142857142857143")},
+		},
+		"vwap ignores the age of the candles": {
+			strategy: VWAPStrategy{},
+			candles: provider.AggregatedProviderCandles{
+				config.ProviderBinance: {"ATOM": {candlePrice("10", "100", now-60000), candlePrice("20", "100", now)}},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(15)},
+		},
+		"vwap skips stale candles": {
+			strategy: VWAPStrategy{},
+			candles: provider.AggregatedProviderCandles{
+				config.ProviderBinance: {"ATOM": {candlePrice("10", "100", now-600000), candlePrice("20", "100", now)}},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(20)},
+		},
+		"median": {
+			strategy: MedianStrategy{},
+			candles:  candles,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("17.5")},
+		},
+		"weighted median": {
+			strategy: WeightedMedianStrategy{},
+			candles:  candles,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(100)},
+		},
+		"trimmed mean": {
+			strategy: TrimmedMeanStrategy{TrimFraction: math.LegacyMustNewDecFromStr("0.2")},
+			candles:  candles,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("44.5")},
+		},
+		"trimmed mean dropping the extremes": {
+			strategy: TrimmedMeanStrategy{TrimFraction: math.LegacyMustNewDecFromStr("0.4")},
+			candles:  candles,
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("17.5")},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			mockNow = now
+			defer func() { mockNow = 0 }()
+
+			prices, err := tc.strategy.AggregateCandles(tc.candles, ProviderWeights{})
+			require.NoError(t, err)
+			require.Len(t, prices, len(tc.expected))
+
+			for k, v := range tc.expected {
+				require.Equalf(t, v, prices[k], "unexpected price for %s", k)
+			}
+		})
+	}
+}
+
+func TestAggregation_AggregateTickers(t *testing.T) {
+	tickers := provider.AggregatedProviderPrices{
+		config.ProviderBinance: {
+			"ATOM": {Price: math.LegacyNewDec(10), Volume: math.LegacyNewDec(100)},
+			"KII":  {Price: math.LegacyNewDec(10), Volume: math.LegacyNewDec(100)},
+		},
+		config.ProviderKraken: {
+			"ATOM": {Price: math.LegacyNewDec(11), Volume: math.LegacyNewDec(100)},
+			"KII":  {Price: math.LegacyNewDec(11), Volume: math.LegacyNewDec(100)},
+		},
+		config.ProviderOkx: {
+			"ATOM": {Price: math.LegacyNewDec(30), Volume: math.LegacyNewDec(1000)},
+			"KII":  {Price: math.LegacyNewDec(30), Volume: math.LegacyNewDec(1000)},
+		},
+	}
+
+	aggregation := Aggregation{
+		Strategies: map[string]AggregationStrategy{"ATOM": MedianStrategy{}},
+	}
+
+	prices, err := aggregation.AggregateTickers(tickers)
+	require.NoError(t, err)
+	require.Equal(t, map[string]math.LegacyDec{
+		"ATOM": math.LegacyNewDec(11),
+		"KII":  math.LegacyMustNewDecFromStr("26.75"),
+	}, prices)
+}
+
+func TestNewAggregation(t *testing.T) {
+	aggregation, err := NewAggregation(
+		config.Aggregation{
+			MaxProviderShare: "0.5",
+			Strategy:         config.AggregationMedian,
+			Assets: []config.AssetAggregation{
+				{Base: "ATOM", Strategy: config.AggregationTrimmedMean, TrimFraction: "0.1"},
+				{Base: "KII", Strategy: config.AggregationWeightedMedian},
+				{Base: "USDT"},
+			},
+		},
+		[]config.ProviderWeight{
+			{Provider: config.ProviderMexc, Weight: "0.5"},
+			{Provider: config.ProviderMexc, Base: "ATOM", Weight: "0"},
+		},
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, MedianStrategy{}, aggregation.strategy("BTC"))
+	require.Equal(t, TrimmedMeanStrategy{TrimFraction: math.LegacyMustNewDecFromStr("0.1")}, aggregation.strategy("ATOM"))
+	require.Equal(t, WeightedMedianStrategy{}, aggregation.strategy("KII"))
+	require.Equal(t, MedianStrategy{}, aggregation.strategy("USDT"))
+	require.Equal(t, math.LegacyMustNewDecFromStr("0.5"), aggregation.Weights.MaxShare)
+	require.Equal(t, math.LegacyZeroDec(), aggregation.Weights.Weight(config.ProviderMexc, "ATOM"))
+	require.Equal(t, math.LegacyMustNewDecFromStr("0.5"), aggregation.Weights.Weight(config.ProviderMexc, "KII"))
+
+	require.Equal(t, TVWAPStrategy{}, Aggregation{}.strategy("BTC"))
+
+	_, err = NewAggregationStrategy("mean", "")
+	require.ErrorContains(t, err, "unsupported aggregation strategy: mean")
+}
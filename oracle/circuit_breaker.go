@@ -0,0 +1,312 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-metrics"
+	oracletypes "github.com/kiichain/kiichain/v3/x/oracle/types"
+	"github.com/rs/zerolog"
+
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+// References of the prices checked by the circuit breaker
+const (
+	referenceLastVote = "last_vote"
+	referenceOnChain  = "on_chain"
+)
+
+type (
+	// CircuitBreakerRule defines the MaxChange fraction the price of an asset
+	// can change from its references, and the Action when it changes more.
+	// The "confirm" action accepts the change after ConfirmPeriods consecutive
+	// vote periods, and the "hold" action accepts the new level after
+	// MaxHoldPeriods consecutive vote periods, holding without a limit if
+	// zero. The rule is disabled if its MaxChange is nil.
+	CircuitBreakerRule struct {
+		MaxChange      math.LegacyDec
+		Action         string
+		ConfirmPeriods int
+		MaxHoldPeriods int
+	}
+
+	// CircuitBreaker guards the prices of the assets against sudden changes
+	// from their last voted price and their on-chain exchange rate.
+	CircuitBreaker struct {
+		// Rule is the rule of the assets without their own rule
+		Rule CircuitBreakerRule
+		// Rules are the rules by base
+		Rules map[string]CircuitBreakerRule
+
+		breaches map[string]circuitBreach // ongoing breach of the max change by base
+	}
+
+	// circuitBreach defines the consecutive vote periods the price of an asset
+	// breaches its max change, up to the last vote period and price breaching
+	// it.
+	circuitBreach struct {
+		periods    int
+		votePeriod int64
+		price      math.LegacyDec
+	}
+)
+
+// NewCircuitBreaker returns the circuit breaker of the config.
+func NewCircuitBreaker(circuitBreakerConfig config.CircuitBreaker) (*CircuitBreaker, error) {
+	rule, err := newCircuitBreakerRule(
+		circuitBreakerConfig.MaxChange,
+		circuitBreakerConfig.Action,
+		circuitBreakerConfig.ConfirmPeriods,
+		circuitBreakerConfig.MaxHoldPeriods,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	circuitBreaker := &CircuitBreaker{
+		Rule:     rule,
+		Rules:    make(map[string]CircuitBreakerRule, len(circuitBreakerConfig.Assets)),
+		breaches: make(map[string]circuitBreach),
+	}
+	for _, asset := range circuitBreakerConfig.Assets {
+		circuitBreaker.Rules[asset.Base], err = newCircuitBreakerRule(
+			asset.MaxChange,
+			asset.Action,
+			asset.ConfirmPeriods,
+			asset.MaxHoldPeriods,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return circuitBreaker, nil
+}
+
+// newCircuitBreakerRule returns the rule of the settings, disabled if the max
+// change is empty.
+func newCircuitBreakerRule(maxChange, action string, confirmPeriods, maxHoldPeriods int) (CircuitBreakerRule, error) {
+	rule := CircuitBreakerRule{Action: action, ConfirmPeriods: confirmPeriods, MaxHoldPeriods: maxHoldPeriods}
+	if len(maxChange) == 0 {
+		return rule, nil
+	}
+
+	var err error
+	rule.MaxChange, err = math.LegacyNewDecFromStr(maxChange)
+	if err != nil {
+		return CircuitBreakerRule{}, err
+	}
+	if _, ok := config.SupportedCircuitBreakerActions[action]; !ok {
+		return CircuitBreakerRule{}, fmt.Errorf("unsupported circuit breaker action: %s", action)
+	}
+	return rule, nil
+}
+
+// Enabled returns true if the rule of any asset is enabled.
+func (cb *CircuitBreaker) Enabled() bool {
+	if !cb.Rule.MaxChange.IsNil() {
+		return true
+	}
+	for _, rule := range cb.Rules {
+		if !rule.MaxChange.IsNil() {
+			return true
+		}
+	}
+	return false
+}
+
+// rule returns the rule of the base, false if disabled.
+func (cb *CircuitBreaker) rule(base string) (CircuitBreakerRule, bool) {
+	rule, ok := cb.Rules[base]
+	if !ok {
+		rule = cb.Rule
+	}
+	return rule, !rule.MaxChange.IsNil()
+}
+
+// Apply returns the prices guarded by the rule of every asset, given the
+// current vote period, the last voted prices and the on-chain exchange rates
+// by base, along with the bases whose last voted price is held. A price
+// changing more than the max change from any reference trips the circuit
+// breaker, which holds the last voted price until the max hold periods,
+// abstains from voting the asset, or holds the last voted price until the
+// change is confirmed by consecutive vote periods whose breaching prices are
+// within the max change of each other. The breaches are counted once by vote
+// period, however many ticks breach within it. Without a last voted price to
+// hold, the asset is abstained from.
+func (cb *CircuitBreaker) Apply(
+	logger zerolog.Logger,
+	votePeriod int64,
+	prices map[string]math.LegacyDec,
+	lastVotedPrices map[string]math.LegacyDec,
+	onChainRates map[string]math.LegacyDec,
+) (map[string]math.LegacyDec, map[string]struct{}, Abstentions) {
+	if cb.breaches == nil {
+		cb.breaches = make(map[string]circuitBreach)
+	}
+
+	guardedPrices := make(map[string]math.LegacyDec, len(prices))
+	held := make(map[string]struct{})
+	abstentions := make(Abstentions)
+
+	for base, price := range prices {
+		rule, ok := cb.rule(base)
+		if !ok {
+			guardedPrices[base] = price
+			continue
+		}
+
+		reference, referencePrice, breached := rule.breach(base, price, lastVotedPrices, onChainRates)
+		if !breached {
+			delete(cb.breaches, base)
+			guardedPrices[base] = price
+			continue
+		}
+		breach := cb.breaches[base].next(rule, votePeriod, price)
+		cb.breaches[base] = breach
+
+		telemetry.IncrCounterWithLabels([]string{"circuit_breaker", "trip"}, 1, []metrics.Label{
+			{Name: "base", Value: base},
+			{Name: "reference", Value: reference},
+			{Name: "action", Value: rule.Action},
+		})
+		logger.Warn().
+			Str("base", base).
+			Str("price", price.String()).
+			Str("reference", reference).
+			Str("reference_price", referencePrice.String()).
+			Str("max_change", rule.MaxChange.String()).
+			Str("action", rule.Action).
+			Int("periods", breach.periods).
+			Msg("price change tripped the circuit breaker")
+
+		if rule.Action == config.CircuitBreakerConfirm && breach.periods >= rule.ConfirmPeriods {
+			logger.Info().Str("base", base).Str("price", price.String()).Msg("price change confirmed")
+			delete(cb.breaches, base)
+			guardedPrices[base] = price
+			continue
+		}
+		if rule.Action == config.CircuitBreakerHold && rule.MaxHoldPeriods > 0 && breach.periods > rule.MaxHoldPeriods {
+			logger.Info().Str("base", base).Str("price", price.String()).Msg("price held for the max hold periods, accepting it")
+			delete(cb.breaches, base)
+			guardedPrices[base] = price
+			continue
+		}
+
+		switch rule.Action {
+		case config.CircuitBreakerHold, config.CircuitBreakerConfirm:
+			if lastVotedPrice, ok := lastVotedPrices[base]; ok {
+				guardedPrices[base] = lastVotedPrice
+				held[base] = struct{}{}
+			} else {
+				abstentions.add(base, "circuit breaker without a last vote to hold")
+			}
+
+		default:
+			abstentions.add(base, "circuit breaker")
+		}
+	}
+
+	// forget the breaches of the assets without prices
+	for base := range cb.breaches {
+		if _, ok := prices[base]; !ok {
+			delete(cb.breaches, base)
+		}
+	}
+
+	return guardedPrices, held, abstentions
+}
+
+// next returns the breach following a breaching price in the vote period,
+// counting the vote period if it follows the last breaching one. The "confirm"
+// action restarts the count when the price is not within the max change of
+// the last breaching price, as the change is not settled yet.
+func (b circuitBreach) next(rule CircuitBreakerRule, votePeriod int64, price math.LegacyDec) circuitBreach {
+	switch {
+	case b.periods == 0:
+		return circuitBreach{periods: 1, votePeriod: votePeriod, price: price}
+
+	case rule.Action == config.CircuitBreakerConfirm &&
+		price.Sub(b.price).Abs().Quo(b.price).GT(rule.MaxChange):
+		return circuitBreach{periods: 1, votePeriod: votePeriod, price: price}
+
+	case votePeriod == b.votePeriod:
+		return circuitBreach{periods: b.periods, votePeriod: votePeriod, price: price}
+
+	case votePeriod == b.votePeriod+1:
+		return circuitBreach{periods: b.periods + 1, votePeriod: votePeriod, price: price}
+
+	default:
+		return circuitBreach{periods: 1, votePeriod: votePeriod, price: price}
+	}
+}
+
+// breach returns the first reference the price changes more than the max
+// change from, false if none.
+func (rule CircuitBreakerRule) breach(
+	base string,
+	price math.LegacyDec,
+	lastVotedPrices map[string]math.LegacyDec,
+	onChainRates map[string]math.LegacyDec,
+) (string, math.LegacyDec, bool) {
+	for _, reference := range []struct {
+		name   string
+		prices map[string]math.LegacyDec
+	}{
+		{referenceLastVote, lastVotedPrices},
+		{referenceOnChain, onChainRates},
+	} {
+		referencePrice, ok := reference.prices[base]
+		if !ok || !referencePrice.IsPositive() {
+			continue
+		}
+
+		change := price.Sub(referencePrice).Abs().Quo(referencePrice)
+		if change.GT(rule.MaxChange) {
+			return reference.name, referencePrice, true
+		}
+	}
+
+	return "", math.LegacyDec{}, false
+}
+
+// applyCircuitBreaker guards the prices against sudden changes from the last
+// voted prices and the on-chain exchange rates, which are skipped if they
+// can not be queried.
+func (o *Oracle) applyCircuitBreaker(ctx context.Context, blockHeight int64, params oracletypes.Params) {
+	circuitBreaker := o.votePolicy.CircuitBreaker
+	if circuitBreaker == nil || !circuitBreaker.Enabled() {
+		return
+	}
+
+	exchangeRates, err := o.GetCachedExchangeRates(ctx, blockHeight, params.VotePeriod)
+	if err != nil {
+		o.logger.Warn().Err(err).Msg("failed to get the on-chain exchange rates of the circuit breaker")
+	}
+	onChainRates := make(map[string]math.LegacyDec, len(exchangeRates))
+	for base, chainDenom := range o.chainDenomMapping {
+		if rate, ok := exchangeRates[chainDenom]; ok {
+			onChainRates[base] = rate
+		}
+	}
+
+	// the breaches are counted by the vote period of the next block, as the
+	// votes
+	votePeriod := blockHeight + 1
+	if params.VotePeriod > 0 {
+		votePeriod /= int64(params.VotePeriod)
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	prices, held, abstentions := circuitBreaker.Apply(o.logger, votePeriod, o.prices, o.lastVotedPrices, onChainRates)
+	abstentions.report(o.logger)
+	o.prices = prices
+	o.heldPrices = held
+}
@@ -0,0 +1,291 @@
+package oracle
+
+import (
+	"context"
+	"testing"
+
+	oracletypes "github.com/kiichain/kiichain/v3/x/oracle/types"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+func TestCircuitBreaker_Apply(t *testing.T) {
+	rule := func(action string) CircuitBreakerRule {
+		return CircuitBreakerRule{
+			MaxChange:      math.LegacyMustNewDecFromStr("0.2"),
+			Action:         action,
+			ConfirmPeriods: 2,
+			MaxHoldPeriods: 2,
+		}
+	}
+	lastVotedPrices := map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)}
+
+	type tick struct {
+		votePeriod          int64
+		price               string
+		expected            string
+		held                bool
+		expectedAbstentions Abstentions
+	}
+
+	testCases := map[string]struct {
+		rule         CircuitBreakerRule
+		onChainRates map[string]math.LegacyDec
+		ticks        []tick
+	}{
+		"within the max change": {
+			rule:  rule(config.CircuitBreakerAbstain),
+			ticks: []tick{{votePeriod: 0, price: "12", expected: "12", expectedAbstentions: Abstentions{}}},
+		},
+		"hold": {
+			rule: rule(config.CircuitBreakerHold),
+			ticks: []tick{
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 1, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+			},
+		},
+		"hold until the max hold periods": {
+			rule: rule(config.CircuitBreakerHold),
+			ticks: []tick{
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 1, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 2, price: "14", expected: "14", expectedAbstentions: Abstentions{}},
+			},
+		},
+		"hold by vote period": {
+			rule: rule(config.CircuitBreakerHold),
+			ticks: []tick{
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 1, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 1, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 2, price: "14", expected: "14", expectedAbstentions: Abstentions{}},
+			},
+		},
+		"hold recovered": {
+			rule: rule(config.CircuitBreakerHold),
+			ticks: []tick{
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 1, price: "11", expected: "11", expectedAbstentions: Abstentions{}},
+				{votePeriod: 2, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+			},
+		},
+		"abstain": {
+			rule:  rule(config.CircuitBreakerAbstain),
+			ticks: []tick{{votePeriod: 0, price: "6", expectedAbstentions: Abstentions{"ATOM": "circuit breaker"}}},
+		},
+		"confirm": {
+			rule: rule(config.CircuitBreakerConfirm),
+			ticks: []tick{
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 1, price: "14", expected: "14", expectedAbstentions: Abstentions{}},
+			},
+		},
+		"confirm by vote period": {
+			rule: rule(config.CircuitBreakerConfirm),
+			ticks: []tick{
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 1, price: "14", expected: "14", expectedAbstentions: Abstentions{}},
+			},
+		},
+		"confirm moving price": {
+			rule: rule(config.CircuitBreakerConfirm),
+			ticks: []tick{
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 1, price: "20", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 2, price: "21", expected: "21", expectedAbstentions: Abstentions{}},
+			},
+		},
+		"confirm skipped vote period": {
+			rule: rule(config.CircuitBreakerConfirm),
+			ticks: []tick{
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 2, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 3, price: "14", expected: "14", expectedAbstentions: Abstentions{}},
+			},
+		},
+		"confirm interrupted": {
+			rule: rule(config.CircuitBreakerConfirm),
+			ticks: []tick{
+				{votePeriod: 0, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+				{votePeriod: 1, price: "11", expected: "11", expectedAbstentions: Abstentions{}},
+				{votePeriod: 2, price: "14", expected: "10", held: true, expectedAbstentions: Abstentions{}},
+			},
+		},
+		"on-chain rate": {
+			rule:         rule(config.CircuitBreakerHold),
+			onChainRates: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(8)},
+			ticks:        []tick{{votePeriod: 0, price: "11", expected: "10", held: true, expectedAbstentions: Abstentions{}}},
+		},
+		"disabled": {
+			rule:  CircuitBreakerRule{},
+			ticks: []tick{{votePeriod: 0, price: "100", expected: "100", expectedAbstentions: Abstentions{}}},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			circuitBreaker := &CircuitBreaker{Rules: map[string]CircuitBreakerRule{"ATOM": tc.rule}}
+
+			for i, tick := range tc.ticks {
+				prices, held, abstentions := circuitBreaker.Apply(
+					zerolog.Nop(),
+					tick.votePeriod,
+					map[string]math.LegacyDec{
+						"ATOM": math.LegacyMustNewDecFromStr(tick.price),
+						"KII":  math.LegacyNewDec(100),
+					},
+					lastVotedPrices,
+					tc.onChainRates,
+				)
+
+				expected := map[string]math.LegacyDec{"KII": math.LegacyNewDec(100)}
+				if len(tick.expected) > 0 {
+					expected["ATOM"] = math.LegacyMustNewDecFromStr(tick.expected)
+				}
+				require.Equalf(t, expected, prices, "unexpected prices of tick %d", i)
+				_, ok := held["ATOM"]
+				require.Equalf(t, tick.held, ok, "unexpected held price of tick %d", i)
+				require.Equalf(t, tick.expectedAbstentions, abstentions, "unexpected abstentions of tick %d", i)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_ApplyWithoutLastVote(t *testing.T) {
+	circuitBreaker := &CircuitBreaker{
+		Rule: CircuitBreakerRule{
+			MaxChange: math.LegacyMustNewDecFromStr("0.2"),
+			Action:    config.CircuitBreakerHold,
+		},
+	}
+
+	prices, held, abstentions := circuitBreaker.Apply(
+		zerolog.Nop(),
+		1,
+		map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(14)},
+		nil,
+		map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)},
+	)
+	require.Empty(t, prices)
+	require.Empty(t, held)
+	require.Equal(t, Abstentions{"ATOM": "circuit breaker without a last vote to hold"}, abstentions)
+}
+
+func TestNewCircuitBreaker(t *testing.T) {
+	circuitBreaker, err := NewCircuitBreaker(config.CircuitBreaker{
+		Action:         config.CircuitBreakerHold,
+		ConfirmPeriods: 3,
+		MaxHoldPeriods: 10,
+		Assets: []config.AssetCircuitBreaker{
+			{Base: "ATOM", MaxChange: "0.1", Action: config.CircuitBreakerConfirm, ConfirmPeriods: 5, MaxHoldPeriods: 10},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, circuitBreaker.Enabled())
+
+	_, ok := circuitBreaker.rule("KII")
+	require.False(t, ok)
+
+	rule, ok := circuitBreaker.rule("ATOM")
+	require.True(t, ok)
+	require.Equal(t, CircuitBreakerRule{
+		MaxChange:      math.LegacyMustNewDecFromStr("0.1"),
+		Action:         config.CircuitBreakerConfirm,
+		ConfirmPeriods: 5,
+		MaxHoldPeriods: 10,
+	}, rule)
+
+	circuitBreaker, err = NewCircuitBreaker(config.CircuitBreaker{Action: config.CircuitBreakerHold})
+	require.NoError(t, err)
+	require.False(t, circuitBreaker.Enabled())
+
+	_, err = NewCircuitBreaker(config.CircuitBreaker{MaxChange: "0.1", Action: "pause"})
+	require.ErrorContains(t, err, "unsupported circuit breaker action: pause")
+}
+
+func TestOracle_ApplyCircuitBreaker(t *testing.T) {
+	circuitBreaker, err := NewCircuitBreaker(config.CircuitBreaker{
+		MaxChange:      "0.2",
+		Action:         config.CircuitBreakerAbstain,
+		ConfirmPeriods: 3,
+	})
+	require.NoError(t, err)
+
+	oracle := &Oracle{
+		logger:            zerolog.Nop(),
+		chainDenomMapping: map[string]string{"ATOM": "uatom", "KII": "akii"},
+		votePolicy:        VotePolicy{CircuitBreaker: circuitBreaker},
+		prices: map[string]math.LegacyDec{
+			"ATOM": math.LegacyNewDec(10),
+			"KII":  math.LegacyNewDec(2),
+		},
+	}
+	// the on-chain rates of the vote period are cached
+	oracle.exchangeRateCache.Update(5, map[string]math.LegacyDec{
+		"uatom": math.LegacyNewDec(10),
+		"akii":  math.LegacyOneDec(),
+	})
+
+	oracle.applyCircuitBreaker(context.Background(), 10, oracletypes.Params{VotePeriod: 2})
+	require.Equal(t, map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)}, oracle.prices)
+
+	oracle.setLastVotedPrices(sdk.NewDecCoins(
+		sdk.NewDecCoinFromDec("uatom", math.LegacyNewDec(10)),
+		sdk.NewDecCoinFromDec("uusdc", math.LegacyOneDec()),
+	))
+	require.Equal(t, map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)}, oracle.lastVotedPrices)
+}
+
+func TestOracle_ApplyCircuitBreakerHoldRecovers(t *testing.T) {
+	circuitBreaker, err := NewCircuitBreaker(config.CircuitBreaker{
+		MaxChange:      "0.2",
+		Action:         config.CircuitBreakerHold,
+		ConfirmPeriods: 3,
+		MaxHoldPeriods: 2,
+	})
+	require.NoError(t, err)
+
+	oracle := &Oracle{
+		logger:            zerolog.Nop(),
+		chainDenomMapping: map[string]string{"ATOM": "uatom"},
+		votePolicy:        VotePolicy{CircuitBreaker: circuitBreaker},
+		lastVotedPrices:   map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)},
+	}
+	oracle.exchangeRateCache.Update(5, map[string]math.LegacyDec{})
+
+	// the price moves to a new level, held for the max hold periods without
+	// saving the held price as the last vote
+	for i, expected := range []int64{10, 10, 14} {
+		oracle.prices = map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(14)}
+		oracle.applyCircuitBreaker(context.Background(), int64(10+2*i), oracletypes.Params{VotePeriod: 2})
+		require.Equal(t, map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(expected)}, oracle.prices)
+
+		oracle.setLastVotedPrices(sdk.NewDecCoins(sdk.NewDecCoinFromDec("uatom", oracle.prices["ATOM"])))
+	}
+	require.Equal(t, map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(14)}, oracle.lastVotedPrices)
+
+	// the new level is the reference of the next prices
+	oracle.prices = map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(15)}
+	oracle.applyCircuitBreaker(context.Background(), 16, oracletypes.Params{VotePeriod: 2})
+	require.Equal(t, map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(15)}, oracle.prices)
+}
+
+func TestExchangeRateCache(t *testing.T) {
+	exchangeRateCache := ExchangeRateCache{}
+	require.True(t, exchangeRateCache.IsOutdated(1))
+
+	exchangeRateCache.Update(1, map[string]math.LegacyDec{"uatom": math.LegacyNewDec(10)})
+	require.False(t, exchangeRateCache.IsOutdated(1))
+	require.True(t, exchangeRateCache.IsOutdated(2))
+}
@@ -2,6 +2,7 @@ package oracle
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/rs/zerolog"
@@ -13,28 +14,275 @@ import (
 	"github.com/kiichain/price-feeder/oracle/types"
 )
 
-// getUSDBasedProviders retrieves which providers for an asset have a USD-based pair,
-// given the asset and the map of providers to currency pairs.
-func getUSDBasedProviders(asset string, providerPairs map[string][]types.CurrencyPair) (map[string]struct{}, error) {
-	conversionProviders := make(map[string]struct{})
+// conversionGraph defines the currency pairs priced by the providers as the
+// edges from their base to their quote, used to find the paths converting the
+// quotes into USD.
+type conversionGraph map[string]map[string]map[string]struct{} // base => quote => providers
 
-	for provider, pairs := range providerPairs {
+// newConversionGraph returns the conversion graph of the provider pairs.
+func newConversionGraph(providerPairs map[string][]types.CurrencyPair) conversionGraph {
+	graph := make(conversionGraph)
+
+	for providerName, pairs := range providerPairs {
 		for _, pair := range pairs {
-			if strings.ToUpper(pair.Quote) == config.DenomUSD && strings.ToUpper(pair.Base) == asset {
-				conversionProviders[provider] = struct{}{}
+			base, quote := pair.Base, pair.Quote
+			if _, ok := graph[base]; !ok {
+				graph[base] = make(map[string]map[string]struct{})
+			}
+			if _, ok := graph[base][quote]; !ok {
+				graph[base][quote] = make(map[string]struct{})
+			}
+			graph[base][quote][providerName] = struct{}{}
+		}
+	}
+
+	return graph
+}
+
+// providers returns the providers pricing the pair.
+func (g conversionGraph) providers(pair types.CurrencyPair) map[string]struct{} {
+	return g[pair.Base][pair.Quote]
+}
+
+// path returns the shortest path of pairs converting the asset into USD, ex.
+// X/BTC, BTC/USDT and USDT/USD for X. If there is no path, the error names
+// the assets reached from the asset without a pair leading further.
+func (g conversionGraph) path(asset string) ([]types.CurrencyPair, error) {
+	previous := map[string]string{asset: ""}
+	queue := []string{asset}
+	deadEnds := []string{}
+
+	for len(queue) > 0 {
+		base := queue[0]
+		queue = queue[1:]
+
+		if strings.ToUpper(base) == config.DenomUSD {
+			path := []types.CurrencyPair{}
+			for quote := base; previous[quote] != ""; quote = previous[quote] {
+				path = append([]types.CurrencyPair{{Base: previous[quote], Quote: quote}}, path...)
+			}
+			return path, nil
+		}
+
+		// visit the quotes in order, so the path is deterministic
+		quotes := make([]string, 0, len(g[base]))
+		for quote := range g[base] {
+			quotes = append(quotes, quote)
+		}
+		sort.Strings(quotes)
+
+		for _, quote := range quotes {
+			if _, ok := previous[quote]; !ok {
+				previous[quote] = base
+				queue = append(queue, quote)
+			}
+		}
+		if len(g[base]) == 0 {
+			deadEnds = append(deadEnds, base)
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"no conversion path from %s to USD: missing a pair with %s as base",
+		asset,
+		strings.Join(deadEnds, ", "),
+	)
+}
+
+// ratedPath returns the shortest path of pairs converting the asset into USD
+// through the pairs with a rate, along with the product of their rates. The
+// rate of a pair is given by the rate function, which returns false if it is
+// missing. It returns false if every path misses a rate.
+func (g conversionGraph) ratedPath(
+	asset string,
+	rate func(pair types.CurrencyPair) (math.LegacyDec, bool, error),
+) ([]types.CurrencyPair, math.LegacyDec, bool, error) {
+	previous := map[string]string{asset: ""}
+	pathRates := map[string]math.LegacyDec{asset: math.LegacyOneDec()}
+	queue := []string{asset}
+
+	for len(queue) > 0 {
+		base := queue[0]
+		queue = queue[1:]
+
+		if strings.ToUpper(base) == config.DenomUSD {
+			path := []types.CurrencyPair{}
+			for quote := base; previous[quote] != ""; quote = previous[quote] {
+				path = append([]types.CurrencyPair{{Base: previous[quote], Quote: quote}}, path...)
+			}
+			return path, pathRates[base], true, nil
+		}
+
+		// visit the quotes in order, so the path is deterministic
+		quotes := make([]string, 0, len(g[base]))
+		for quote := range g[base] {
+			quotes = append(quotes, quote)
+		}
+		sort.Strings(quotes)
+
+		for _, quote := range quotes {
+			if _, ok := previous[quote]; ok {
+				continue
+			}
+
+			edgeRate, ok, err := rate(types.CurrencyPair{Base: base, Quote: quote})
+			if err != nil {
+				return nil, math.LegacyDec{}, false, err
+			}
+			if !ok {
+				continue
+			}
+
+			previous[quote] = base
+			pathRates[quote] = pathRates[base].Mul(edgeRate)
+			queue = append(queue, quote)
+		}
+	}
+
+	return nil, math.LegacyDec{}, false, nil
+}
+
+// routeSeparator separates the provider name from the quote in the name of a
+// route.
+const routeSeparator = "/"
+
+// formatPath returns the assets of the path, ex. X→BTC→USDT→USD.
+func formatPath(path []types.CurrencyPair) string {
+	if len(path) == 0 {
+		return ""
+	}
+
+	assets := []string{path[0].Base}
+	for _, pair := range path {
+		assets = append(assets, pair.Quote)
+	}
+	return strings.Join(assets, "→")
+}
+
+// conversionRates returns the USD rate and the conversion path of every non-USD
+// quote of the provider pairs, multiplying the rates of every pair on the
+// shortest path of the quote to USD whose pairs all have a rate.
+// The rate of a pair is computed by the edgeRate function, given the providers
+// pricing the pair, which returns false if the rate is missing. The quotes
+// without any path with rates are skipped with a warning, so only the pairs
+// quoted in them are not converted.
+func conversionRates(
+	logger zerolog.Logger,
+	providerPairs map[string][]types.CurrencyPair,
+	edgeRate func(pair types.CurrencyPair, providers map[string]struct{}) (math.LegacyDec, bool, error),
+) (map[string]math.LegacyDec, map[string][]types.CurrencyPair, error) {
+	var (
+		graph        = newConversionGraph(providerPairs)
+		edgeRates    = make(map[string]math.LegacyDec)
+		missingEdges = make(map[string]struct{})
+		rates        = make(map[string]math.LegacyDec)
+		paths        = make(map[string][]types.CurrencyPair)
+		skipped      = make(map[string]struct{})
+	)
+
+	rate := func(edge types.CurrencyPair) (math.LegacyDec, bool, error) {
+		key := edge.Base + "/" + edge.Quote
+		if rate, ok := edgeRates[key]; ok {
+			return rate, true, nil
+		}
+		if _, ok := missingEdges[key]; ok {
+			return math.LegacyDec{}, false, nil
+		}
+
+		rate, ok, err := edgeRate(edge, graph.providers(edge))
+		if err != nil {
+			return math.LegacyDec{}, false, err
+		}
+		if !ok {
+			missingEdges[key] = struct{}{}
+			return math.LegacyDec{}, false, nil
+		}
+		edgeRates[key] = rate
+		return rate, true, nil
+	}
+
+	for _, pairs := range providerPairs {
+		for _, pair := range pairs {
+			if strings.ToUpper(pair.Quote) == config.DenomUSD {
+				continue
+			}
+			if _, ok := rates[pair.Quote]; ok {
+				continue
+			}
+			if _, ok := skipped[pair.Quote]; ok {
+				continue
+			}
+
+			// the quote must be connected to USD by the pairs of the providers
+			shortestPath, err := graph.path(pair.Quote)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			path, pathRate, ok, err := graph.ratedPath(pair.Quote, rate)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				logger.Warn().
+					Str("quote", pair.Quote).
+					Str("path", formatPath(shortestPath)).
+					Msg("no valid conversion rates to USD on any path, skipping the pairs of the quote")
+				skipped[pair.Quote] = struct{}{}
+				continue
 			}
+
+			rates[pair.Quote] = pathRate
+			paths[pair.Quote] = path
 		}
 	}
-	if len(conversionProviders) == 0 {
-		return nil, fmt.Errorf("no providers have a usd conversion for this asset")
+
+	return rates, paths, nil
+}
+
+// routeName returns the name of the sample of the pair priced by the provider,
+// the provider name followed by the quote if the provider prices the base of
+// the pair through several quotes, ex. binance/USDC.
+func routeName(providerName string, pair types.CurrencyPair, pairs []types.CurrencyPair) string {
+	for _, other := range pairs {
+		if other.Base == pair.Base && other.Quote != pair.Quote {
+			return providerName + routeSeparator + pair.Quote
+		}
 	}
+	return providerName
+}
 
-	return conversionProviders, nil
+// routeProvider returns the name of the provider of the route.
+func routeProvider(route string) string {
+	providerName, _, _ := strings.Cut(route, routeSeparator)
+	return providerName
+}
+
+// latestCandlePrice returns the price of the most recent candle, false if
+// there is none.
+func latestCandlePrice(candles []provider.CandlePrice) (math.LegacyDec, bool) {
+	if len(candles) == 0 {
+		return math.LegacyDec{}, false
+	}
+
+	latest := candles[0]
+	for _, candle := range candles[1:] {
+		if candle.TimeStamp > latest.TimeStamp {
+			latest = candle
+		}
+	}
+	return latest.Price, true
 }
 
 // ConvertCandlesToUSD converts any candles which are not quoted in USD
-// to USD by other price feeds. It will also filter out any candles not
-// within the deviation threshold set by the config.
+// to USD by other price feeds, following the conversion path of their quote.
+// The rate of every pair on the path is aggregated across the providers of
+// the pair, after filtering out the candles not within the deviation
+// threshold set by the config. The candles are given by provider and pair, and
+// returned by route and base, every quote of a base priced by a provider being
+// a separate route. The routes converted through a depegged stablecoin are
+// dropped or abstained from, as set by the depeg guard of the stablecoin, the
+// bases without any other route being abstained from.
 //
 // Ref: https://github.com/umee-network/umee/blob/4348c3e433df8c37dd98a690e96fc275de609bc1/price-feeder/oracle/filter.go#L41
 func convertCandlesToUSD(
@@ -42,83 +290,111 @@ func convertCandlesToUSD(
 	candles provider.AggregatedProviderCandles,
 	providerPairs map[string][]types.CurrencyPair,
 	deviationThresholds map[string]math.LegacyDec,
-) (provider.AggregatedProviderCandles, error) {
+	aggregation Aggregation,
+) (provider.AggregatedProviderCandles, Abstentions, error) {
+	abstentions := make(Abstentions)
 	if len(candles) == 0 {
-		return candles, nil
+		return candles, abstentions, nil
 	}
 
-	conversionRates := make(map[string]math.LegacyDec)
-	requiredConversions := make(map[string]types.CurrencyPair)
-
-	for pairProviderName, pairs := range providerPairs {
-		for _, pair := range pairs {
-			if strings.ToUpper(pair.Quote) != config.DenomUSD {
-				// Get valid providers and use them to generate a USD-based price for this asset.
-				validProviders, err := getUSDBasedProviders(pair.Quote, providerPairs)
-				if err != nil {
-					return nil, err
+	conversionRates, paths, err := conversionRates(
+		logger,
+		providerPairs,
+		func(pair types.CurrencyPair, providers map[string]struct{}) (math.LegacyDec, bool, error) {
+			// Find candles which we can use for conversion, and aggregate them
+			// to find the conversion rate.
+			validCandleList := provider.AggregatedProviderCandles{}
+			for providerName := range providers {
+				if candle, ok := candles[providerName][pair.String()]; ok {
+					validCandleList[providerName] = map[string][]provider.CandlePrice{pair.Base: candle}
 				}
+			}
 
-				// Find candles which we can use for conversion, and calculate the tvwap
-				// to find the conversion rate.
-				validCandleList := provider.AggregatedProviderCandles{}
-				for providerName, candleSet := range candles {
-					if _, ok := validProviders[providerName]; ok {
-						for base, candle := range candleSet {
-							if base == pair.Quote {
-								if _, ok := validCandleList[providerName]; !ok {
-									validCandleList[providerName] = make(map[string][]provider.CandlePrice)
-								}
-
-								validCandleList[providerName][base] = candle
-							}
-						}
-					}
-				}
+			if len(validCandleList) == 0 {
+				return math.LegacyDec{}, false, nil
+			}
 
-				if len(validCandleList) == 0 {
-					return nil, fmt.Errorf("there are no valid conversion rates for %s", pair.Quote)
-				}
+			filteredCandles, err := FilterCandleDeviations(
+				logger,
+				validCandleList,
+				deviationThresholds,
+				aggregation,
+			)
+			if err != nil {
+				return math.LegacyDec{}, false, err
+			}
 
-				filteredCandles, err := FilterCandleDeviations(
-					logger,
-					validCandleList,
-					deviationThresholds,
-				)
-				if err != nil {
-					return nil, err
-				}
+			rates, err := aggregation.AggregateCandles(filteredCandles)
+			if err != nil {
+				return math.LegacyDec{}, false, err
+			}
 
-				tvwap, err := ComputeTVWAP(filteredCandles)
-				if err != nil {
-					return nil, err
+			rate, ok := rates[pair.Base]
+			return rate, ok, nil
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	impliedRates := aggregation.DepegGuards.impliedRates(
+		providerPairs,
+		func(providerName string, pair types.CurrencyPair) (math.LegacyDec, bool) {
+			return latestCandlePrice(candles[providerName][pair.String()])
+		},
+	)
+	depegged := aggregation.DepegGuards.depegged(logger, "candle", conversionRates, impliedRates)
+	depegged.abstainUnrouted(providerPairs, paths, abstentions)
+
+	// Convert assets to USD.
+	convertedCandles := make(provider.AggregatedProviderCandles)
+	for providerName, pairs := range providerPairs {
+		for _, pair := range pairs {
+			pairCandles, ok := candles[providerName][pair.String()]
+			if !ok || !depegged.allows(pair, paths[pair.Quote], abstentions) {
+				continue
+			}
+
+			rate := math.LegacyOneDec()
+			if strings.ToUpper(pair.Quote) != config.DenomUSD {
+				// the quotes without conversion rates are skipped
+				if rate, ok = conversionRates[pair.Quote]; !ok {
+					continue
 				}
+			}
 
-				conversionRates[pair.Quote] = tvwap[pair.Quote]
-				requiredConversions[pairProviderName] = pair
+			assetCandles := make([]provider.CandlePrice, len(pairCandles))
+			for i, candle := range pairCandles {
+				candle.Price = candle.Price.Mul(rate)
+				assetCandles[i] = candle
 			}
+
+			route := routeName(providerName, pair, pairs)
+			if _, ok := convertedCandles[route]; !ok {
+				convertedCandles[route] = make(map[string][]provider.CandlePrice)
+			}
+			convertedCandles[route][pair.Base] = assetCandles
 		}
 	}
 
-	// Convert assets to USD.
-	for provider, assetMap := range candles {
-		for asset, assetCandles := range assetMap {
-			if requiredConversions[provider].Base == asset {
-				for i := range assetCandles {
-					assetCandles[i].Price = assetCandles[i].Price.Mul(
-						conversionRates[requiredConversions[provider].Quote],
-					)
-				}
-			}
+	// Drop the other routes of the abstained assets.
+	for base := range abstentions {
+		for _, assetCandles := range convertedCandles {
+			delete(assetCandles, base)
 		}
 	}
 
-	return candles, nil
+	return convertedCandles, abstentions, nil
 }
 
 // convertTickersToUSD converts any tickers which are not quoted in USD to USD,
-// using the conversion rates of other tickers. It will also filter out any tickers
-// not within the deviation threshold set by the config.
+// following the conversion path of their quote. The rate of every pair on the
+// path is aggregated across the providers of the pair, after filtering out the
+// tickers not within the deviation threshold set by the config. The tickers
+// are given by provider and pair, and returned by route and base, every quote
+// of a base priced by a provider being a separate route. The routes converted
+// through a depegged stablecoin are dropped or abstained from, as set by the
+// depeg guard of the stablecoin, the bases without any other route being
+// abstained from.
 //
 // Ref: https://github.com/umee-network/umee/blob/4348c3e433df8c37dd98a690e96fc275de609bc1/price-feeder/oracle/filter.go#L41
 func convertTickersToUSD(
@@ -126,78 +402,94 @@ func convertTickersToUSD(
 	tickers provider.AggregatedProviderPrices,
 	providerPairs map[string][]types.CurrencyPair,
 	deviationThresholds map[string]math.LegacyDec,
-) (provider.AggregatedProviderPrices, error) {
+	aggregation Aggregation,
+) (provider.AggregatedProviderPrices, Abstentions, error) {
+	abstentions := make(Abstentions)
 	if len(tickers) == 0 {
-		return tickers, nil
+		return tickers, abstentions, nil
 	}
 
-	conversionRates := make(map[string]math.LegacyDec)
-	requiredConversions := make(map[string]types.CurrencyPair)
-
-	for pairProviderName, pairs := range providerPairs {
-		for _, pair := range pairs {
-			if strings.ToUpper(pair.Quote) != config.DenomUSD {
-				// Get valid providers and use them to generate a USD-based price for this asset.
-				validProviders, err := getUSDBasedProviders(pair.Quote, providerPairs)
-				if err != nil {
-					return nil, err
+	conversionRates, paths, err := conversionRates(
+		logger,
+		providerPairs,
+		func(pair types.CurrencyPair, providers map[string]struct{}) (math.LegacyDec, bool, error) {
+			// Find tickers which we can use for conversion, and aggregate them
+			// to find the conversion rate.
+			validTickerList := provider.AggregatedProviderPrices{}
+			for providerName := range providers {
+				if ticker, ok := tickers[providerName][pair.String()]; ok {
+					validTickerList[providerName] = map[string]provider.TickerPrice{pair.Base: ticker}
 				}
+			}
 
-				// Find valid candles, and then let's re-compute the tvwap.
-				validTickerList := provider.AggregatedProviderPrices{}
-				for providerName, candleSet := range tickers {
-					// Find tickers which we can use for conversion, and calculate the vwap
-					// to find the conversion rate.
-					if _, ok := validProviders[providerName]; ok {
-						for base, ticker := range candleSet {
-							if base == pair.Quote {
-								if _, ok := validTickerList[providerName]; !ok {
-									validTickerList[providerName] = make(map[string]provider.TickerPrice)
-								}
-
-								validTickerList[providerName][base] = ticker
-							}
-						}
-					}
-				}
+			if len(validTickerList) == 0 {
+				return math.LegacyDec{}, false, nil
+			}
 
-				if len(validTickerList) == 0 {
-					return nil, fmt.Errorf("there are no valid conversion rates for %s", pair.Quote)
-				}
+			filteredTickers, err := FilterTickerDeviations(
+				logger,
+				validTickerList,
+				deviationThresholds,
+				aggregation,
+			)
+			if err != nil {
+				return math.LegacyDec{}, false, err
+			}
 
-				filteredTickers, err := FilterTickerDeviations(
-					logger,
-					validTickerList,
-					deviationThresholds,
-				)
-				if err != nil {
-					return nil, err
-				}
+			rates, err := aggregation.AggregateTickers(filteredTickers)
+			if err != nil {
+				return math.LegacyDec{}, false, err
+			}
 
-				vwap, err := ComputeVWAP(filteredTickers)
-				if err != nil {
-					return nil, err
+			rate, ok := rates[pair.Base]
+			return rate, ok, nil
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	impliedRates := aggregation.DepegGuards.impliedRates(
+		providerPairs,
+		func(providerName string, pair types.CurrencyPair) (math.LegacyDec, bool) {
+			ticker, ok := tickers[providerName][pair.String()]
+			return ticker.Price, ok
+		},
+	)
+	depegged := aggregation.DepegGuards.depegged(logger, "ticker", conversionRates, impliedRates)
+	depegged.abstainUnrouted(providerPairs, paths, abstentions)
+
+	// Convert assets to USD.
+	convertedTickers := make(provider.AggregatedProviderPrices)
+	for providerName, pairs := range providerPairs {
+		for _, pair := range pairs {
+			ticker, ok := tickers[providerName][pair.String()]
+			if !ok || !depegged.allows(pair, paths[pair.Quote], abstentions) {
+				continue
+			}
+
+			if strings.ToUpper(pair.Quote) != config.DenomUSD {
+				// the quotes without conversion rates are skipped
+				rate, ok := conversionRates[pair.Quote]
+				if !ok {
+					continue
 				}
+				ticker.Price = ticker.Price.Mul(rate)
+			}
 
-				conversionRates[pair.Quote] = vwap[pair.Quote]
-				requiredConversions[pairProviderName] = pair
+			route := routeName(providerName, pair, pairs)
+			if _, ok := convertedTickers[route]; !ok {
+				convertedTickers[route] = make(map[string]provider.TickerPrice)
 			}
+			convertedTickers[route][pair.Base] = ticker
 		}
 	}
 
-	// Convert assets to USD.
-	for providerName, assetMap := range tickers {
-		for asset := range assetMap {
-			if requiredConversions[providerName].Base == asset {
-				assetMap[asset] = provider.TickerPrice{
-					Price: assetMap[asset].Price.Mul(
-						conversionRates[requiredConversions[providerName].Quote],
-					),
-					Volume: assetMap[asset].Volume,
-				}
-			}
+	// Drop the other routes of the abstained assets.
+	for base := range abstentions {
+		for _, assetTickers := range convertedTickers {
+			delete(assetTickers, base)
 		}
 	}
 
-	return tickers, nil
+	return convertedTickers, abstentions, nil
 }
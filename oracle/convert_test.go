@@ -30,57 +30,96 @@ var (
 	}
 )
 
-func TestGetUSDBasedProviders(t *testing.T) {
-	providerPairs := make(map[string][]types.CurrencyPair, 3)
-	providerPairs["coinbase"] = []types.CurrencyPair{
-		{
-			Base:  "FOO",
-			Quote: "USD",
-		},
+func TestConversionGraph_Path(t *testing.T) {
+	providerPairs := map[string][]types.CurrencyPair{
+		config.ProviderCoinbase: {{Base: "FOO", Quote: "USD"}},
+		config.ProviderKraken:   {{Base: "FOO", Quote: "USDT"}, {Base: "X", Quote: "BTC"}},
+		config.ProviderBinance:  {{Base: "BTC", Quote: "USDT"}, {Base: "BAR", Quote: "BAZ"}},
+		config.ProviderOkx:      {{Base: "USDT", Quote: "USD"}, {Base: "BTC", Quote: "ETH"}},
 	}
-	providerPairs["huobi"] = []types.CurrencyPair{
-		{
-			Base:  "FOO",
-			Quote: "USD",
+	graph := newConversionGraph(providerPairs)
+
+	testCases := map[string]struct {
+		asset       string
+		expected    []types.CurrencyPair
+		expectedErr string
+	}{
+		"usd": {
+			asset:    "USD",
+			expected: []types.CurrencyPair{},
 		},
-	}
-	providerPairs["kraken"] = []types.CurrencyPair{
-		{
-			Base:  "FOO",
-			Quote: "USDT",
+		"single hop": {
+			asset:    "USDT",
+			expected: []types.CurrencyPair{{Base: "USDT", Quote: "USD"}},
 		},
-	}
-	providerPairs["binance"] = []types.CurrencyPair{
-		{
-			Base:  "USDT",
-			Quote: "USD",
+		"shortest path": {
+			asset:    "FOO",
+			expected: []types.CurrencyPair{{Base: "FOO", Quote: "USD"}},
+		},
+		"multiple hops": {
+			asset: "X",
+			expected: []types.CurrencyPair{
+				{Base: "X", Quote: "BTC"},
+				{Base: "BTC", Quote: "USDT"},
+				{Base: "USDT", Quote: "USD"},
+			},
+		},
+		"no path": {
+			asset:       "BAR",
+			expectedErr: "no conversion path from BAR to USD: missing a pair with BAZ as base",
+		},
+		"unknown asset": {
+			asset:       "QUX",
+			expectedErr: "no conversion path from QUX to USD: missing a pair with QUX as base",
 		},
 	}
 
-	pairs, err := getUSDBasedProviders("FOO", providerPairs)
-	require.NoError(t, err)
-	expectedPairs := map[string]struct{}{
-		"coinbase": {},
-		"huobi":    {},
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			path, err := graph.path(tc.asset)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, path)
+		})
 	}
-	require.Equal(t, pairs, expectedPairs)
 
-	pairs, err = getUSDBasedProviders("USDT", providerPairs)
-	require.NoError(t, err)
-	expectedPairs = map[string]struct{}{
-		"binance": {},
+	require.Equal(
+		t,
+		map[string]struct{}{config.ProviderCoinbase: {}},
+		graph.providers(types.CurrencyPair{Base: "FOO", Quote: "USD"}),
+	)
+	require.Equal(t, "X→BTC→USDT→USD", formatPath([]types.CurrencyPair{
+		{Base: "X", Quote: "BTC"},
+		{Base: "BTC", Quote: "USDT"},
+		{Base: "USDT", Quote: "USD"},
+	}))
+}
+
+func TestRouteName(t *testing.T) {
+	pairs := []types.CurrencyPair{
+		{Base: "ATOM", Quote: "USDT"},
+		{Base: "ATOM", Quote: "USDC"},
+		{Base: "KII", Quote: "USDT"},
 	}
-	require.Equal(t, pairs, expectedPairs)
 
-	_, err = getUSDBasedProviders("BAR", providerPairs)
-	require.Error(t, err)
+	require.Equal(t, "binance/USDT", routeName(config.ProviderBinance, pairs[0], pairs))
+	require.Equal(t, "binance/USDC", routeName(config.ProviderBinance, pairs[1], pairs))
+	require.Equal(t, config.ProviderBinance, routeName(config.ProviderBinance, pairs[2], pairs))
+
+	require.Equal(t, config.ProviderBinance, routeProvider("binance/USDC"))
+	require.Equal(t, config.ProviderBinance, routeProvider(config.ProviderBinance))
 }
 
 func TestConvertCandlesToUSD(t *testing.T) {
 	providerCandles := make(provider.AggregatedProviderCandles, 2)
 
 	binanceCandles := map[string][]provider.CandlePrice{
-		"ATOM": {{
+		atomPair.String(): {{
 			Price:     atomPrice,
 			Volume:    atomVolume,
 			TimeStamp: provider.PastUnixTime(1 * time.Minute),
@@ -89,7 +128,7 @@ func TestConvertCandlesToUSD(t *testing.T) {
 	providerCandles[config.ProviderBinance] = binanceCandles
 
 	krakenCandles := map[string][]provider.CandlePrice{
-		"USDT": {{
+		usdtPair.String(): {{
 			Price:     usdtPrice,
 			Volume:    usdtVolume,
 			TimeStamp: provider.PastUnixTime(1 * time.Minute),
@@ -102,11 +141,12 @@ func TestConvertCandlesToUSD(t *testing.T) {
 		config.ProviderKraken:  {usdtPair},
 	}
 
-	convertedCandles, err := convertCandlesToUSD(
+	convertedCandles, _, err := convertCandlesToUSD(
 		zerolog.Nop(),
 		providerCandles,
 		providerPairs,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 	)
 	require.NoError(t, err)
 
@@ -121,7 +161,7 @@ func TestConvertCandlesToUSDFiltering(t *testing.T) {
 	providerCandles := make(provider.AggregatedProviderCandles, 2)
 
 	binanceCandles := map[string][]provider.CandlePrice{
-		"ATOM": {{
+		atomPair.String(): {{
 			Price:     atomPrice,
 			Volume:    atomVolume,
 			TimeStamp: provider.PastUnixTime(1 * time.Minute),
@@ -130,7 +170,7 @@ func TestConvertCandlesToUSDFiltering(t *testing.T) {
 	providerCandles[config.ProviderBinance] = binanceCandles
 
 	krakenCandles := map[string][]provider.CandlePrice{
-		"USDT": {{
+		usdtPair.String(): {{
 			Price:     usdtPrice,
 			Volume:    usdtVolume,
 			TimeStamp: provider.PastUnixTime(1 * time.Minute),
@@ -139,7 +179,7 @@ func TestConvertCandlesToUSDFiltering(t *testing.T) {
 	providerCandles[config.ProviderKraken] = krakenCandles
 
 	gateCandles := map[string][]provider.CandlePrice{
-		"USDT": {{
+		usdtPair.String(): {{
 			Price:     usdtPrice,
 			Volume:    usdtVolume,
 			TimeStamp: provider.PastUnixTime(1 * time.Minute),
@@ -148,7 +188,7 @@ func TestConvertCandlesToUSDFiltering(t *testing.T) {
 	providerCandles[config.ProviderGate] = gateCandles
 
 	okxCandles := map[string][]provider.CandlePrice{
-		"USDT": {{
+		usdtPair.String(): {{
 			Price:     math.LegacyMustNewDecFromStr("100.0"),
 			Volume:    usdtVolume,
 			TimeStamp: provider.PastUnixTime(1 * time.Minute),
@@ -163,11 +203,12 @@ func TestConvertCandlesToUSDFiltering(t *testing.T) {
 		config.ProviderOkx:     {usdtPair},
 	}
 
-	convertedCandles, err := convertCandlesToUSD(
+	convertedCandles, _, err := convertCandlesToUSD(
 		zerolog.Nop(),
 		providerCandles,
 		providerPairs,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 	)
 	require.NoError(t, err)
 
@@ -182,7 +223,7 @@ func TestConvertTickersToUSD(t *testing.T) {
 	providerPrices := make(provider.AggregatedProviderPrices, 2)
 
 	binanceTickers := map[string]provider.TickerPrice{
-		"ATOM": {
+		atomPair.String(): {
 			Price:  atomPrice,
 			Volume: atomVolume,
 		},
@@ -190,7 +231,7 @@ func TestConvertTickersToUSD(t *testing.T) {
 	providerPrices[config.ProviderBinance] = binanceTickers
 
 	krakenTicker := map[string]provider.TickerPrice{
-		"USDT": {
+		usdtPair.String(): {
 			Price:  usdtPrice,
 			Volume: usdtVolume,
 		},
@@ -202,11 +243,12 @@ func TestConvertTickersToUSD(t *testing.T) {
 		config.ProviderKraken:  {usdtPair},
 	}
 
-	convertedTickers, err := convertTickersToUSD(
+	convertedTickers, _, err := convertTickersToUSD(
 		zerolog.Nop(),
 		providerPrices,
 		providerPairs,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 	)
 	require.NoError(t, err)
 
@@ -221,7 +263,7 @@ func TestConvertTickersToUSDFiltering(t *testing.T) {
 	providerPrices := make(provider.AggregatedProviderPrices, 2)
 
 	binanceTickers := map[string]provider.TickerPrice{
-		"ATOM": {
+		atomPair.String(): {
 			Price:  atomPrice,
 			Volume: atomVolume,
 		},
@@ -229,7 +271,7 @@ func TestConvertTickersToUSDFiltering(t *testing.T) {
 	providerPrices[config.ProviderBinance] = binanceTickers
 
 	krakenTicker := map[string]provider.TickerPrice{
-		"USDT": {
+		usdtPair.String(): {
 			Price:  usdtPrice,
 			Volume: usdtVolume,
 		},
@@ -237,12 +279,12 @@ func TestConvertTickersToUSDFiltering(t *testing.T) {
 	providerPrices[config.ProviderKraken] = krakenTicker
 
 	gateTicker := map[string]provider.TickerPrice{
-		"USDT": krakenTicker["USDT"],
+		usdtPair.String(): krakenTicker[usdtPair.String()],
 	}
 	providerPrices[config.ProviderGate] = gateTicker
 
 	huobiTicker := map[string]provider.TickerPrice{
-		"USDT": {
+		usdtPair.String(): {
 			Price:  math.LegacyMustNewDecFromStr("10000"),
 			Volume: usdtVolume,
 		},
@@ -256,11 +298,12 @@ func TestConvertTickersToUSDFiltering(t *testing.T) {
 		config.ProviderHuobi:   {usdtPair},
 	}
 
-	covertedDeviation, err := convertTickersToUSD(
+	covertedDeviation, _, err := convertTickersToUSD(
 		zerolog.Nop(),
 		providerPrices,
 		providerPairs,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 	)
 	require.NoError(t, err)
 
@@ -270,3 +313,127 @@ func TestConvertTickersToUSDFiltering(t *testing.T) {
 		covertedDeviation["binance"]["ATOM"].Price,
 	)
 }
+
+func TestConvertTickersToUSDMultiHop(t *testing.T) {
+	tickerPrice := func(price string) provider.TickerPrice {
+		return provider.TickerPrice{
+			Price:  math.LegacyMustNewDecFromStr(price),
+			Volume: math.LegacyNewDec(100),
+		}
+	}
+
+	usdcPair := types.CurrencyPair{Base: "USDC", Quote: "USD"}
+	providerPairs := map[string][]types.CurrencyPair{
+		config.ProviderMexc:     {{Base: "KII", Quote: "BTC"}, {Base: "ATOM", Quote: "USDT"}},
+		config.ProviderBinance:  {{Base: "BTC", Quote: "USDT"}},
+		config.ProviderKraken:   {{Base: "BTC", Quote: "USDT"}},
+		config.ProviderOkx:      {usdtPair},
+		config.ProviderGate:     {{Base: "BTC", Quote: "USDC"}},
+		config.ProviderCoinbase: {usdcPair},
+	}
+
+	testCases := map[string]struct {
+		tickers        provider.AggregatedProviderPrices
+		expectedPrices map[string]math.LegacyDec
+	}{
+		"rates aggregated at every hop": {
+			tickers: provider.AggregatedProviderPrices{
+				config.ProviderMexc: {
+					"KIIBTC":   tickerPrice("0.0001"),
+					"ATOMUSDT": tickerPrice("10"),
+				},
+				config.ProviderBinance: {"BTCUSDT": tickerPrice("60000")},
+				config.ProviderKraken:  {"BTCUSDT": tickerPrice("62000")},
+				config.ProviderOkx:     {usdtPair.String(): tickerPrice("0.99")},
+			},
+			expectedPrices: map[string]math.LegacyDec{
+				"KII":  math.LegacyMustNewDecFromStr("6.039"),
+				"ATOM": math.LegacyMustNewDecFromStr("9.9"),
+			},
+		},
+		"missing edge converted through another path": {
+			tickers: provider.AggregatedProviderPrices{
+				config.ProviderMexc: {
+					"KIIBTC":   tickerPrice("0.0001"),
+					"ATOMUSDT": tickerPrice("10"),
+				},
+				config.ProviderOkx:      {usdtPair.String(): tickerPrice("0.99")},
+				config.ProviderGate:     {"BTCUSDC": tickerPrice("61000")},
+				config.ProviderCoinbase: {usdcPair.String(): tickerPrice("1")},
+			},
+			expectedPrices: map[string]math.LegacyDec{
+				"KII":  math.LegacyMustNewDecFromStr("6.1"),
+				"ATOM": math.LegacyMustNewDecFromStr("9.9"),
+			},
+		},
+		"missing edge on every path": {
+			tickers: provider.AggregatedProviderPrices{
+				config.ProviderMexc: {
+					"KIIBTC":   tickerPrice("0.0001"),
+					"ATOMUSDT": tickerPrice("10"),
+				},
+				config.ProviderOkx: {usdtPair.String(): tickerPrice("0.99")},
+			},
+			expectedPrices: map[string]math.LegacyDec{
+				"ATOM": math.LegacyMustNewDecFromStr("9.9"),
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			convertedTickers, _, err := convertTickersToUSD(
+				zerolog.Nop(),
+				tc.tickers,
+				providerPairs,
+				make(map[string]math.LegacyDec),
+				Aggregation{},
+			)
+			require.NoError(t, err)
+
+			// both pairs of the same provider are converted by their own quote
+			prices := make(map[string]math.LegacyDec)
+			for base, ticker := range convertedTickers[config.ProviderMexc] {
+				prices[base] = ticker.Price
+			}
+			require.Equal(t, tc.expectedPrices, prices)
+		})
+	}
+}
+
+func TestConvertCandlesToUSDMultiHop(t *testing.T) {
+	candlePrice := func(price string) []provider.CandlePrice {
+		return []provider.CandlePrice{{
+			Price:     math.LegacyMustNewDecFromStr(price),
+			Volume:    math.LegacyNewDec(100),
+			TimeStamp: provider.PastUnixTime(1 * time.Minute),
+		}}
+	}
+
+	providerPairs := map[string][]types.CurrencyPair{
+		config.ProviderMexc:    {{Base: "KII", Quote: "BTC"}},
+		config.ProviderBinance: {{Base: "BTC", Quote: "USDT"}},
+		config.ProviderOkx:     {usdtPair},
+	}
+
+	convertedCandles, _, err := convertCandlesToUSD(
+		zerolog.Nop(),
+		provider.AggregatedProviderCandles{
+			config.ProviderMexc:    {"KIIBTC": candlePrice("0.0001")},
+			config.ProviderBinance: {"BTCUSDT": candlePrice("60000")},
+			config.ProviderOkx:     {usdtPair.String(): candlePrice("0.99")},
+		},
+		providerPairs,
+		make(map[string]math.LegacyDec),
+		Aggregation{},
+	)
+	require.NoError(t, err)
+
+	require.Equal(
+		t,
+		math.LegacyMustNewDecFromStr("5.94"),
+		convertedCandles[config.ProviderMexc]["KII"][0].Price,
+	)
+}
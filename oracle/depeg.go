@@ -0,0 +1,250 @@
+package oracle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-metrics"
+	"github.com/rs/zerolog"
+
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+// defaultDepegBand defines the fraction of its USD peg a stablecoin can move
+// away from it without being considered depegged.
+var defaultDepegBand = math.LegacyMustNewDecFromStr("0.02")
+
+type (
+	// DepegGuard defines the Band of its USD peg a stablecoin can move away
+	// from it, and the Action when it moves outside the band.
+	DepegGuard struct {
+		Band   math.LegacyDec
+		Action string
+	}
+
+	// DepegGuards are the depeg guards by stablecoin.
+	DepegGuards map[string]DepegGuard
+)
+
+// NewDepegGuards returns the depeg guards of the stablecoins.
+func NewDepegGuards(stablecoinGuards []config.StablecoinGuard) (DepegGuards, error) {
+	guards := make(DepegGuards, len(stablecoinGuards))
+
+	for _, stablecoinGuard := range stablecoinGuards {
+		band, err := decOrDefault(stablecoinGuard.Band, defaultDepegBand)
+		if err != nil {
+			return nil, err
+		}
+
+		action := stablecoinGuard.Action
+		if len(action) == 0 {
+			action = config.DepegActionAlert
+		}
+		if _, ok := config.SupportedDepegActions[action]; !ok {
+			return nil, fmt.Errorf("unsupported depeg action: %s", action)
+		}
+
+		guards[stablecoinGuard.Denom] = DepegGuard{Band: band, Action: action}
+	}
+
+	return guards, nil
+}
+
+// impliedRates returns the USD rate of every guarded stablecoin implied by
+// the direct fiat quoted markets, ex. BTC/USD over BTC/USDT for USDT. It is the
+// median, across the bases priced both in USD and in the stablecoin, of the
+// ratio of their median USD price to their median stablecoin price. The price
+// function returns the price of the pair reported by the provider, false if
+// missing.
+func (g DepegGuards) impliedRates(
+	providerPairs map[string][]types.CurrencyPair,
+	price func(providerName string, pair types.CurrencyPair) (math.LegacyDec, bool),
+) map[string]math.LegacyDec {
+	impliedRates := make(map[string]math.LegacyDec, len(g))
+
+	for stablecoin := range g {
+		var (
+			usdPrices        = make(map[string]map[string]math.LegacyDec) // base => provider => price
+			stablecoinPrices = make(map[string]map[string]math.LegacyDec) // base => provider => price
+		)
+		for providerName, pairs := range providerPairs {
+			for _, pair := range pairs {
+				var prices map[string]map[string]math.LegacyDec
+				switch strings.ToUpper(pair.Quote) {
+				case config.DenomUSD:
+					prices = usdPrices
+				case strings.ToUpper(stablecoin):
+					prices = stablecoinPrices
+				default:
+					continue
+				}
+
+				pairPrice, ok := price(providerName, pair)
+				if !ok || !pairPrice.IsPositive() {
+					continue
+				}
+				if _, ok := prices[pair.Base]; !ok {
+					prices[pair.Base] = make(map[string]math.LegacyDec)
+				}
+				prices[pair.Base][providerName] = pairPrice
+			}
+		}
+
+		ratios := make(map[string]math.LegacyDec)
+		for base, basePrices := range stablecoinPrices {
+			if _, ok := usdPrices[base]; !ok {
+				continue
+			}
+			ratios[base] = medianOf(usdPrices[base]).Quo(medianOf(basePrices))
+		}
+		if len(ratios) > 0 {
+			impliedRates[stablecoin] = medianOf(ratios)
+		}
+	}
+
+	return impliedRates
+}
+
+// depegged returns the guards of the stablecoins considered depegged,
+// alerting of every depeg. A stablecoin is depegged if its conversion rate or
+// its rate implied by the fiat quoted markets is outside the band of its peg,
+// or if its conversion rate is outside the band of its implied rate, as when
+// its direct USD quotes are thin or stale. The conversion rate of a guarded
+// stablecoin comes from its direct USD quotes, being the shortest path to USD.
+func (g DepegGuards) depegged(
+	logger zerolog.Logger,
+	priceType string,
+	conversionRates map[string]math.LegacyDec,
+	impliedRates map[string]math.LegacyDec,
+) DepegGuards {
+	depegged := make(DepegGuards)
+
+	for stablecoin, guard := range g {
+		// only the stablecoins quoting other assets are monitored
+		rate, ok := conversionRates[stablecoin]
+		if !ok {
+			continue
+		}
+
+		telemetry.SetGaugeWithLabels([]string{"stablecoin", "rate"}, float32(rate.MustFloat64()), []metrics.Label{
+			{Name: "type", Value: priceType},
+			{Name: "stablecoin", Value: stablecoin},
+		})
+
+		impliedRate, implied := impliedRates[stablecoin]
+		if implied {
+			telemetry.SetGaugeWithLabels([]string{"stablecoin", "implied_rate"}, float32(impliedRate.MustFloat64()), []metrics.Label{
+				{Name: "type", Value: priceType},
+				{Name: "stablecoin", Value: stablecoin},
+			})
+		}
+
+		var reason string
+		switch {
+		case !isBetween(rate, math.LegacyOneDec(), guard.Band):
+			reason = "conversion rate outside the band of its peg"
+		case implied && !isBetween(impliedRate, math.LegacyOneDec(), guard.Band):
+			reason = "implied rate outside the band of its peg"
+		case implied && !isBetween(rate, impliedRate, impliedRate.Mul(guard.Band)):
+			reason = "conversion rate outside the band of its implied rate"
+		default:
+			continue
+		}
+
+		telemetry.IncrCounterWithLabels([]string{"stablecoin", "depeg"}, 1, []metrics.Label{
+			{Name: "type", Value: priceType},
+			{Name: "stablecoin", Value: stablecoin},
+			{Name: "action", Value: guard.Action},
+		})
+		event := logger.Warn().
+			Str("type", priceType).
+			Str("stablecoin", stablecoin).
+			Str("rate", rate.String()).
+			Str("band", guard.Band.String()).
+			Str("action", guard.Action).
+			Str("reason", reason)
+		if implied {
+			event = event.Str("implied_rate", impliedRate.String())
+		}
+		event.Msg("stablecoin outside the band of its peg")
+
+		depegged[stablecoin] = guard
+	}
+
+	return depegged
+}
+
+// blocking returns the first depegged stablecoin on the conversion path of a
+// quote whose guard drops the routes through it, false if there is none.
+func (g DepegGuards) blocking(path []types.CurrencyPair) (string, DepegGuard, bool) {
+	for _, edge := range path {
+		guard, ok := g[edge.Base]
+		if !ok {
+			continue
+		}
+
+		switch guard.Action {
+		case config.DepegActionAlternate, config.DepegActionAbstain:
+			return edge.Base, guard, true
+		}
+	}
+
+	return "", DepegGuard{}, false
+}
+
+// allows returns false if the route of the pair is dropped because the
+// conversion path of its quote goes through a depegged stablecoin, abstaining
+// from voting the base of the pair if the stablecoin abstains.
+func (g DepegGuards) allows(pair types.CurrencyPair, path []types.CurrencyPair, abstentions Abstentions) bool {
+	stablecoin, guard, ok := g.blocking(path)
+	if !ok {
+		return true
+	}
+
+	if guard.Action == config.DepegActionAbstain {
+		abstentions.add(pair.Base, fmt.Sprintf("%s depeg", stablecoin))
+	}
+	return false
+}
+
+// abstainUnrouted abstains from voting the bases whose routes are dropped by
+// the alternate action of a depegged stablecoin when none of their other
+// quotes can be converted to USD, as nothing is left to price them. The paths
+// are the conversion paths of the quotes with a USD rate.
+func (g DepegGuards) abstainUnrouted(
+	providerPairs map[string][]types.CurrencyPair,
+	paths map[string][]types.CurrencyPair,
+	abstentions Abstentions,
+) {
+	var (
+		alternated = make(map[string]string) // base => depegged stablecoin
+		routed     = make(map[string]struct{})
+	)
+	for _, pairs := range providerPairs {
+		for _, pair := range pairs {
+			path, ok := paths[pair.Quote]
+			if !ok && strings.ToUpper(pair.Quote) != config.DenomUSD {
+				continue
+			}
+
+			stablecoin, guard, blocked := g.blocking(path)
+			switch {
+			case !blocked:
+				routed[pair.Base] = struct{}{}
+			case guard.Action == config.DepegActionAlternate:
+				alternated[pair.Base] = stablecoin
+			}
+		}
+	}
+
+	for base, stablecoin := range alternated {
+		if _, ok := routed[base]; !ok {
+			abstentions.add(base, fmt.Sprintf("%s depeg without an alternate quote", stablecoin))
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package oracle
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+func TestNewDepegGuards(t *testing.T) {
+	guards, err := NewDepegGuards([]config.StablecoinGuard{
+		{Denom: "USDT", Band: "0.01", Action: config.DepegActionAbstain},
+		{Denom: "USDC"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, DepegGuards{
+		"USDT": {Band: math.LegacyMustNewDecFromStr("0.01"), Action: config.DepegActionAbstain},
+		"USDC": {Band: defaultDepegBand, Action: config.DepegActionAlert},
+	}, guards)
+
+	_, err = NewDepegGuards([]config.StablecoinGuard{{Denom: "USDT", Action: "pause"}})
+	require.ErrorContains(t, err, "unsupported depeg action: pause")
+}
+
+func TestDepegGuards_Depegged(t *testing.T) {
+	guards := DepegGuards{
+		"USDT": {Band: math.LegacyMustNewDecFromStr("0.02"), Action: config.DepegActionAlert},
+		"USDC": {Band: math.LegacyMustNewDecFromStr("0.02"), Action: config.DepegActionAbstain},
+		"DAI":  {Band: math.LegacyMustNewDecFromStr("0.02"), Action: config.DepegActionAbstain},
+	}
+
+	depegged := guards.depegged(zerolog.Nop(), "ticker", map[string]math.LegacyDec{
+		"USDT": math.LegacyMustNewDecFromStr("0.97"),
+		"USDC": math.LegacyMustNewDecFromStr("1.02"),
+		"BTC":  math.LegacyNewDec(60000),
+	}, map[string]math.LegacyDec{})
+	require.Equal(t, DepegGuards{"USDT": guards["USDT"]}, depegged)
+
+	// the rates implied by the fiat quoted markets detect the depegs missed
+	// by stale conversion rates
+	depegged = guards.depegged(zerolog.Nop(), "ticker", map[string]math.LegacyDec{
+		"USDT": math.LegacyOneDec(),
+		"USDC": math.LegacyOneDec(),
+		"DAI":  math.LegacyMustNewDecFromStr("1.01"),
+	}, map[string]math.LegacyDec{
+		"USDT": math.LegacyMustNewDecFromStr("1.01"),
+		"USDC": math.LegacyMustNewDecFromStr("0.97"),
+		"DAI":  math.LegacyMustNewDecFromStr("0.99"),
+	})
+	require.Equal(t, DepegGuards{"USDC": guards["USDC"], "DAI": guards["DAI"]}, depegged)
+}
+
+func TestDepegGuards_ImpliedRates(t *testing.T) {
+	guards := DepegGuards{
+		"USDT": {Band: defaultDepegBand, Action: config.DepegActionAlert},
+		"USDC": {Band: defaultDepegBand, Action: config.DepegActionAlert},
+	}
+	prices := map[string]map[string]math.LegacyDec{
+		config.ProviderKraken: {
+			"BTCUSD": math.LegacyNewDec(60000),
+			"ETHUSD": math.LegacyNewDec(3000),
+		},
+		config.ProviderCoinbase: {"BTCUSD": math.LegacyNewDec(62000)},
+		config.ProviderBinance: {
+			"BTCUSDT": math.LegacyNewDec(64000),
+			"ETHUSDT": math.LegacyNewDec(3200),
+			"KIIUSDT": math.LegacyOneDec(),
+		},
+	}
+	providerPairs := map[string][]types.CurrencyPair{
+		config.ProviderKraken:   {{Base: "BTC", Quote: "USD"}, {Base: "ETH", Quote: "USD"}},
+		config.ProviderCoinbase: {{Base: "BTC", Quote: "USD"}},
+		config.ProviderBinance: {
+			{Base: "BTC", Quote: "USDT"},
+			{Base: "ETH", Quote: "USDT"},
+			{Base: "KII", Quote: "USDT"},
+		},
+	}
+
+	impliedRates := guards.impliedRates(
+		providerPairs,
+		func(providerName string, pair types.CurrencyPair) (math.LegacyDec, bool) {
+			price, ok := prices[providerName][pair.String()]
+			return price, ok
+		},
+	)
+
+	// median of 61000 / 64000 for BTC and 3000 / 3200 for ETH, USDC has no
+	// base priced in it
+	require.Equal(t, map[string]math.LegacyDec{
+		"USDT": math.LegacyMustNewDecFromStr("0.945312500000000000"),
+	}, impliedRates)
+}
+
+func TestConvertTickersToUSDDepeg(t *testing.T) {
+	atomUSDTPair := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
+	atomUSDPair := types.CurrencyPair{Base: "ATOM", Quote: "USD"}
+	kiiUSDTPair := types.CurrencyPair{Base: "KII", Quote: "USDT"}
+	volume := math.LegacyNewDec(100)
+
+	providerPairs := map[string][]types.CurrencyPair{
+		config.ProviderBinance: {atomUSDTPair, kiiUSDTPair},
+		config.ProviderKraken:  {atomUSDPair},
+		config.ProviderOkx:     {usdtPair},
+	}
+	tickers := func(usdtRate string) provider.AggregatedProviderPrices {
+		return provider.AggregatedProviderPrices{
+			config.ProviderBinance: {
+				atomUSDTPair.String(): {Price: math.LegacyNewDec(10), Volume: volume},
+				kiiUSDTPair.String():  {Price: math.LegacyOneDec(), Volume: volume},
+			},
+			config.ProviderKraken: {atomUSDPair.String(): {Price: math.LegacyNewDec(10), Volume: volume}},
+			config.ProviderOkx:    {usdtPair.String(): {Price: math.LegacyMustNewDecFromStr(usdtRate), Volume: volume}},
+		}
+	}
+	guards := func(action string) DepegGuards {
+		return DepegGuards{"USDT": {Band: math.LegacyMustNewDecFromStr("0.02"), Action: action}}
+	}
+
+	testCases := map[string]struct {
+		tickers             provider.AggregatedProviderPrices
+		guards              DepegGuards
+		expected            map[string]map[string]string
+		expectedAbstentions Abstentions
+	}{
+		"within the band": {
+			tickers: tickers("0.99"),
+			guards:  guards(config.DepegActionAbstain),
+			expected: map[string]map[string]string{
+				config.ProviderBinance: {"ATOM": "9.9", "KII": "0.99"},
+				config.ProviderKraken:  {"ATOM": "10"},
+				config.ProviderOkx:     {"USDT": "0.99"},
+			},
+			expectedAbstentions: Abstentions{},
+		},
+		"alert": {
+			tickers: tickers("0.9"),
+			guards:  guards(config.DepegActionAlert),
+			expected: map[string]map[string]string{
+				config.ProviderBinance: {"ATOM": "9", "KII": "0.9"},
+				config.ProviderKraken:  {"ATOM": "10"},
+				config.ProviderOkx:     {"USDT": "0.9"},
+			},
+			expectedAbstentions: Abstentions{},
+		},
+		"alternate quotes": {
+			tickers: tickers("0.9"),
+			guards:  guards(config.DepegActionAlternate),
+			expected: map[string]map[string]string{
+				config.ProviderKraken: {"ATOM": "10"},
+				config.ProviderOkx:    {"USDT": "0.9"},
+			},
+			expectedAbstentions: Abstentions{"KII": "USDT depeg without an alternate quote"},
+		},
+		"stale conversion rate": {
+			// ATOM/USD over ATOM/USDT implies a USDT rate of 0.8
+			tickers: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {
+					atomUSDTPair.String(): {Price: math.LegacyMustNewDecFromStr("12.5"), Volume: volume},
+					kiiUSDTPair.String():  {Price: math.LegacyOneDec(), Volume: volume},
+				},
+				config.ProviderKraken: {atomUSDPair.String(): {Price: math.LegacyNewDec(10), Volume: volume}},
+				config.ProviderOkx:    {usdtPair.String(): {Price: math.LegacyOneDec(), Volume: volume}},
+			},
+			guards: guards(config.DepegActionAlternate),
+			expected: map[string]map[string]string{
+				config.ProviderKraken: {"ATOM": "10"},
+				config.ProviderOkx:    {"USDT": "1"},
+			},
+			expectedAbstentions: Abstentions{"KII": "USDT depeg without an alternate quote"},
+		},
+		"abstain": {
+			tickers: tickers("0.9"),
+			guards:  guards(config.DepegActionAbstain),
+			expected: map[string]map[string]string{
+				config.ProviderKraken: {},
+				config.ProviderOkx:    {"USDT": "0.9"},
+			},
+			expectedAbstentions: Abstentions{"ATOM": "USDT depeg", "KII": "USDT depeg"},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			convertedTickers, abstentions, err := convertTickersToUSD(
+				zerolog.Nop(),
+				tc.tickers,
+				providerPairs,
+				make(map[string]math.LegacyDec),
+				Aggregation{DepegGuards: tc.guards},
+			)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedAbstentions, abstentions)
+
+			prices := make(map[string]map[string]string, len(convertedTickers))
+			for route, assetTickers := range convertedTickers {
+				prices[route] = make(map[string]string, len(assetTickers))
+				for base, ticker := range assetTickers {
+					prices[route][base] = ticker.Price.String()
+				}
+			}
+			require.Len(t, prices, len(tc.expected))
+			for route, expectedPrices := range tc.expected {
+				require.Len(t, prices[route], len(expectedPrices))
+				for base, price := range expectedPrices {
+					require.Equal(t, math.LegacyMustNewDecFromStr(price).String(), prices[route][base])
+				}
+			}
+		})
+	}
+}
+
+func TestGetComputedPricesDepegAbstain(t *testing.T) {
+	atomUSDTPair := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
+	volume := math.LegacyNewDec(100)
+
+	prices, abstentions, err := GetComputedPrices(
+		zerolog.Nop(),
+		make(provider.AggregatedProviderCandles),
+		provider.AggregatedProviderPrices{
+			config.ProviderBinance: {atomUSDTPair.String(): {Price: math.LegacyNewDec(10), Volume: volume}},
+			config.ProviderOkx:     {usdtPair.String(): {Price: math.LegacyMustNewDecFromStr("0.9"), Volume: volume}},
+		},
+		map[string][]types.CurrencyPair{
+			config.ProviderBinance: {atomUSDTPair},
+			config.ProviderOkx:     {usdtPair},
+		},
+		make(map[string]math.LegacyDec),
+		Aggregation{
+			DepegGuards: DepegGuards{
+				"USDT": {Band: defaultDepegBand, Action: config.DepegActionAbstain},
+			},
+		},
+		map[string]struct{}{"ATOM": {}, "USDT": {}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, map[string]math.LegacyDec{"USDT": math.LegacyMustNewDecFromStr("0.9")}, prices)
+	require.Equal(t, Abstentions{"ATOM": "USDT depeg"}, abstentions)
+}
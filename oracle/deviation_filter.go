@@ -0,0 +1,298 @@
+package oracle
+
+import (
+	"fmt"
+	"sort"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+var (
+	// defaultMADThreshold defines how many scaled MADs a provider can be away
+	// from the median without being considered faulty.
+	defaultMADThreshold = math.LegacyNewDec(3)
+
+	// defaultBand defines the fraction of the median a provider can be away
+	// from the median without being considered faulty.
+	defaultBand = math.LegacyMustNewDecFromStr("0.05")
+
+	// madScale scales the MAD into a consistent estimator of 𝜎 for normally
+	// distributed prices.
+	madScale = math.LegacyMustNewDecFromStr("1.4826")
+)
+
+var (
+	_ DeviationFilter = StdDevFilter{}
+	_ DeviationFilter = MADFilter{}
+	_ DeviationFilter = BandFilter{}
+	_ DeviationFilter = VolatilityFilter{}
+)
+
+type (
+	// DeviationFilter defines how the providers whose price deviates from the
+	// prices of the other providers of a base are filtered out.
+	DeviationFilter interface {
+		// Filter returns the accepted providers given the prices by provider.
+		Filter(prices map[string]math.LegacyDec) (map[string]struct{}, error)
+	}
+
+	// StdDevFilter accepts the prices within Threshold 𝜎 of the mean. With
+	// less than three prices every price is accepted. A nil Threshold uses the
+	// deviation threshold of the base.
+	StdDevFilter struct {
+		Threshold math.LegacyDec
+	}
+
+	// MADFilter accepts the prices within Threshold scaled median absolute
+	// deviations of the median. With less than three prices every price is
+	// accepted, and if most prices are equal to the median, so the MAD is
+	// zero, the prices within the Band of the median are accepted.
+	MADFilter struct {
+		Threshold math.LegacyDec
+		Band      math.LegacyDec
+	}
+
+	// BandFilter accepts the prices within the Band fraction of the median,
+	// ex. 0.05 accepts the prices within 5% of the median.
+	BandFilter struct {
+		Band math.LegacyDec
+	}
+
+	// VolatilityFilter accepts the prices within Multiplier times the
+	// realized Volatility of the mean, the margin being bounded by the Floor
+	// and the Ceiling 𝜎 of the mean. With less than three prices every price
+	// is accepted.
+	VolatilityFilter struct {
+		Volatility math.LegacyDec
+		Multiplier math.LegacyDec
+		Floor      math.LegacyDec
+		Ceiling    math.LegacyDec
+	}
+)
+
+// NewDeviationFilter returns the deviation filter by its name, the
+// StdDevFilter using the deviation thresholds if empty.
+func NewDeviationFilter(name, madThreshold, band string) (DeviationFilter, error) {
+	bandDec, err := decOrDefault(band, defaultBand)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "", config.FilterStdDev:
+		return StdDevFilter{}, nil
+
+	case config.FilterMAD:
+		threshold, err := decOrDefault(madThreshold, defaultMADThreshold)
+		if err != nil {
+			return nil, err
+		}
+		return MADFilter{Threshold: threshold, Band: bandDec}, nil
+
+	case config.FilterBand:
+		return BandFilter{Band: bandDec}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported deviation filter: %s", name)
+	}
+}
+
+// NewTwoSourceFilter returns the filter of the bases priced by only two
+// providers by its policy, nil if both prices are kept.
+func NewTwoSourceFilter(policy, band string) (DeviationFilter, error) {
+	switch policy {
+	case "", config.TwoSourcePolicyBand:
+		bandDec, err := decOrDefault(band, defaultBand)
+		if err != nil {
+			return nil, err
+		}
+		return BandFilter{Band: bandDec}, nil
+
+	case config.TwoSourcePolicyKeep:
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported two source policy: %s", policy)
+	}
+}
+
+// Filter accepts the prices within Threshold 𝜎 of the mean.
+func (f StdDevFilter) Filter(prices map[string]math.LegacyDec) (map[string]struct{}, error) {
+	if len(prices) < 3 {
+		return acceptAll(prices), nil
+	}
+
+	// compute the 𝜎 of the single base
+	const base = ""
+	providerPrices := make(map[string]map[string]math.LegacyDec, len(prices))
+	for providerName, price := range prices {
+		providerPrices[providerName] = map[string]math.LegacyDec{base: price}
+	}
+	deviations, means, err := StandardDeviation(providerPrices)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := make(map[string]struct{}, len(prices))
+	for providerName, price := range prices {
+		if isBetween(price, means[base], deviations[base].Mul(f.Threshold)) {
+			accepted[providerName] = struct{}{}
+		}
+	}
+
+	return accepted, nil
+}
+
+// Filter accepts the prices within Threshold scaled MADs of the median.
+func (f MADFilter) Filter(prices map[string]math.LegacyDec) (map[string]struct{}, error) {
+	if len(prices) < 3 {
+		return acceptAll(prices), nil
+	}
+
+	median := medianOf(prices)
+	absoluteDeviations := make(map[string]math.LegacyDec, len(prices))
+	for providerName, price := range prices {
+		absoluteDeviations[providerName] = price.Sub(median).Abs()
+	}
+	mad := medianOf(absoluteDeviations)
+
+	margin := mad.Mul(madScale).Mul(f.Threshold)
+	if mad.IsZero() {
+		margin = median.Mul(f.Band)
+	}
+
+	accepted := make(map[string]struct{}, len(prices))
+	for providerName, price := range prices {
+		if isBetween(price, median, margin) {
+			accepted[providerName] = struct{}{}
+		}
+	}
+
+	return accepted, nil
+}
+
+// Filter accepts the prices within the Band fraction of the median.
+func (f BandFilter) Filter(prices map[string]math.LegacyDec) (map[string]struct{}, error) {
+	median := medianOf(prices)
+	margin := median.Mul(f.Band)
+
+	accepted := make(map[string]struct{}, len(prices))
+	for providerName, price := range prices {
+		if isBetween(price, median, margin) {
+			accepted[providerName] = struct{}{}
+		}
+	}
+
+	return accepted, nil
+}
+
+// Filter accepts the prices within Multiplier realized volatilities of the
+// mean, bounded by the Floor and the Ceiling 𝜎.
+func (f VolatilityFilter) Filter(prices map[string]math.LegacyDec) (map[string]struct{}, error) {
+	if len(prices) < 3 {
+		return acceptAll(prices), nil
+	}
+
+	// compute the 𝜎 of the single base
+	const base = ""
+	providerPrices := make(map[string]map[string]math.LegacyDec, len(prices))
+	for providerName, price := range prices {
+		providerPrices[providerName] = map[string]math.LegacyDec{base: price}
+	}
+	deviations, means, err := StandardDeviation(providerPrices)
+	if err != nil {
+		return nil, err
+	}
+
+	margin := means[base].Mul(f.Volatility).Mul(f.Multiplier)
+	if floor := deviations[base].Mul(f.Floor); margin.LT(floor) {
+		margin = floor
+	}
+	if ceiling := deviations[base].Mul(f.Ceiling); margin.GT(ceiling) {
+		margin = ceiling
+	}
+
+	accepted := make(map[string]struct{}, len(prices))
+	for providerName, price := range prices {
+		if isBetween(price, means[base], margin) {
+			accepted[providerName] = struct{}{}
+		}
+	}
+
+	return accepted, nil
+}
+
+// filter returns the deviation filter of the base, the StdDevFilter with the
+// deviation threshold of the base by default, or the VolatilityFilter if the
+// deviation thresholds are adaptive and the realized volatility of the base
+// is known.
+func (a Aggregation) filter(base string, deviationThresholds map[string]math.LegacyDec) DeviationFilter {
+	filter, ok := a.Filters[base]
+	if !ok {
+		filter = a.Filter
+	}
+
+	if stdDevFilter, ok := filter.(StdDevFilter); filter == nil || (ok && stdDevFilter.Threshold.IsNil()) {
+		threshold := defaultDeviationThreshold
+		if t, ok := deviationThresholds[base]; ok {
+			threshold = t
+		}
+
+		if volatility, ok := a.Volatilities[base]; ok && a.AdaptiveDeviation != nil {
+			return a.AdaptiveDeviation.filter(threshold, volatility)
+		}
+		return StdDevFilter{Threshold: threshold}
+	}
+
+	return filter
+}
+
+// twoSourceFilter returns the filter of the base when priced by only two
+// providers, nil if both prices are kept.
+func (a Aggregation) twoSourceFilter(base string) DeviationFilter {
+	if filter, ok := a.TwoSourceFilters[base]; ok {
+		return filter
+	}
+	return a.TwoSourceFilter
+}
+
+// acceptAll returns every provider of the prices.
+func acceptAll(prices map[string]math.LegacyDec) map[string]struct{} {
+	accepted := make(map[string]struct{}, len(prices))
+	for providerName := range prices {
+		accepted[providerName] = struct{}{}
+	}
+	return accepted
+}
+
+// medianOf returns the median of the prices, the mean of the two middle
+// prices if their amount is even.
+func medianOf(prices map[string]math.LegacyDec) math.LegacyDec {
+	if len(prices) == 0 {
+		return math.LegacyZeroDec()
+	}
+
+	sorted := make([]math.LegacyDec, 0, len(prices))
+	for _, price := range prices {
+		sorted = append(sorted, price)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].LT(sorted[j])
+	})
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return sorted[middle-1].Add(sorted[middle]).QuoInt64(2)
+	}
+	return sorted[middle]
+}
+
+// decOrDefault parses the decimal, returning the default value if empty.
+func decOrDefault(value string, defaultValue math.LegacyDec) (math.LegacyDec, error) {
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+	return math.LegacyNewDecFromStr(value)
+}
@@ -0,0 +1,203 @@
+package oracle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+func TestDeviationFilters(t *testing.T) {
+	prices := func(values ...string) map[string]math.LegacyDec {
+		providers := []string{
+			config.ProviderBinance,
+			config.ProviderKraken,
+			config.ProviderOkx,
+			config.ProviderHuobi,
+			config.ProviderGate,
+		}
+
+		prices := make(map[string]math.LegacyDec, len(values))
+		for i, value := range values {
+			prices[providers[i]] = math.LegacyMustNewDecFromStr(value)
+		}
+		return prices
+	}
+
+	testCases := map[string]struct {
+		filter   DeviationFilter
+		prices   map[string]math.LegacyDec
+		expected []string
+	}{
+		"stddev outlier inflating the deviation": {
+			filter:   StdDevFilter{Threshold: math.LegacyNewDec(2)},
+			prices:   prices("10", "11", "100"),
+			expected: []string{config.ProviderBinance, config.ProviderKraken, config.ProviderOkx},
+		},
+		"stddev outlier": {
+			filter:   StdDevFilter{Threshold: math.LegacyOneDec()},
+			prices:   prices("10", "11", "100"),
+			expected: []string{config.ProviderBinance, config.ProviderKraken},
+		},
+		"stddev two sources": {
+			filter:   StdDevFilter{Threshold: math.LegacyOneDec()},
+			prices:   prices("10", "100"),
+			expected: []string{config.ProviderBinance, config.ProviderKraken},
+		},
+		"mad outlier": {
+			filter:   MADFilter{Threshold: math.LegacyNewDec(3), Band: defaultBand},
+			prices:   prices("10", "11", "100"),
+			expected: []string{config.ProviderBinance, config.ProviderKraken},
+		},
+		"mad within threshold": {
+			filter:   MADFilter{Threshold: math.LegacyNewDec(3), Band: defaultBand},
+			prices:   prices("10", "11", "12", "14"),
+			expected: []string{config.ProviderBinance, config.ProviderKraken, config.ProviderOkx, config.ProviderHuobi},
+		},
+		"mad zero uses the band": {
+			filter:   MADFilter{Threshold: math.LegacyNewDec(3), Band: defaultBand},
+			prices:   prices("10", "10", "10", "10.2", "11"),
+			expected: []string{config.ProviderBinance, config.ProviderKraken, config.ProviderOkx, config.ProviderHuobi},
+		},
+		"mad two sources": {
+			filter:   MADFilter{Threshold: math.LegacyNewDec(3), Band: defaultBand},
+			prices:   prices("10", "100"),
+			expected: []string{config.ProviderBinance, config.ProviderKraken},
+		},
+		"band outlier": {
+			filter:   BandFilter{Band: defaultBand},
+			prices:   prices("10", "10.4", "11"),
+			expected: []string{config.ProviderBinance, config.ProviderKraken},
+		},
+		"band two sources within the band": {
+			filter:   BandFilter{Band: defaultBand},
+			prices:   prices("10", "10.5"),
+			expected: []string{config.ProviderBinance, config.ProviderKraken},
+		},
+		"band two sources outside the band": {
+			filter:   BandFilter{Band: defaultBand},
+			prices:   prices("10", "12"),
+			expected: []string{},
+		},
+		"band single source": {
+			filter:   BandFilter{Band: defaultBand},
+			prices:   prices("10"),
+			expected: []string{config.ProviderBinance},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			accepted, err := tc.filter.Filter(tc.prices)
+			require.NoError(t, err)
+
+			expected := make(map[string]struct{}, len(tc.expected))
+			for _, providerName := range tc.expected {
+				expected[providerName] = struct{}{}
+			}
+			require.Equal(t, expected, accepted)
+		})
+	}
+}
+
+func TestAggregation_FilterDeviations(t *testing.T) {
+	prices := map[string]map[string]math.LegacyDec{
+		config.ProviderBinance: {"ATOM": math.LegacyNewDec(10), "KII": math.LegacyNewDec(10), "BTC": math.LegacyNewDec(10)},
+		config.ProviderKraken:  {"ATOM": math.LegacyNewDec(11), "KII": math.LegacyNewDec(12), "BTC": math.LegacyNewDec(12)},
+		config.ProviderOkx:     {"ATOM": math.LegacyNewDec(100)},
+	}
+
+	testCases := map[string]struct {
+		aggregation Aggregation
+		expected    map[string]map[string]struct{}
+	}{
+		"default filters": {
+			aggregation: Aggregation{},
+			expected: map[string]map[string]struct{}{
+				"ATOM": {config.ProviderBinance: {}, config.ProviderKraken: {}},
+				"KII":  {config.ProviderBinance: {}, config.ProviderKraken: {}},
+				"BTC":  {config.ProviderBinance: {}, config.ProviderKraken: {}},
+			},
+		},
+		"two source band": {
+			aggregation: Aggregation{
+				Filter:          MADFilter{Threshold: defaultMADThreshold, Band: defaultBand},
+				TwoSourceFilter: BandFilter{Band: math.LegacyMustNewDecFromStr("0.05")},
+			},
+			expected: map[string]map[string]struct{}{
+				"ATOM": {config.ProviderBinance: {}, config.ProviderKraken: {}},
+				"KII":  {},
+				"BTC":  {},
+			},
+		},
+		"two source policy by base": {
+			aggregation: Aggregation{
+				TwoSourceFilter: BandFilter{Band: math.LegacyMustNewDecFromStr("0.05")},
+				TwoSourceFilters: map[string]DeviationFilter{
+					"KII": nil,
+					"BTC": BandFilter{Band: math.LegacyMustNewDecFromStr("0.1")},
+				},
+			},
+			expected: map[string]map[string]struct{}{
+				"ATOM": {config.ProviderBinance: {}, config.ProviderKraken: {}},
+				"KII":  {config.ProviderBinance: {}, config.ProviderKraken: {}},
+				"BTC":  {config.ProviderBinance: {}, config.ProviderKraken: {}},
+			},
+		},
+		"filter by base": {
+			aggregation: Aggregation{
+				Filters: map[string]DeviationFilter{
+					"ATOM": StdDevFilter{Threshold: math.LegacyNewDec(2)},
+				},
+			},
+			expected: map[string]map[string]struct{}{
+				"ATOM": {config.ProviderBinance: {}, config.ProviderKraken: {}, config.ProviderOkx: {}},
+				"KII":  {config.ProviderBinance: {}, config.ProviderKraken: {}},
+				"BTC":  {config.ProviderBinance: {}, config.ProviderKraken: {}},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			accepted, err := tc.aggregation.filterDeviations(prices, map[string]math.LegacyDec{})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, accepted)
+		})
+	}
+}
+
+func TestNewDeviationFilter(t *testing.T) {
+	filter, err := NewDeviationFilter(config.FilterMAD, "2.5", "")
+	require.NoError(t, err)
+	require.Equal(t, MADFilter{Threshold: math.LegacyMustNewDecFromStr("2.5"), Band: defaultBand}, filter)
+
+	filter, err = NewDeviationFilter(config.FilterBand, "", "0.01")
+	require.NoError(t, err)
+	require.Equal(t, BandFilter{Band: math.LegacyMustNewDecFromStr("0.01")}, filter)
+
+	filter, err = NewDeviationFilter("", "", "")
+	require.NoError(t, err)
+	require.Equal(t, StdDevFilter{}, filter)
+
+	_, err = NewDeviationFilter("iqr", "", "")
+	require.ErrorContains(t, err, "unsupported deviation filter: iqr")
+
+	filter, err = NewTwoSourceFilter(config.TwoSourcePolicyKeep, "")
+	require.NoError(t, err)
+	require.Nil(t, filter)
+
+	filter, err = NewTwoSourceFilter("", "")
+	require.NoError(t, err)
+	require.Equal(t, BandFilter{Band: defaultBand}, filter)
+
+	_, err = NewTwoSourceFilter("drop", "")
+	require.ErrorContains(t, err, "unsupported two source policy: drop")
+}
@@ -0,0 +1,95 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	oracletypes "github.com/kiichain/kiichain/v3/x/oracle/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	sdkmath "cosmossdk.io/math"
+)
+
+// ExchangeRateCache is used to cache the on-chain exchange rates during a
+// vote period, since they are only updated at the end of the vote periods.
+type ExchangeRateCache struct {
+	rates      map[string]sdkmath.LegacyDec // map with the exchange rates by chain denom
+	votePeriod int64
+}
+
+// Update updates the instance with the exchange rates of the vote period.
+func (exchangeRateCache *ExchangeRateCache) Update(votePeriod int64, rates map[string]sdkmath.LegacyDec) {
+	exchangeRateCache.votePeriod = votePeriod
+	exchangeRateCache.rates = rates
+}
+
+// IsOutdated checks whether or not the exchange rates were fetched during
+// another vote period.
+func (exchangeRateCache *ExchangeRateCache) IsOutdated(votePeriod int64) bool {
+	return exchangeRateCache.rates == nil || exchangeRateCache.votePeriod != votePeriod
+}
+
+// GetCachedExchangeRates returns the on-chain exchange rates of the vote
+// period of the block, querying them again once per vote period.
+func (o *Oracle) GetCachedExchangeRates(
+	ctx context.Context,
+	currentBlockHeight int64,
+	votePeriodBlocks uint64,
+) (map[string]sdkmath.LegacyDec, error) {
+	if votePeriodBlocks == 0 {
+		return nil, fmt.Errorf("expected positive vote period")
+	}
+
+	votePeriod := currentBlockHeight / int64(votePeriodBlocks)
+	if !o.exchangeRateCache.IsOutdated(votePeriod) {
+		return o.exchangeRateCache.rates, nil
+	}
+
+	rates, err := o.GetExchangeRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	o.exchangeRateCache.Update(votePeriod, rates)
+	return rates, nil
+}
+
+// GetExchangeRates returns the current on-chain exchange rates of the x/oracle
+// module by chain denom.
+func (o *Oracle) GetExchangeRates(ctx context.Context) (map[string]sdkmath.LegacyDec, error) {
+	// create the connection with the blockchain
+	grpcConn, err := grpc.NewClient(
+		o.oracleClient.GRPCEndpoint,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(dialerFunc),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial Cosmos gRPC service: %w", err)
+	}
+
+	defer grpcConn.Close()
+
+	// create oracle query client
+	queryClient := oracletypes.NewQueryClient(grpcConn)
+
+	// create context with timeout
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	// query oracle module's exchange rates
+	queryResponse, err := queryClient.ExchangeRates(ctx, &oracletypes.QueryExchangeRatesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get x/oracle exchange rates: %w", err)
+	}
+
+	rates := make(map[string]sdkmath.LegacyDec, len(queryResponse.DenomOracleExchangeRate))
+	for _, denomRate := range queryResponse.DenomOracleExchangeRate {
+		if denomRate.OracleExchangeRate != nil {
+			rates[denomRate.Denom] = denomRate.OracleExchangeRate.ExchangeRate
+		}
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,159 @@
+package oracle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-metrics"
+	"github.com/rs/zerolog"
+
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+// fallbackPersistDivisor defines the fraction of the max age between two
+// writes of the last known good prices, so the prices loaded after a restart
+// keep most of their max age without writing the file on every tick.
+const fallbackPersistDivisor = 4
+
+type (
+	// KnownGoodPrice is the last price of an asset computed from the live
+	// prices of its providers.
+	KnownGoodPrice struct {
+		Price     math.LegacyDec `json:"price"`
+		Timestamp time.Time      `json:"timestamp"`
+		Providers []string       `json:"providers"`
+	}
+
+	// PriceFallback keeps the last known good price of every asset, reused
+	// when the live price of the asset can not be computed if it is not older
+	// than MaxAge. The prices are persisted to the Path file if set, at most
+	// once every PersistInterval.
+	PriceFallback struct {
+		MaxAge          time.Duration
+		Path            string
+		PersistInterval time.Duration
+
+		prices      map[string]KnownGoodPrice
+		persistedAt time.Time // time of the last write of the prices
+	}
+)
+
+// NewPriceFallback returns the price fallback of the config, nil if disabled,
+// loading the prices persisted by the previous run.
+func NewPriceFallback(fallback config.Fallback) (*PriceFallback, error) {
+	if len(fallback.MaxAge) == 0 {
+		return nil, nil
+	}
+
+	maxAge, err := time.ParseDuration(fallback.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	priceFallback := &PriceFallback{
+		MaxAge:          maxAge,
+		Path:            fallback.Path,
+		PersistInterval: maxAge / fallbackPersistDivisor,
+		prices:          make(map[string]KnownGoodPrice),
+	}
+	if len(priceFallback.Path) == 0 {
+		return priceFallback, nil
+	}
+
+	bz, err := os.ReadFile(priceFallback.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return priceFallback, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the fallback prices: %w", err)
+	}
+	if err := json.Unmarshal(bz, &priceFallback.prices); err != nil {
+		return nil, fmt.Errorf("failed to decode the fallback prices: %w", err)
+	}
+
+	return priceFallback, nil
+}
+
+// Update saves the live prices as the last known good prices, with their
+// contributing providers, persisting them if the fallback has a path and the
+// persist interval has elapsed since the last write. A failed write is
+// retried on the next update.
+func (f *PriceFallback) Update(prices map[string]math.LegacyDec, sources map[string][]string, now time.Time) error {
+	for base, price := range prices {
+		f.prices[base] = KnownGoodPrice{
+			Price:     price,
+			Timestamp: now,
+			Providers: sources[base],
+		}
+	}
+
+	if len(f.Path) == 0 || len(prices) == 0 || now.Sub(f.persistedAt) < f.PersistInterval {
+		return nil
+	}
+	if err := f.persist(); err != nil {
+		return err
+	}
+
+	f.persistedAt = now
+	return nil
+}
+
+// Price returns the last known good price of the base if it is not older
+// than the max age, alerting of its reuse.
+func (f *PriceFallback) Price(logger zerolog.Logger, base string, now time.Time) (math.LegacyDec, bool) {
+	knownGoodPrice, ok := f.prices[base]
+	if !ok {
+		return math.LegacyDec{}, false
+	}
+
+	age := now.Sub(knownGoodPrice.Timestamp)
+	if age > f.MaxAge {
+		return math.LegacyDec{}, false
+	}
+
+	telemetry.IncrCounterWithLabels([]string{"price", "fallback"}, 1, []metrics.Label{
+		{Name: "base", Value: base},
+	})
+	logger.Warn().
+		Str("base", base).
+		Str("price", knownGoodPrice.Price.String()).
+		Dur("age", age).
+		Strs("providers", knownGoodPrice.Providers).
+		Bool("fallback", true).
+		Msg("voting the last known good price")
+
+	return knownGoodPrice.Price, true
+}
+
+// persist writes the last known good prices to the path of the fallback,
+// through a temporary file so a crash does not leave a partial file.
+func (f *PriceFallback) persist() error {
+	bz, err := json.Marshal(f.prices)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(bz); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), f.Path)
+}
@@ -0,0 +1,87 @@
+package oracle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+func TestPriceFallback_Price(t *testing.T) {
+	priceFallback, err := NewPriceFallback(config.Fallback{MaxAge: "1m"})
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, priceFallback.Update(
+		map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)},
+		map[string][]string{"ATOM": {config.ProviderBinance, config.ProviderKraken}},
+		now,
+	))
+
+	price, ok := priceFallback.Price(zerolog.Nop(), "ATOM", now.Add(time.Minute))
+	require.True(t, ok)
+	require.Equal(t, math.LegacyNewDec(10), price)
+
+	// the prices older than the max age are not reused
+	_, ok = priceFallback.Price(zerolog.Nop(), "ATOM", now.Add(time.Minute+time.Second))
+	require.False(t, ok)
+
+	_, ok = priceFallback.Price(zerolog.Nop(), "KII", now)
+	require.False(t, ok)
+
+	priceFallback, err = NewPriceFallback(config.Fallback{})
+	require.NoError(t, err)
+	require.Nil(t, priceFallback)
+}
+
+func TestPriceFallback_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+
+	priceFallback, err := NewPriceFallback(config.Fallback{MaxAge: "1m", Path: path})
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, priceFallback.Update(
+		map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)},
+		map[string][]string{"ATOM": {config.ProviderBinance}},
+		now,
+	))
+
+	// the prices are loaded after a restart
+	restarted, err := NewPriceFallback(config.Fallback{MaxAge: "1m", Path: path})
+	require.NoError(t, err)
+	require.Equal(t, map[string]KnownGoodPrice{
+		"ATOM": {Price: math.LegacyNewDec(10), Timestamp: now, Providers: []string{config.ProviderBinance}},
+	}, restarted.prices)
+
+	// the prices are written at most once every quarter of the max age
+	require.Equal(t, 15*time.Second, priceFallback.PersistInterval)
+	for _, tick := range []struct {
+		elapsed  time.Duration
+		expected time.Time
+	}{
+		{elapsed: 10 * time.Second, expected: now},
+		{elapsed: 15 * time.Second, expected: now.Add(15 * time.Second)},
+	} {
+		require.NoError(t, priceFallback.Update(
+			map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(11)},
+			map[string][]string{"ATOM": {config.ProviderBinance}},
+			now.Add(tick.elapsed),
+		))
+
+		restarted, err = NewPriceFallback(config.Fallback{MaxAge: "1m", Path: path})
+		require.NoError(t, err)
+		require.Equal(t, tick.expected, restarted.prices["ATOM"].Timestamp)
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err = NewPriceFallback(config.Fallback{MaxAge: "1m", Path: path})
+	require.ErrorContains(t, err, "failed to decode the fallback prices")
+}
@@ -16,12 +16,14 @@ import (
 // in the config.
 var defaultDeviationThreshold = math.LegacyMustNewDecFromStr("1.0")
 
-// FilterTickerDeviations finds the standard deviations of the prices of
-// all assets, and filters out any providers that are not within 2𝜎 of the mean.
+// FilterTickerDeviations filters out any providers whose price deviates from
+// the prices of the other providers, using the deviation filter of every asset
+// (the standard deviations by default).
 func FilterTickerDeviations(
 	logger zerolog.Logger,
 	prices provider.AggregatedProviderPrices,
 	deviationThresholds map[string]math.LegacyDec,
+	aggregation Aggregation,
 ) (provider.AggregatedProviderPrices, error) {
 	var (
 		filteredPrices = make(provider.AggregatedProviderPrices)
@@ -39,22 +41,14 @@ func FilterTickerDeviations(
 		}
 	}
 
-	deviations, means, err := StandardDeviation(priceMap)
+	accepted, err := aggregation.filterDeviations(priceMap, deviationThresholds)
 	if err != nil {
 		return nil, err
 	}
 
-	// We accept any prices that are within (2 * T)𝜎, or for which we couldn't get 𝜎.
-	// T is defined as the deviation threshold, either set by the config
-	// or defaulted to 1.
 	for providerName, priceTickers := range prices {
 		for base, tp := range priceTickers {
-			t := defaultDeviationThreshold
-			if _, ok := deviationThresholds[base]; ok {
-				t = deviationThresholds[base]
-			}
-
-			if d, ok := deviations[base]; !ok || isBetween(tp.Price, means[base], d.Mul(t)) {
+			if _, ok := accepted[base][providerName]; ok {
 				p, ok := filteredPrices[providerName]
 				if !ok {
 					p = map[string]provider.TickerPrice{}
@@ -80,12 +74,14 @@ func FilterTickerDeviations(
 	return filteredPrices, nil
 }
 
-// FilterCandleDeviations finds the standard deviations of the tvwaps of
-// all assets, and filters out any providers that are not within 2𝜎 of the mean.
+// FilterCandleDeviations filters out any providers whose tvwap deviates from
+// the tvwaps of the other providers, using the deviation filter of every asset
+// (the standard deviations by default).
 func FilterCandleDeviations(
 	logger zerolog.Logger,
 	candles provider.AggregatedProviderCandles,
 	deviationThresholds map[string]math.LegacyDec,
+	aggregation Aggregation,
 ) (provider.AggregatedProviderCandles, error) {
 	var (
 		filteredCandles = make(provider.AggregatedProviderCandles)
@@ -104,7 +100,7 @@ func FilterCandleDeviations(
 			p[base] = cp
 		}
 
-		tvwap, err := ComputeTVWAP(candlePrices)
+		tvwap, err := ComputeTVWAP(candlePrices, ProviderWeights{})
 		if err != nil {
 			return nil, err
 		}
@@ -118,22 +114,14 @@ func FilterCandleDeviations(
 		}
 	}
 
-	deviations, means, err := StandardDeviation(tvwaps)
+	accepted, err := aggregation.filterDeviations(tvwaps, deviationThresholds)
 	if err != nil {
 		return nil, err
 	}
 
-	// We accept any prices that are within (2 * T)𝜎, or for which we couldn't get 𝜎.
-	// T is defined as the deviation threshold, either set by the config
-	// or defaulted to 1.
 	for providerName, priceMap := range tvwaps {
 		for base, price := range priceMap {
-			t := defaultDeviationThreshold
-			if _, ok := deviationThresholds[base]; ok {
-				t = deviationThresholds[base]
-			}
-
-			if d, ok := deviations[base]; !ok || isBetween(price, means[base], d.Mul(t)) {
+			if _, ok := accepted[base][providerName]; ok {
 				p, ok := filteredCandles[providerName]
 				if !ok {
 					p = map[string][]provider.CandlePrice{}
@@ -159,6 +147,42 @@ func FilterCandleDeviations(
 	return filteredCandles, nil
 }
 
+// filterDeviations returns the providers accepted by the deviation filter of
+// every base, given the prices by provider and base. When only two providers
+// price a base, their prices are checked by the two-source filter instead,
+// since two prices deviate equally from their mean and median.
+func (a Aggregation) filterDeviations(
+	prices map[string]map[string]math.LegacyDec,
+	deviationThresholds map[string]math.LegacyDec,
+) (map[string]map[string]struct{}, error) {
+	basePrices := make(map[string]map[string]math.LegacyDec)
+	for providerName, providerPrices := range prices {
+		for base, price := range providerPrices {
+			if _, ok := basePrices[base]; !ok {
+				basePrices[base] = make(map[string]math.LegacyDec)
+			}
+			basePrices[base][providerName] = price
+		}
+	}
+
+	accepted := make(map[string]map[string]struct{}, len(basePrices))
+	for base, providerPrices := range basePrices {
+		filter := a.filter(base, deviationThresholds)
+		if twoSourceFilter := a.twoSourceFilter(base); len(providerPrices) == 2 && twoSourceFilter != nil {
+			filter = twoSourceFilter
+		}
+
+		providers, err := filter.Filter(providerPrices)
+		if err != nil {
+			return nil, err
+		}
+		accepted[base] = providers
+	}
+
+	return accepted, nil
+}
+
+// isBetween returns true if the price is within the margin of the mean.
 func isBetween(p, mean, margin math.LegacyDec) bool {
 	return p.GTE(mean.Sub(margin)) &&
 		p.LTE(mean.Add(margin))
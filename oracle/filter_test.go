@@ -55,6 +55,7 @@ func TestSuccessFilterCandleDeviations(t *testing.T) {
 		zerolog.Nop(),
 		providerCandles,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 	)
 
 	_, ok := pricesFiltered[config.ProviderCoinbase]
@@ -68,6 +69,7 @@ func TestSuccessFilterCandleDeviations(t *testing.T) {
 		zerolog.Nop(),
 		providerCandles,
 		customDeviations,
+		Aggregation{},
 	)
 
 	_, ok = pricesFilteredCustom[config.ProviderCoinbase]
@@ -110,6 +112,7 @@ func TestSuccessFilterTickerDeviations(t *testing.T) {
 		zerolog.Nop(),
 		providerTickers,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 	)
 
 	_, ok := pricesFiltered[config.ProviderCoinbase]
@@ -123,6 +126,7 @@ func TestSuccessFilterTickerDeviations(t *testing.T) {
 		zerolog.Nop(),
 		providerTickers,
 		customDeviations,
+		Aggregation{},
 	)
 
 	_, ok = pricesFilteredCustom[config.ProviderCoinbase]
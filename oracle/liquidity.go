@@ -0,0 +1,138 @@
+package oracle
+
+import (
+	"sort"
+
+	"github.com/hashicorp/go-metrics"
+	"github.com/rs/zerolog"
+
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+)
+
+// LiquidityFilter excludes the provider routes of an asset whose 24h volume
+// in USD is below the minimum volume of the asset, so the tiny-volume venues
+// neither drive the deviation filters nor the aggregation of the asset.
+type LiquidityFilter struct {
+	// MinVolume is the minimum 24h USD volume of the assets without their
+	// own minimum, disabled if nil
+	MinVolume *math.LegacyDec
+	// MinVolumes are the minimum 24h USD volumes by asset
+	MinVolumes map[string]math.LegacyDec
+}
+
+// NewLiquidityFilter returns the liquidity filter of the aggregation config.
+func NewLiquidityFilter(aggregationConfig config.Aggregation) (LiquidityFilter, error) {
+	liquidityFilter := LiquidityFilter{
+		MinVolumes: make(map[string]math.LegacyDec, len(aggregationConfig.Assets)),
+	}
+
+	if len(aggregationConfig.MinVolume) > 0 {
+		minVolume, err := math.LegacyNewDecFromStr(aggregationConfig.MinVolume)
+		if err != nil {
+			return LiquidityFilter{}, err
+		}
+		liquidityFilter.MinVolume = &minVolume
+	}
+	for _, assetAggregation := range aggregationConfig.Assets {
+		if len(assetAggregation.MinVolume) == 0 {
+			continue
+		}
+		minVolume, err := math.LegacyNewDecFromStr(assetAggregation.MinVolume)
+		if err != nil {
+			return LiquidityFilter{}, err
+		}
+		liquidityFilter.MinVolumes[assetAggregation.Base] = minVolume
+	}
+
+	return liquidityFilter, nil
+}
+
+// enabled returns whether any asset has a minimum volume.
+func (f LiquidityFilter) enabled() bool {
+	return f.MinVolume != nil || len(f.MinVolumes) > 0
+}
+
+// minVolume returns the minimum volume of the base, false if it has none.
+func (f LiquidityFilter) minVolume(base string) (math.LegacyDec, bool) {
+	if minVolume, ok := f.MinVolumes[base]; ok {
+		return minVolume, true
+	}
+	if f.MinVolume != nil {
+		return *f.MinVolume, true
+	}
+	return math.LegacyDec{}, false
+}
+
+// illiquidRoutes returns the bases of every route whose 24h USD volume, the
+// volume of its USD converted ticker times its price, is below the minimum
+// volume of the base, alerting of their exclusion. The routes without a
+// ticker are kept, their volume being unknown, as are the routes of the index
+// providers, whose volume is synthetic.
+func (f LiquidityFilter) illiquidRoutes(
+	logger zerolog.Logger,
+	tickers provider.AggregatedProviderPrices,
+) map[string][]string {
+	illiquid := make(map[string][]string)
+	if !f.enabled() {
+		return illiquid
+	}
+
+	for route, assetTickers := range tickers {
+		if _, ok := config.SupportedIndexProviders[routeProvider(route)]; ok {
+			continue
+		}
+
+		for base, ticker := range assetTickers {
+			minVolume, ok := f.minVolume(base)
+			if !ok {
+				continue
+			}
+
+			volume := ticker.Volume.Mul(ticker.Price)
+			if volume.GTE(minVolume) {
+				continue
+			}
+
+			telemetry.IncrCounterWithLabels([]string{"liquidity", "exclude"}, 1, []metrics.Label{
+				{Name: "provider", Value: routeProvider(route)},
+				{Name: "base", Value: base},
+			})
+			logger.Warn().
+				Str("route", route).
+				Str("base", base).
+				Str("volume", volume.String()).
+				Str("min_volume", minVolume.String()).
+				Msg("excluding the price source below the minimum liquidity")
+
+			illiquid[route] = append(illiquid[route], base)
+		}
+	}
+
+	for route := range illiquid {
+		sort.Strings(illiquid[route])
+	}
+	return illiquid
+}
+
+// excludeCandleRoutes drops the candles of the bases of every route.
+func excludeCandleRoutes(candles provider.AggregatedProviderCandles, routes map[string][]string) {
+	for route, bases := range routes {
+		for _, base := range bases {
+			delete(candles[route], base)
+		}
+	}
+}
+
+// excludeTickerRoutes drops the tickers of the bases of every route.
+func excludeTickerRoutes(tickers provider.AggregatedProviderPrices, routes map[string][]string) {
+	for route, bases := range routes {
+		for _, base := range bases {
+			delete(tickers[route], base)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package oracle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+func TestNewLiquidityFilter(t *testing.T) {
+	liquidityFilter, err := NewLiquidityFilter(config.Aggregation{
+		MinVolume: "100000",
+		Assets: []config.AssetAggregation{
+			{Base: "ATOM", MinVolume: "250000"},
+			{Base: "KII"},
+		},
+	})
+	require.NoError(t, err)
+
+	minVolume, ok := liquidityFilter.minVolume("ATOM")
+	require.True(t, ok)
+	require.Equal(t, math.LegacyNewDec(250000), minVolume)
+	minVolume, ok = liquidityFilter.minVolume("KII")
+	require.True(t, ok)
+	require.Equal(t, math.LegacyNewDec(100000), minVolume)
+
+	// the liquidity filter is disabled without any minimum volume
+	liquidityFilter, err = NewLiquidityFilter(config.Aggregation{})
+	require.NoError(t, err)
+	require.False(t, liquidityFilter.enabled())
+	_, ok = liquidityFilter.minVolume("ATOM")
+	require.False(t, ok)
+}
+
+func TestLiquidityFilter_IlliquidRoutes(t *testing.T) {
+	minVolume := math.LegacyNewDec(1000)
+	liquidityFilter := LiquidityFilter{
+		MinVolume:  &minVolume,
+		MinVolumes: map[string]math.LegacyDec{"KII": math.LegacyZeroDec()},
+	}
+
+	illiquidRoutes := liquidityFilter.illiquidRoutes(zerolog.Nop(), provider.AggregatedProviderPrices{
+		// 10 * 100 = 1000 USD
+		config.ProviderBinance: {"ATOM": {Price: math.LegacyNewDec(10), Volume: math.LegacyNewDec(100)}},
+		// 10 * 99 = 990 USD
+		config.ProviderMexc + routeSeparator + "USDC": {
+			"ATOM": {Price: math.LegacyNewDec(10), Volume: math.LegacyNewDec(99)},
+			"KII":  {Price: math.LegacyOneDec(), Volume: math.LegacyOneDec()},
+		},
+		config.ProviderGate: {"ATOM": {Price: math.LegacyNewDec(10), Volume: math.LegacyZeroDec()}},
+		// the synthetic volume of the index providers is ignored
+		config.ProviderOkxIndex: {"ATOM": {Price: math.LegacyNewDec(10), Volume: math.LegacyOneDec()}},
+	})
+	require.Equal(t, map[string][]string{
+		config.ProviderMexc + routeSeparator + "USDC": {"ATOM"},
+		config.ProviderGate:                           {"ATOM"},
+	}, illiquidRoutes)
+}
+
+func TestGetComputedPricesLiquidity(t *testing.T) {
+	atomPair := types.CurrencyPair{Base: "ATOM", Quote: "USD"}
+	providerPairs := map[string][]types.CurrencyPair{
+		config.ProviderBinance: {atomPair},
+		config.ProviderMexc:    {atomPair},
+	}
+	providerPrices := provider.AggregatedProviderPrices{
+		config.ProviderBinance: {atomPair.String(): {Price: math.LegacyNewDec(10), Volume: math.LegacyNewDec(1000)}},
+		config.ProviderMexc:    {atomPair.String(): {Price: math.LegacyNewDec(16), Volume: math.LegacyOneDec()}},
+	}
+	candle := func(price int64) []provider.CandlePrice {
+		return []provider.CandlePrice{{
+			Price:     math.LegacyNewDec(price),
+			Volume:    math.LegacyNewDec(1000),
+			TimeStamp: provider.PastUnixTime(1 * time.Minute),
+		}}
+	}
+
+	testCases := map[string]struct {
+		candles        provider.AggregatedProviderCandles
+		minVolume      string
+		expectedPrices map[string]math.LegacyDec
+	}{
+		"tickers without minimum volume": {
+			expectedPrices: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(13)},
+		},
+		"illiquid ticker excluded": {
+			minVolume:      "1000",
+			expectedPrices: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)},
+		},
+		"illiquid candles excluded": {
+			candles: provider.AggregatedProviderCandles{
+				config.ProviderBinance: {atomPair.String(): candle(10)},
+				config.ProviderMexc:    {atomPair.String(): candle(16)},
+			},
+			minVolume:      "1000",
+			expectedPrices: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			liquidityFilter, err := NewLiquidityFilter(config.Aggregation{MinVolume: tc.minVolume})
+			require.NoError(t, err)
+
+			prices, _, err := GetComputedPrices(
+				zerolog.Nop(),
+				tc.candles,
+				providerPrices,
+				providerPairs,
+				make(map[string]math.LegacyDec),
+				Aggregation{Strategy: MedianStrategy{}, Liquidity: liquidityFilter},
+				map[string]struct{}{"ATOM": {}},
+			)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPrices, prices)
+		})
+	}
+}
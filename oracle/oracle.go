@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -43,16 +44,22 @@ type Oracle struct {
 	failedProviders    map[string]error
 	oracleClient       client.OracleClient
 	deviations         map[string]sdkmath.LegacyDec
+	aggregation        Aggregation
+	votePolicy         VotePolicy
 	endpoints          map[string]config.ProviderEndpoint
+	orderBookPricing   map[string]provider.OrderBookPricing // map with the order book pricing by currency pair
 
 	// variables store and handle the prices
-	mtx             sync.RWMutex
-	lastPriceSyncTS time.Time
-	prices          map[string]sdkmath.LegacyDec // map with the prices to be requested
-	paramCache      ParamCache
-	jailCache       JailCache
-	healthchecks    map[string]http.Client
-	mockSetPrices   func(ctx context.Context) error // used for testing
+	mtx               sync.RWMutex
+	lastPriceSyncTS   time.Time
+	prices            map[string]sdkmath.LegacyDec // map with the prices to be requested
+	lastVotedPrices   map[string]sdkmath.LegacyDec // map with the last voted prices by base
+	heldPrices        map[string]struct{}          // set with the bases whose price is held by the circuit breaker
+	paramCache        ParamCache
+	exchangeRateCache ExchangeRateCache
+	jailCache         JailCache
+	healthchecks      map[string]http.Client
+	mockSetPrices     func(ctx context.Context) error // used for testing
 }
 
 // createMappingsFromPairs is a helper function to initialize maps from currencyPairs
@@ -75,12 +82,34 @@ func createMappingsFromPairs(currencyPairs []config.CurrencyPair) (map[string]st
 			// save the currencies per provider
 			providerPairs[provider] = append(providerPairs[provider], currencyPair)
 		}
-		// store the pairs per denom
-		chainDenomMapping[pair.Base] = pair.ChainDenom
+		// store the chain-denom per base, shared by every quote of the base
+		if _, ok := chainDenomMapping[pair.Base]; !ok || len(pair.ChainDenom) > 0 {
+			chainDenomMapping[pair.Base] = pair.ChainDenom
+		}
 	}
 	return chainDenomMapping, providerPairs
 }
 
+// createOrderBookPricing returns the order book pricing of the currency pairs
+// which are not priced from their last trade.
+func createOrderBookPricing(currencyPairs []config.CurrencyPair) map[string]provider.OrderBookPricing {
+	orderBookPricing := make(map[string]provider.OrderBookPricing)
+
+	for _, pair := range currencyPairs {
+		if pair.PriceSource == "" || pair.PriceSource == config.PriceSourceLast {
+			continue
+		}
+
+		currencyPair := types.CurrencyPair{Base: pair.Base, Quote: pair.Quote}
+		orderBookPricing[currencyPair.String()] = provider.OrderBookPricing{
+			Source:   pair.PriceSource,
+			DepthBps: pair.DepthBps,
+		}
+	}
+
+	return orderBookPricing
+}
+
 // New creates a new instance of the Oracle struct and
 // extract the currencie pairs per denom
 func New(
@@ -89,6 +118,8 @@ func New(
 	currencyPairs []config.CurrencyPair,
 	providerTimeout time.Duration,
 	deviations map[string]sdkmath.LegacyDec,
+	aggregation Aggregation,
+	votePolicy VotePolicy,
 	endpoints map[string]config.ProviderEndpoint,
 	healthchecksConfig []config.Healthchecks,
 ) *Oracle {
@@ -120,10 +151,13 @@ func New(
 		priceProviders:    make(map[string]provider.Provider),
 		providerTimeout:   providerTimeout,
 		deviations:        deviations,
+		aggregation:       aggregation,
+		votePolicy:        votePolicy,
 		paramCache:        ParamCache{},
 		jailCache:         JailCache{},
 		failedProviders:   make(map[string]error),
 		endpoints:         endpoints,
+		orderBookPricing:  createOrderBookPricing(currencyPairs),
 		healthchecks:      healthchecks,
 	}
 }
@@ -212,6 +246,22 @@ func (o *Oracle) GetPrices() sdk.DecCoins {
 	return prices
 }
 
+// GetActiveEndpoints returns the endpoint currently used by each running
+// provider which supports several endpoints.
+func (o *Oracle) GetActiveEndpoints() map[string]config.ProviderEndpoint {
+	o.mtx.RLock()
+	defer o.mtx.RUnlock()
+
+	endpoints := make(map[string]config.ProviderEndpoint, len(o.priceProviders))
+	for providerName, priceProvider := range o.priceProviders {
+		if endpointProvider, ok := priceProvider.(provider.EndpointProvider); ok {
+			endpoints[providerName] = endpointProvider.ActiveEndpoint()
+		}
+	}
+
+	return endpoints
+}
+
 // sendProviderFailureMetric function is overridden by unit tests
 var sendProviderFailureMetric = telemetry.IncrCounterWithLabels
 
@@ -239,9 +289,9 @@ func reportPriceErrMetrics[V any](providerName string, priceType string, prices
 }
 
 // SetPrices retrieves all the prices and candles from our set of providers as
-// determined in the config. If candles are available, uses TVWAP in order
-// to determine prices. If candles are not available, uses the most recent prices
-// with VWAP. Warns the user of any missing prices, and filters out any faulty
+// determined in the config. If candles are available, aggregates them with the
+// strategy of every asset (TVWAP by default) in order to determine prices. If
+// candles are not available, uses the most recent prices instead. Warns the user of any missing prices, and filters out any faulty
 // providers which do not report prices or candles within 2𝜎 of the others.
 func (o *Oracle) SetPrices(ctx context.Context) error {
 	if o.mockSetPrices != nil {
@@ -310,9 +360,9 @@ func (o *Oracle) SetPrices(ctx context.Context) error {
 				return nil
 			}
 
-			// flatten and collect prices based on the base currency per provider
+			// flatten and collect prices based on the currency pair per provider
 			//
-			// e.g.: {ProviderKraken: {"ATOM": <price, volume>, ...}}
+			// e.g.: {ProviderKraken: {"ATOMUSDT": <price, volume>, ...}}
 			mtx.Lock()
 			for _, pair := range currencyPairs {
 				success := SetProviderTickerPricesAndCandles(providerName, providerPrices, providerCandles, prices, candles, pair)
@@ -339,40 +389,105 @@ func (o *Oracle) SetPrices(ctx context.Context) error {
 		o.logger.Error().Err(err).Msg("set-prices errgroup returned an error")
 	}
 
-	computedPrices, err := GetComputedPrices(
+	computedPrices, sources, abstentions, computeErr := computePrices(
 		o.logger,
 		providerCandles,
 		providerPrices,
 		o.providerPairs,
 		o.deviations,
+		o.aggregation,
 		requiredRates,
 	)
-	if err != nil {
-		return err
+
+	// without live prices, every required price is filled by the last known
+	// good prices, otherwise the prices fail
+	fallback := o.votePolicy.Fallback
+	now := time.Now()
+	if computeErr != nil {
+		if fallback == nil {
+			return computeErr
+		}
+		o.logger.Warn().Err(computeErr).Msg("failed to compute the prices, falling back to the last known good prices")
+		computedPrices = make(map[string]sdkmath.LegacyDec, len(requiredRates))
+		abstentions = make(Abstentions)
 	}
 
+	// save the live prices as the last known good prices
+	if fallback != nil && computeErr == nil {
+		if err := fallback.Update(computedPrices, sources, now); err != nil {
+			o.logger.Warn().Err(err).Msg("failed to persist the fallback prices")
+		}
+	}
+
+	// the missing required prices are filled by the last known good prices,
+	// otherwise fail the vote unless abstained from
+	voteAbstentions := make(Abstentions)
 	for base := range requiredRates {
-		if _, ok := computedPrices[base]; !ok {
-			return fmt.Errorf("reported prices were not equal to required rates, missed: %s", base)
+		if _, ok := computedPrices[base]; ok {
+			continue
 		}
+
+		reason, ok := abstentions[base]
+		if !ok {
+			if fallback != nil {
+				if price, ok := fallback.Price(o.logger, base, now); ok {
+					computedPrices[base] = price
+					continue
+				}
+			}
+			if computeErr != nil {
+				return computeErr
+			}
+			if !o.votePolicy.PartialVote {
+				return fmt.Errorf("reported prices were not equal to required rates, missed: %s", base)
+			}
+			reason = "missing price"
+		}
+		voteAbstentions.add(base, reason)
 	}
+	voteAbstentions.report(o.logger)
 
 	o.prices = computedPrices
 	return nil
 }
 
 // GetComputedPrices gets the candle and ticker prices and computes it.
-// It returns candles' TVWAP if possible, if not possible (not available
-// or due to some staleness) it will use the most recent ticker prices
-// and the VWAP formula instead.
+// It returns the candles aggregated by the strategy of every asset if possible,
+// if not possible (not available or due to some staleness) it will aggregate
+// the most recent ticker prices instead. It also returns the assets abstained
+// from, which are not priced.
 func GetComputedPrices(
 	logger zerolog.Logger,
 	providerCandles provider.AggregatedProviderCandles,
 	providerPrices provider.AggregatedProviderPrices,
 	providerPairs map[string][]types.CurrencyPair,
 	deviations map[string]sdkmath.LegacyDec,
+	aggregation Aggregation,
+	requiredRates map[string]struct{},
+) (prices map[string]sdkmath.LegacyDec, abstentions Abstentions, err error) {
+	prices, _, abstentions, err = computePrices(
+		logger,
+		providerCandles,
+		providerPrices,
+		providerPairs,
+		deviations,
+		aggregation,
+		requiredRates,
+	)
+	return prices, abstentions, err
+}
+
+// computePrices computes the prices like GetComputedPrices, also returning the
+// sorted providers contributing to the price of every asset.
+func computePrices(
+	logger zerolog.Logger,
+	providerCandles provider.AggregatedProviderCandles,
+	providerPrices provider.AggregatedProviderPrices,
+	providerPairs map[string][]types.CurrencyPair,
+	deviations map[string]sdkmath.LegacyDec,
+	aggregation Aggregation,
 	requiredRates map[string]struct{},
-) (prices map[string]sdkmath.LegacyDec, err error) {
+) (prices map[string]sdkmath.LegacyDec, sources map[string][]string, abstentions Abstentions, err error) {
 	// only do asset provider map logic is log level is debug
 	if logger.GetLevel() == zerolog.DebugLevel {
 		assetProviderMap := make(map[string][]string)
@@ -383,7 +498,7 @@ func GetComputedPrices(
 		}
 		assetProviderJSON, err := json.Marshal(assetProviderMap)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 		logger.Debug().Msg(fmt.Sprintf("Asset Provider Coverage Map: %s", string(assetProviderJSON)))
 
@@ -395,19 +510,53 @@ func GetComputedPrices(
 		}
 		candleProviderJSON, err := json.Marshal(candleProviderMap)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 		logger.Debug().Msg(fmt.Sprintf("Candle Provider Coverage Map: %s", string(candleProviderJSON)))
 	}
 	// convert any non-USD denominated candles into USD
-	convertedCandles, err := convertCandlesToUSD(
+	convertedCandles, abstentions, err := convertCandlesToUSD(
 		logger,
 		providerCandles,
 		providerPairs,
 		deviations,
+		aggregation,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
+	}
+
+	// derive the adaptive deviation thresholds from the candle history
+	if aggregation.AdaptiveDeviation != nil {
+		aggregation.Volatilities, err = realizedVolatilities(convertedCandles)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// exclude the routes below the minimum liquidity of their asset, given
+	// the 24h volume of their tickers
+	var (
+		convertedTickers  provider.AggregatedProviderPrices
+		tickerAbstentions Abstentions
+		tickersConverted  bool
+	)
+	if aggregation.Liquidity.enabled() {
+		convertedTickers, tickerAbstentions, err = convertTickersToUSD(
+			logger,
+			providerPrices,
+			providerPairs,
+			deviations,
+			aggregation,
+		)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		tickersConverted = true
+
+		illiquidRoutes := aggregation.Liquidity.illiquidRoutes(logger, convertedTickers)
+		excludeCandleRoutes(convertedCandles, illiquidRoutes)
+		excludeTickerRoutes(convertedTickers, illiquidRoutes)
 	}
 
 	// filter out any erroneous candles
@@ -415,69 +564,131 @@ func GetComputedPrices(
 		logger,
 		convertedCandles,
 		deviations,
+		aggregation,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	// attempt to use candles for TVWAP calculations
-	computedPrices, err := ComputeTVWAP(filteredCandles)
+	// drop the assets with fewer candle providers than their quorum
+	candleProviders := candleSources(filteredCandles)
+	belowQuorum := aggregation.Quorum.enforce(logger, "candle", candleProviders)
+	for base := range belowQuorum {
+		for _, assetCandles := range filteredCandles {
+			delete(assetCandles, base)
+		}
+	}
+
+	// attempt to use candles for the aggregation of every asset
+	computedPrices, err := aggregation.AggregateCandles(filteredCandles)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	candleAssets := []string{}
 	tickerAssets := []string{}
+	sources = make(map[string][]string, len(computedPrices))
 	for base := range computedPrices {
 		candleAssets = append(candleAssets, base)
+		sources[base] = candleProviders[base]
 	}
 	allRequiredAssetsPresent := true
 	for asset := range requiredRates {
-		if _, ok := computedPrices[asset]; !ok {
+		_, abstained := abstentions[asset]
+		if _, ok := computedPrices[asset]; !ok && !abstained {
 			allRequiredAssetsPresent = false
 		}
 	}
 	// If we're missing some assets, calculate tickers too to fill the gaps
-	// use most recent prices & VWAP instead.
+	// use most recent prices instead.
 	if !allRequiredAssetsPresent {
 		logger.Debug().Msg("Evaluating tickers because some required rates were not provided via candles")
-		convertedTickers, err := convertTickersToUSD(
-			logger,
-			providerPrices,
-			providerPairs,
-			deviations,
-		)
-		if err != nil {
-			return nil, err
+		if !tickersConverted {
+			convertedTickers, tickerAbstentions, err = convertTickersToUSD(
+				logger,
+				providerPrices,
+				providerPairs,
+				deviations,
+				aggregation,
+			)
+			if err != nil {
+				return nil, nil, nil, err
+			}
 		}
 
 		filteredProviderPrices, err := FilterTickerDeviations(
 			logger,
 			convertedTickers,
 			deviations,
+			aggregation,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
+		}
+
+		// drop the assets with fewer ticker providers than their quorum
+		tickerProviders := tickerSources(filteredProviderPrices)
+		tickersBelowQuorum := aggregation.Quorum.enforce(logger, "ticker", tickerProviders)
+		for base, providers := range tickersBelowQuorum {
+			for _, assetTickers := range filteredProviderPrices {
+				delete(assetTickers, base)
+			}
+			belowQuorum[base] = providers
 		}
 
-		vwapPrices, err := ComputeVWAP(filteredProviderPrices)
+		tickerPrices, err := aggregation.AggregateTickers(filteredProviderPrices)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 
-		for asset, price := range vwapPrices {
-			if _, ok := computedPrices[asset]; !ok {
+		for asset, price := range tickerPrices {
+			_, abstained := abstentions[asset]
+			if _, ok := computedPrices[asset]; !ok && !abstained {
 				tickerAssets = append(tickerAssets, asset)
 				computedPrices[asset] = price
+				sources[asset] = tickerProviders[asset]
+			}
+		}
+
+		// the ticker abstentions only apply to the assets without candles
+		for asset, reason := range tickerAbstentions {
+			if _, ok := computedPrices[asset]; !ok {
+				abstentions.add(asset, reason)
 			}
 		}
 	}
-	logger.Debug().Msg(fmt.Sprint("Assets using Candle TVWAP: ", candleAssets, " Assets using Ticker VWAP: ", tickerAssets))
-	return computedPrices, nil
+
+	// the assets priced by neither candles nor tickers because of their
+	// quorum are abstained from, or fail the prices
+	quorumAssets := make([]string, 0, len(belowQuorum))
+	for base := range belowQuorum {
+		_, abstained := abstentions[base]
+		if _, ok := computedPrices[base]; !ok && !abstained {
+			quorumAssets = append(quorumAssets, base)
+		}
+	}
+	sort.Strings(quorumAssets)
+	for _, base := range quorumAssets {
+		rule := aggregation.Quorum.rule(base)
+		if rule.Action == config.QuorumActionFail {
+			return nil, nil, nil, fmt.Errorf(
+				"%s priced by %d sources below its quorum of %d: %s",
+				base,
+				len(belowQuorum[base]),
+				rule.MinSources,
+				formatSources(belowQuorum[base]),
+			)
+		}
+		abstentions.add(base, "quorum")
+	}
+
+	logger.Debug().Msg(fmt.Sprint("Assets using Candles: ", candleAssets, " Assets using Tickers: ", tickerAssets))
+	return computedPrices, sources, abstentions, nil
 }
 
 // SetProviderTickerPricesAndCandles flattens and collects prices for
-// candles and tickers based on the base currency per provider.
+// candles and tickers based on the currency pair per provider, so the
+// quotes of a base priced by the same provider do not overwrite each other.
 // Returns true if at least one of price or candle exists.
 func SetProviderTickerPricesAndCandles(
 	providerName string,
@@ -498,10 +709,10 @@ func SetProviderTickerPricesAndCandles(
 	cp, candlesOk := candles[pair.String()]
 
 	if pricesOk {
-		providerPrices[providerName][pair.Base] = tp
+		providerPrices[providerName][pair.String()] = tp
 	}
 	if candlesOk {
-		providerCandles[providerName][pair.Base] = cp
+		providerCandles[providerName][pair.String()] = cp
 	}
 
 	return pricesOk || candlesOk
@@ -532,13 +743,45 @@ func (o *Oracle) getOrSetProvider(ctx context.Context, providerName string) (pro
 			return nil, err
 		}
 		priceProvider = newProvider
+		o.setOrderBookPricing(providerName, priceProvider)
 
+		o.mtx.Lock()
 		o.priceProviders[providerName] = priceProvider
+		o.mtx.Unlock()
 	}
 
 	return priceProvider, nil
 }
 
+// setOrderBookPricing requests the provider to price its pairs configured with
+// an order book price source from their order book. Providers without order
+// book support keep reporting the last trade price of those pairs.
+func (o *Oracle) setOrderBookPricing(providerName string, priceProvider provider.Provider) {
+	for _, pair := range o.providerPairs[providerName] {
+		pricing, ok := o.orderBookPricing[pair.String()]
+		if !ok {
+			continue
+		}
+
+		orderBookProvider, ok := priceProvider.(provider.OrderBookProvider)
+		if !ok {
+			o.logger.Warn().
+				Str("provider", providerName).
+				Str("pair", pair.String()).
+				Str("price_source", pricing.Source).
+				Msg("provider does not support order book pricing, using the last trade price")
+			continue
+		}
+
+		if err := orderBookProvider.SetOrderBookPricing(pair, pricing); err != nil {
+			o.logger.Err(err).
+				Str("provider", providerName).
+				Str("pair", pair.String()).
+				Msg("failed to set order book pricing")
+		}
+	}
+}
+
 // Create various providers to pull price data for oracle price feeds
 func NewProvider(
 	ctx context.Context,
@@ -572,6 +815,12 @@ func NewProvider(
 	case config.ProviderGate:
 		return provider.NewGateProvider(ctx, logger, endpoint, providerPairs...)
 
+	case config.ProviderOkxIndex:
+		return provider.NewOkxIndexProvider(ctx, logger, endpoint, providerPairs...)
+
+	case config.ProviderBinanceIndex:
+		return provider.NewBinanceIndexProvider(ctx, logger, endpoint, providerPairs...)
+
 	case config.ProviderMock:
 		return provider.NewMockProvider(), nil
 	}
@@ -631,6 +880,9 @@ func (o *Oracle) tick(
 		return err
 	}
 
+	// guard the prices against sudden changes before voting them
+	o.applyCircuitBreaker(ctx, blockHeight, oracleParams)
+
 	o.lastPriceSyncTS = time.Now() // update the date when the prices was updated
 
 	// Get oracle vote period, next block height, current vote period, and index
@@ -663,6 +915,12 @@ func (o *Oracle) tick(
 	// filter for whitelisted denominations so that extra oracle prices are not penalized
 	filteredPrices := filterPricesByDenomList(prices, oracleParams.Whitelist)
 
+	// check the prices against the reward band before voting them
+	filteredPrices = o.checkRewardBand(ctx, blockHeight, oracleParams, filteredPrices)
+	if filteredPrices.Empty() {
+		return fmt.Errorf("no exchange rates to vote")
+	}
+
 	// convert rates to string (sorted string)
 	exchangeRatesStr := GenerateExchangeRatesString(filteredPrices)
 
@@ -704,6 +962,9 @@ func (o *Oracle) tick(
 	// update the vote period voted
 	o.previousVotePeriod = currentVotePeriod
 
+	// save the voted prices to guard the next prices
+	o.setLastVotedPrices(filteredPrices)
+
 	// validate the health endpoints
 	o.healthchecksPing()
 
@@ -730,6 +991,32 @@ func (o *Oracle) logResponseError(err error, resp *sdk.TxResponse, startTime tim
 		Msg(fmt.Sprintf("broadcasted for height %d", blockHeight))
 }
 
+// setLastVotedPrices saves the voted prices by base as the references of the
+// circuit breaker. The prices held by the circuit breaker are not saved, so
+// the bases keep the last price voted from their providers.
+func (o *Oracle) setLastVotedPrices(votedPrices sdk.DecCoins) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	lastVotedPrices := make(map[string]sdkmath.LegacyDec, len(votedPrices))
+	for base, chainDenom := range o.chainDenomMapping {
+		if len(chainDenom) == 0 {
+			continue
+		}
+		if _, ok := o.heldPrices[base]; ok {
+			if lastVotedPrice, ok := o.lastVotedPrices[base]; ok {
+				lastVotedPrices[base] = lastVotedPrice
+			}
+			continue
+		}
+		if price := votedPrices.AmountOf(chainDenom); price.IsPositive() {
+			lastVotedPrices[base] = price
+		}
+	}
+
+	o.lastVotedPrices = lastVotedPrices
+}
+
 // healthchecksPing validates the health endpoints work
 func (o *Oracle) healthchecksPing() {
 	// iterate over the health check endpoints listed
@@ -3,6 +3,7 @@ package oracle
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -155,6 +156,16 @@ func (m failingProvider) GetAvailablePairs() (map[string]struct{}, error) {
 	return map[string]struct{}{}, nil
 }
 
+type orderBookProvider struct {
+	mockProvider
+	pricing map[string]provider.OrderBookPricing
+}
+
+func (m orderBookProvider) SetOrderBookPricing(cp types.CurrencyPair, pricing provider.OrderBookPricing) error {
+	m.pricing[cp.String()] = pricing
+	return nil
+}
+
 type OracleTestSuite struct {
 	suite.Suite
 
@@ -201,6 +212,8 @@ func (ots *OracleTestSuite) SetupSuite() {
 		},
 		time.Millisecond*100,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
+		VotePolicy{},
 		make(map[string]config.ProviderEndpoint),
 		[]config.Healthchecks{
 			{URL: "https://hc-ping.com/HEALTHCHECK-UUID", Timeout: "200ms"},
@@ -871,8 +884,8 @@ func TestSuccessSetProviderTickerPricesAndCandles(t *testing.T) {
 	)
 
 	require.True(t, success, "It should successfully set the prices")
-	require.Equal(t, atomPrice, providerPrices[config.ProviderGate][pair.Base].Price)
-	require.Equal(t, atomPrice, providerCandles[config.ProviderGate][pair.Base][0].Price)
+	require.Equal(t, atomPrice, providerPrices[config.ProviderGate][pair.String()].Price)
+	require.Equal(t, atomPrice, providerCandles[config.ProviderGate][pair.String()][0].Price)
 }
 
 func TestFailedSetProviderTickerPricesAndCandles(t *testing.T) {
@@ -902,7 +915,7 @@ func TestSuccessGetComputedPricesCandles(t *testing.T) {
 	atomVolume := math.LegacyMustNewDecFromStr("894123.00")
 
 	candles := make(map[string][]provider.CandlePrice, 1)
-	candles[pair.Base] = []provider.CandlePrice{
+	candles[pair.String()] = []provider.CandlePrice{
 		{
 			Price:     atomPrice,
 			Volume:    atomVolume,
@@ -915,12 +928,13 @@ func TestSuccessGetComputedPricesCandles(t *testing.T) {
 		"binance": {pair},
 	}
 
-	prices, err := GetComputedPrices(
+	prices, _, err := GetComputedPrices(
 		zerolog.Nop(),
 		providerCandles,
 		make(provider.AggregatedProviderPrices, 1),
 		providerPair,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 		map[string]struct{}{
 			"ATOM": {},
 		},
@@ -941,7 +955,7 @@ func TestSuccessGetComputedPricesTickers(t *testing.T) {
 	atomVolume := math.LegacyMustNewDecFromStr("894123.00")
 
 	tickerPrices := make(map[string]provider.TickerPrice, 1)
-	tickerPrices[pair.Base] = provider.TickerPrice{
+	tickerPrices[pair.String()] = provider.TickerPrice{
 		Price:  atomPrice,
 		Volume: atomVolume,
 	}
@@ -951,12 +965,13 @@ func TestSuccessGetComputedPricesTickers(t *testing.T) {
 		"binance": {pair},
 	}
 
-	prices, err := GetComputedPrices(
+	prices, _, err := GetComputedPrices(
 		zerolog.Nop(),
 		make(provider.AggregatedProviderCandles, 1),
 		providerPrices,
 		providerPair,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 		map[string]struct{}{
 			"ATOM": {},
 		},
@@ -966,6 +981,87 @@ func TestSuccessGetComputedPricesTickers(t *testing.T) {
 	require.Equal(t, prices[pair.Base], atomPrice)
 }
 
+func TestGetComputedPricesAggregationStrategy(t *testing.T) {
+	pair := types.CurrencyPair{
+		Base:  "ATOM",
+		Quote: "USD",
+	}
+
+	providerPrices := provider.AggregatedProviderPrices{}
+	providerPair := map[string][]types.CurrencyPair{}
+	for i, providerName := range []string{
+		config.ProviderBinance,
+		config.ProviderKraken,
+		config.ProviderOkx,
+		config.ProviderHuobi,
+	} {
+		volume := math.LegacyNewDec(100)
+		if providerName == config.ProviderHuobi {
+			volume = math.LegacyNewDec(10000)
+		}
+		providerPrices[providerName] = map[string]provider.TickerPrice{
+			pair.String(): {Price: math.LegacyNewDec(int64(10 + i)), Volume: volume},
+		}
+		providerPair[providerName] = []types.CurrencyPair{pair}
+	}
+
+	prices, _, err := GetComputedPrices(
+		zerolog.Nop(),
+		make(provider.AggregatedProviderCandles, 1),
+		providerPrices,
+		providerPair,
+		map[string]math.LegacyDec{pair.Base: math.LegacyNewDec(2)},
+		Aggregation{
+			Strategies: map[string]AggregationStrategy{pair.Base: MedianStrategy{}},
+		},
+		map[string]struct{}{
+			"ATOM": {},
+		},
+	)
+
+	require.NoError(t, err)
+	require.Equal(t, math.LegacyMustNewDecFromStr("11.5"), prices[pair.Base])
+}
+
+func TestGetComputedPricesSeveralQuotes(t *testing.T) {
+	atomUSDTPair := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
+	atomUSDCPair := types.CurrencyPair{Base: "ATOM", Quote: "USDC"}
+	usdtPair := types.CurrencyPair{Base: "USDT", Quote: "USD"}
+	usdcPair := types.CurrencyPair{Base: "USDC", Quote: "USD"}
+	volume := math.LegacyNewDec(100)
+
+	// the prices of every quote route of binance are separate samples
+	providerPrices := provider.AggregatedProviderPrices{
+		config.ProviderBinance: {
+			atomUSDTPair.String(): {Price: math.LegacyNewDec(10), Volume: volume},
+			atomUSDCPair.String(): {Price: math.LegacyNewDec(6), Volume: volume},
+		},
+		config.ProviderKraken: {
+			usdtPair.String(): {Price: math.LegacyOneDec(), Volume: volume},
+			usdcPair.String(): {Price: math.LegacyNewDec(2), Volume: volume},
+		},
+	}
+	providerPair := map[string][]types.CurrencyPair{
+		config.ProviderBinance: {atomUSDTPair, atomUSDCPair},
+		config.ProviderKraken:  {usdtPair, usdcPair},
+	}
+
+	prices, _, err := GetComputedPrices(
+		zerolog.Nop(),
+		make(provider.AggregatedProviderCandles, 1),
+		providerPrices,
+		providerPair,
+		make(map[string]math.LegacyDec),
+		Aggregation{},
+		map[string]struct{}{
+			"ATOM": {},
+		},
+	)
+
+	require.NoError(t, err)
+	require.Equal(t, math.LegacyNewDec(11), prices["ATOM"])
+}
+
 func TestGetComputedPricesCandlesConversion(t *testing.T) {
 	btcPair := types.CurrencyPair{
 		Base:  "BTC",
@@ -987,14 +1083,14 @@ func TestGetComputedPricesCandlesConversion(t *testing.T) {
 
 	// normal rates
 	binanceCandles := make(map[string][]provider.CandlePrice, 2)
-	binanceCandles[btcPair.Base] = []provider.CandlePrice{
+	binanceCandles[btcPair.String()] = []provider.CandlePrice{
 		{
 			Price:     btcEthPrice,
 			Volume:    volume,
 			TimeStamp: provider.PastUnixTime(1 * time.Minute),
 		},
 	}
-	binanceCandles[ethPair.Base] = []provider.CandlePrice{
+	binanceCandles[ethPair.String()] = []provider.CandlePrice{
 		{
 			Price:     ethUsdPrice,
 			Volume:    volume,
@@ -1005,14 +1101,14 @@ func TestGetComputedPricesCandlesConversion(t *testing.T) {
 
 	// normal rates
 	gateCandles := make(map[string][]provider.CandlePrice, 1)
-	gateCandles[ethPair.Base] = []provider.CandlePrice{
+	gateCandles[ethPair.String()] = []provider.CandlePrice{
 		{
 			Price:     ethUsdPrice,
 			Volume:    volume,
 			TimeStamp: provider.PastUnixTime(1 * time.Minute),
 		},
 	}
-	gateCandles[btcPair.Base] = []provider.CandlePrice{
+	gateCandles[btcPair.String()] = []provider.CandlePrice{
 		{
 			Price:     btcEthPrice,
 			Volume:    volume,
@@ -1023,7 +1119,7 @@ func TestGetComputedPricesCandlesConversion(t *testing.T) {
 
 	// abnormal eth rate
 	okxCandles := make(map[string][]provider.CandlePrice, 1)
-	okxCandles[ethPair.Base] = []provider.CandlePrice{
+	okxCandles[ethPair.String()] = []provider.CandlePrice{
 		{
 			Price:     math.LegacyMustNewDecFromStr("1.0"),
 			Volume:    volume,
@@ -1034,7 +1130,7 @@ func TestGetComputedPricesCandlesConversion(t *testing.T) {
 
 	// btc / usd rate
 	krakenCandles := make(map[string][]provider.CandlePrice, 1)
-	krakenCandles[btcUSDPair.Base] = []provider.CandlePrice{
+	krakenCandles[btcUSDPair.String()] = []provider.CandlePrice{
 		{
 			Price:     btcUSDPrice,
 			Volume:    volume,
@@ -1050,12 +1146,13 @@ func TestGetComputedPricesCandlesConversion(t *testing.T) {
 		config.ProviderKraken:  {btcUSDPair},
 	}
 
-	prices, err := GetComputedPrices(
+	prices, _, err := GetComputedPrices(
 		zerolog.Nop(),
 		providerCandles,
 		make(provider.AggregatedProviderPrices, 1),
 		providerPair,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 		map[string]struct{}{
 			"BTC": {},
 		},
@@ -1096,11 +1193,11 @@ func TestGetComputedPricesTickersConversion(t *testing.T) {
 
 	// normal rates
 	binanceTickerPrices := make(map[string]provider.TickerPrice, 2)
-	binanceTickerPrices[btcPair.Base] = provider.TickerPrice{
+	binanceTickerPrices[btcPair.String()] = provider.TickerPrice{
 		Price:  btcEthPrice,
 		Volume: volume,
 	}
-	binanceTickerPrices[ethPair.Base] = provider.TickerPrice{
+	binanceTickerPrices[ethPair.String()] = provider.TickerPrice{
 		Price:  ethUsdPrice,
 		Volume: volume,
 	}
@@ -1108,11 +1205,11 @@ func TestGetComputedPricesTickersConversion(t *testing.T) {
 
 	// normal rates
 	gateTickerPrices := make(map[string]provider.TickerPrice, 4)
-	gateTickerPrices[btcPair.Base] = provider.TickerPrice{
+	gateTickerPrices[btcPair.String()] = provider.TickerPrice{
 		Price:  btcEthPrice,
 		Volume: volume,
 	}
-	gateTickerPrices[ethPair.Base] = provider.TickerPrice{
+	gateTickerPrices[ethPair.String()] = provider.TickerPrice{
 		Price:  ethUsdPrice,
 		Volume: volume,
 	}
@@ -1120,7 +1217,7 @@ func TestGetComputedPricesTickersConversion(t *testing.T) {
 
 	// abnormal eth rate
 	okxTickerPrices := make(map[string]provider.TickerPrice, 1)
-	okxTickerPrices[ethPair.Base] = provider.TickerPrice{
+	okxTickerPrices[ethPair.String()] = provider.TickerPrice{
 		Price:  math.LegacyMustNewDecFromStr("1.0"),
 		Volume: volume,
 	}
@@ -1128,7 +1225,7 @@ func TestGetComputedPricesTickersConversion(t *testing.T) {
 
 	// btc / usd rate
 	krakenTickerPrices := make(map[string]provider.TickerPrice, 1)
-	krakenTickerPrices[btcUSDPair.Base] = provider.TickerPrice{
+	krakenTickerPrices[btcUSDPair.String()] = provider.TickerPrice{
 		Price:  btcUSDPrice,
 		Volume: volume,
 	}
@@ -1141,12 +1238,13 @@ func TestGetComputedPricesTickersConversion(t *testing.T) {
 		config.ProviderKraken:  {btcUSDPair},
 	}
 
-	prices, err := GetComputedPrices(
+	prices, _, err := GetComputedPrices(
 		zerolog.Nop(),
 		make(provider.AggregatedProviderCandles, 1),
 		providerPrices,
 		providerPair,
 		make(map[string]math.LegacyDec),
+		Aggregation{},
 		map[string]struct{}{
 			"BTC": {},
 		},
@@ -1161,3 +1259,173 @@ func TestGetComputedPricesTickersConversion(t *testing.T) {
 		prices[btcPair.Base],
 	)
 }
+
+func TestSetOrderBookPricing(t *testing.T) {
+	currencyPairs := []config.CurrencyPair{
+		{Base: "ATOM", Quote: "USDT", Providers: []string{config.ProviderOkx, config.ProviderBinance}, PriceSource: config.PriceSourceLast},
+		{Base: "TRX", Quote: "USDT", Providers: []string{config.ProviderOkx, config.ProviderBinance}, PriceSource: config.PriceSourceMid},
+		{Base: "XAUT", Quote: "USDT", Providers: []string{config.ProviderOkx}, PriceSource: config.PriceSourceDepth, DepthBps: 15},
+	}
+	_, providerPairs := createMappingsFromPairs(currencyPairs)
+
+	oracle := &Oracle{
+		logger:           zerolog.Nop(),
+		providerPairs:    providerPairs,
+		orderBookPricing: createOrderBookPricing(currencyPairs),
+	}
+
+	okx := orderBookProvider{pricing: map[string]provider.OrderBookPricing{}}
+	oracle.setOrderBookPricing(config.ProviderOkx, okx)
+	require.Equal(t, map[string]provider.OrderBookPricing{
+		"TRXUSDT":  {Source: config.PriceSourceMid},
+		"XAUTUSDT": {Source: config.PriceSourceDepth, DepthBps: 15},
+	}, okx.pricing)
+
+	// providers without order book support are left untouched
+	oracle.setOrderBookPricing(config.ProviderBinance, mockProvider{})
+}
+
+func TestSetPricesPartialVote(t *testing.T) {
+	for _, partialVote := range []bool{false, true} {
+		oracle := New(
+			zerolog.Nop(),
+			client.OracleClient{},
+			[]config.CurrencyPair{
+				{Base: "UMEE", ChainDenom: "uumee", Quote: "USD", Providers: []string{config.ProviderBinance}},
+				{Base: "XBT", ChainDenom: "uxbt", Quote: "USD", Providers: []string{config.ProviderOkx}},
+			},
+			time.Millisecond*100,
+			make(map[string]math.LegacyDec),
+			Aggregation{},
+			VotePolicy{PartialVote: partialVote},
+			make(map[string]config.ProviderEndpoint),
+			[]config.Healthchecks{},
+		)
+		oracle.paramCache = ParamCache{
+			params: &oracletypes.Params{Whitelist: denomList("uumee", "uxbt")},
+		}
+
+		// the XBT price is missing
+		oracle.priceProviders = map[string]provider.Provider{
+			config.ProviderBinance: mockProvider{
+				prices: map[string]provider.TickerPrice{
+					"UMEEUSD": {Price: math.LegacyMustNewDecFromStr("3.72"), Volume: math.LegacyOneDec()},
+				},
+			},
+			config.ProviderOkx: mockProvider{prices: map[string]provider.TickerPrice{}},
+		}
+
+		err := oracle.SetPrices(context.TODO())
+		if !partialVote {
+			require.ErrorContains(t, err, "missed: XBT")
+			require.Empty(t, oracle.GetPrices())
+			continue
+		}
+
+		require.NoError(t, err)
+		prices := oracle.GetPrices()
+		require.Len(t, prices, 1)
+		require.True(t, prices.AmountOf("uumee").IsPositive())
+	}
+}
+
+func TestSetPricesFallback(t *testing.T) {
+	// the prices are set even if they can not be persisted
+	priceFallback, err := NewPriceFallback(config.Fallback{
+		MaxAge: "1m",
+		Path:   filepath.Join(t.TempDir(), "missing", "prices.json"),
+	})
+	require.NoError(t, err)
+
+	oracle := New(
+		zerolog.Nop(),
+		client.OracleClient{},
+		[]config.CurrencyPair{
+			{Base: "UMEE", ChainDenom: "uumee", Quote: "USD", Providers: []string{config.ProviderBinance}},
+			{Base: "XBT", ChainDenom: "uxbt", Quote: "USD", Providers: []string{config.ProviderOkx}},
+		},
+		time.Millisecond*100,
+		make(map[string]math.LegacyDec),
+		Aggregation{},
+		VotePolicy{Fallback: priceFallback},
+		make(map[string]config.ProviderEndpoint),
+		[]config.Healthchecks{},
+	)
+	oracle.paramCache = ParamCache{
+		params: &oracletypes.Params{Whitelist: denomList("uumee", "uxbt")},
+	}
+	umeeProvider := mockProvider{
+		prices: map[string]provider.TickerPrice{
+			"UMEEUSD": {Price: math.LegacyMustNewDecFromStr("3.72"), Volume: math.LegacyOneDec()},
+		},
+	}
+	oracle.priceProviders = map[string]provider.Provider{
+		config.ProviderBinance: umeeProvider,
+		config.ProviderOkx: mockProvider{
+			prices: map[string]provider.TickerPrice{
+				"XBTUSD": {Price: math.LegacyNewDec(20000), Volume: math.LegacyOneDec()},
+			},
+		},
+	}
+	require.NoError(t, oracle.SetPrices(context.TODO()))
+	xbtPrice := oracle.GetPrices().AmountOf("uxbt")
+	require.True(t, xbtPrice.IsPositive())
+
+	// the missing XBT price is the last known good price
+	oracle.priceProviders = map[string]provider.Provider{
+		config.ProviderBinance: umeeProvider,
+		config.ProviderOkx:     mockProvider{prices: map[string]provider.TickerPrice{}},
+	}
+	require.NoError(t, oracle.SetPrices(context.TODO()))
+	require.Equal(t, xbtPrice, oracle.GetPrices().AmountOf("uxbt"))
+}
+
+func TestSetPricesFallbackOnFailure(t *testing.T) {
+	priceFallback, err := NewPriceFallback(config.Fallback{MaxAge: "1m"})
+	require.NoError(t, err)
+
+	oracle := New(
+		zerolog.Nop(),
+		client.OracleClient{},
+		[]config.CurrencyPair{
+			{Base: "UMEE", ChainDenom: "uumee", Quote: "USD", Providers: []string{config.ProviderBinance}},
+			{Base: "XBT", ChainDenom: "uxbt", Quote: "USD", Providers: []string{config.ProviderOkx}},
+		},
+		time.Millisecond*100,
+		make(map[string]math.LegacyDec),
+		Aggregation{},
+		VotePolicy{Fallback: priceFallback},
+		make(map[string]config.ProviderEndpoint),
+		[]config.Healthchecks{},
+	)
+	oracle.paramCache = ParamCache{
+		params: &oracletypes.Params{Whitelist: denomList("uumee", "uxbt")},
+	}
+	oracle.priceProviders = map[string]provider.Provider{
+		config.ProviderBinance: mockProvider{
+			prices: map[string]provider.TickerPrice{
+				"UMEEUSD": {Price: math.LegacyMustNewDecFromStr("3.72"), Volume: math.LegacyOneDec()},
+			},
+		},
+		config.ProviderOkx: mockProvider{
+			prices: map[string]provider.TickerPrice{
+				"XBTUSD": {Price: math.LegacyNewDec(20000), Volume: math.LegacyOneDec()},
+			},
+		},
+	}
+	require.NoError(t, oracle.SetPrices(context.TODO()))
+	prices := oracle.GetPrices()
+	require.Len(t, prices, 2)
+
+	// the XBT quorum fails the computed prices, every price is the last known
+	// good price
+	oracle.aggregation.Quorum = Quorum{
+		Rules: map[string]QuorumRule{"XBT": {MinSources: 2, Action: config.QuorumActionFail}},
+	}
+	require.NoError(t, oracle.SetPrices(context.TODO()))
+	require.Equal(t, prices, oracle.GetPrices())
+
+	// without a fallback the prices fail
+	oracle.votePolicy.Fallback = nil
+	require.ErrorContains(t, oracle.SetPrices(context.TODO()), "XBT priced by 1 sources below its quorum of 2")
+}
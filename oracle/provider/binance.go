@@ -4,11 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"net/url"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
@@ -26,7 +24,17 @@ const (
 	binanceRestPath = "/api/v3/ticker/price"
 )
 
-var _ Provider = (*BinanceProvider)(nil)
+var (
+	_ Provider             = (*BinanceProvider)(nil)
+	_ EndpointProvider     = (*BinanceProvider)(nil)
+	_ SubscriptionProvider = (*BinanceProvider)(nil)
+
+	// binanceFallbacks are the mirrors used when the default endpoint fails.
+	binanceFallbacks = []config.EndpointFallback{
+		{Rest: "https://api2.binance.com", Websocket: "stream.binance.com:443"},
+		{Rest: "https://api3.binance.com", Websocket: "data-stream.binance.vision:443"},
+	}
+)
 
 type (
 	// BinanceProvider defines an Oracle provider implemented by the Binance public
@@ -35,14 +43,14 @@ type (
 	// REF: https://binance-docs.github.io/apidocs/spot/en/#individual-symbol-mini-ticker-stream
 	// REF: https://binance-docs.github.io/apidocs/spot/en/#kline-candlestick-streams
 	BinanceProvider struct {
-		wsURL           url.URL
-		wsClient        *websocket.Conn
+		wsShards        *WebsocketShards
 		logger          zerolog.Logger
 		mtx             sync.RWMutex
-		endpoints       config.ProviderEndpoint
+		endpoints       *EndpointPool
 		tickers         map[string]BinanceTicker      // Symbol => BinanceTicker
 		candles         map[string][]BinanceCandle    // Symbol => BinanceCandle
 		subscribedPairs map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		*subscriptionTracker
 	}
 
 	// BinanceTicker ticker price response. https://pkg.go.dev/encoding/json#Unmarshal
@@ -90,13 +98,12 @@ func NewBinanceProvider(
 	endpoints config.ProviderEndpoint,
 	pairs ...types.CurrencyPair,
 ) (*BinanceProvider, error) {
-	if (endpoints.Name) != config.ProviderBinance {
-		endpoints = config.ProviderEndpoint{
-			Name:      config.ProviderBinance,
-			Rest:      binanceRestHost,
-			Websocket: binanceWSHost,
-		}
-	}
+	endpoints = withDefaultEndpoint(endpoints, config.ProviderEndpoint{
+		Name:      config.ProviderBinance,
+		Rest:      binanceRestHost,
+		Websocket: binanceWSHost,
+		Fallbacks: binanceFallbacks,
+	})
 
 	wsURL := url.URL{
 		Scheme: "wss",
@@ -104,31 +111,39 @@ func NewBinanceProvider(
 		Path:   binanceWSPath,
 	}
 
-	wsConn, response, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	endpointPool, err := NewEndpointPool(endpoints)
 	if err != nil {
-		return nil, fmt.Errorf("error connecting to Binance websocket: %w, %v", err, response)
+		return nil, err
 	}
 
 	provider := &BinanceProvider{
-		wsURL:           wsURL,
-		wsClient:        wsConn,
 		logger:          logger.With().Str("provider", "binance").Logger(),
-		endpoints:       endpoints,
+		endpoints:       endpointPool,
 		tickers:         map[string]BinanceTicker{},
 		candles:         map[string][]BinanceCandle{},
 		subscribedPairs: map[string]types.CurrencyPair{},
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderBinance, endpointPool.SilenceTimeout()),
 	}
 
+	provider.wsShards = NewWebsocketShards(
+		ctx,
+		config.ProviderBinance,
+		wsURL,
+		provider.endpoints,
+		endpoints.Connections,
+		provider.getSubscriptionMsgs,
+		provider.messageReceived,
+		disabledPingDuration,
+		websocket.PingMessage,
+		provider.logger,
+	)
+
 	if err := provider.SubscribeCurrencyPairs(pairs...); err != nil {
 		return nil, err
 	}
 
-	go provider.handleWebSocketMsgs(ctx)
+	go provider.retrySubscriptions(ctx, provider.logger, provider.wsShards.ResubscribeCurrencyPairs)
 
 	return provider, nil
 }
@@ -167,13 +182,15 @@ func (p *BinanceProvider) GetCandlePrices(pairs ...types.CurrencyPair) (map[stri
 	return candlePrices, nil
 }
 
-// SubscribeCurrencyPairs subscribe all currency pairs into ticker and candle channels.
+// SubscribeCurrencyPairs subscribe all currency pairs into ticker and candle
+// channels, splitting them across the websocket connections.
 func (p *BinanceProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
 	if len(cps) == 0 {
 		return fmt.Errorf("currency pairs is empty")
 	}
 
-	if err := p.subscribeChannels(cps...); err != nil {
+	p.trackSubscriptions(cps...)
+	if err := p.wsShards.SubscribeCurrencyPairs(cps...); err != nil {
 		return err
 	}
 
@@ -181,43 +198,15 @@ func (p *BinanceProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) erro
 	return nil
 }
 
-// subscribeChannels subscribe to the ticker and candle channels for all currency pairs.
-func (p *BinanceProvider) subscribeChannels(cps ...types.CurrencyPair) error {
-	if err := p.subscribeTickers(cps...); err != nil {
-		return err
-	}
-
-	return p.subscribeCandles(cps...)
-}
-
-// subscribeTickers subscribe to the ticker channel for all currency pairs.
-func (p *BinanceProvider) subscribeTickers(cps ...types.CurrencyPair) error {
-	pairs := make([]string, len(cps))
-
-	for i, cp := range cps {
-		pairs[i] = currencyPairToBinanceTickerPair(cp)
-	}
-
-	return p.subscribePairs(pairs...)
-}
-
-// subscribeCandles subscribe to the candle channel for all currency pairs.
-func (p *BinanceProvider) subscribeCandles(cps ...types.CurrencyPair) error {
-	pairs := make([]string, len(cps))
-
-	for i, cp := range cps {
-		pairs[i] = currencyPairToBinanceCandlePair(cp)
+// getSubscriptionMsgs returns the message subscribing to the ticker and candle
+// channels of the currency pairs.
+func (p *BinanceProvider) getSubscriptionMsgs(cps ...types.CurrencyPair) []interface{} {
+	params := make([]string, 0, len(cps)*2)
+	for _, cp := range cps {
+		params = append(params, currencyPairToBinanceTickerPair(cp), currencyPairToBinanceCandlePair(cp))
 	}
 
-	return p.subscribePairs(pairs...)
-}
-
-// subscribedPairsToSlice returns the map of subscribed pairs as a slice.
-func (p *BinanceProvider) subscribedPairsToSlice() []types.CurrencyPair {
-	p.mtx.RLock()
-	defer p.mtx.RUnlock()
-
-	return types.MapPairsToSlice(p.subscribedPairs)
+	return []interface{}{newBinanceSubscriptionMsg(params...)}
 }
 
 func (p *BinanceProvider) getTickerPrice(key string) (TickerPrice, error) {
@@ -302,6 +291,8 @@ func (p *BinanceProvider) messageReceived(messageType int, bz []byte) {
 }
 
 func (p *BinanceProvider) setTickerPair(ticker BinanceTicker) {
+	p.setSubscriptionActive(ticker.Symbol)
+
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 	p.tickers[ticker.Symbol] = ticker
@@ -331,90 +322,6 @@ func (candle BinanceCandle) toCandlePrice() (CandlePrice, error) {
 		candle.Metadata.TimeStamp)
 }
 
-func (p *BinanceProvider) handleWebSocketMsgs(ctx context.Context) {
-	reconnectTicker := time.NewTicker(defaultMaxConnectionTime)
-	defer reconnectTicker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(defaultReadNewWSMessage):
-			messageType, bz, err := p.wsClient.ReadMessage()
-			if err != nil {
-				// if some error occurs continue to try to read the next message.
-				p.logger.Err(err).Msg("could not read message")
-				continue
-			}
-
-			if len(bz) == 0 {
-				continue
-			}
-
-			p.messageReceived(messageType, bz)
-
-		case <-reconnectTicker.C:
-			if err := p.reconnect(); err != nil {
-				p.logger.Err(err).Msg("error reconnecting")
-				p.keepReconnecting()
-			}
-		}
-	}
-}
-
-// reconnect closes the last WS connection then create a new one and subscribe to
-// all subscribed pairs in the ticker and candle pais. A single connection to
-// stream.binance.com is only valid for 24 hours; expect to be disconnected at the
-// 24 hour mark. The websocket server will send a ping frame every 3 minutes. If
-// the websocket server does not receive a pong frame back from the connection
-// within a 10 minute period, the connection will be disconnected.
-func (p *BinanceProvider) reconnect() error {
-	p.wsClient.Close()
-
-	p.logger.Debug().Msg("reconnecting websocket")
-	wsConn, response, err := websocket.DefaultDialer.Dial(p.wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
-	if err != nil {
-		return fmt.Errorf("error reconnect to binance websocket: %w", err)
-	}
-	p.wsClient = wsConn
-
-	currencyPairs := p.subscribedPairsToSlice()
-
-	telemetry.IncrCounter(
-		1,
-		"websocket",
-		"reconnect",
-		"provider",
-		config.ProviderBinance,
-	)
-	return p.subscribeChannels(currencyPairs...)
-}
-
-// keepReconnecting keeps trying to reconnect if an error occurs in reconnect.
-func (p *BinanceProvider) keepReconnecting() {
-	reconnectTicker := time.NewTicker(defaultReconnectTime)
-	defer reconnectTicker.Stop()
-	connectionTries := 1
-
-	for time := range reconnectTicker.C {
-		if err := p.reconnect(); err != nil {
-			p.logger.Err(err).Msgf("attempted to reconnect %d times at %s", connectionTries, time.String())
-			connectionTries++
-			continue
-		}
-
-		if connectionTries > maxReconnectionTries {
-			p.logger.Warn().Msgf("failed to reconnect %d times", connectionTries)
-		}
-		return
-	}
-}
-
 // setSubscribedPairs sets N currency pairs to the map of subscribed pairs.
 func (p *BinanceProvider) setSubscribedPairs(cps ...types.CurrencyPair) {
 	p.mtx.Lock()
@@ -425,16 +332,15 @@ func (p *BinanceProvider) setSubscribedPairs(cps ...types.CurrencyPair) {
 	}
 }
 
-// subscribePairs write the subscription msg to the provider.
-func (p *BinanceProvider) subscribePairs(pairs ...string) error {
-	subsMsg := newBinanceSubscriptionMsg(pairs...)
-	return p.wsClient.WriteJSON(subsMsg)
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *BinanceProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
 }
 
 // GetAvailablePairs returns all pairs to which the provider can subscribe.
 // ex.: map["ATOMUSDT" => {}, "UMEEUSDC" => {}].
 func (p *BinanceProvider) GetAvailablePairs() (map[string]struct{}, error) {
-	resp, err := http.Get(p.endpoints.Rest + binanceRestPath)
+	resp, err := p.endpoints.HTTPGet(binanceRestPath)
 	if err != nil {
 		return nil, err
 	}
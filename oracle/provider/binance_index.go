@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+const (
+	binanceIndexWSHost   = "fstream.binance.com"
+	binanceIndexWSPath   = "/ws"
+	binanceIndexRestHost = "https://fapi.binance.com"
+	binanceIndexRestPath = "/fapi/v1/premiumIndex"
+	binanceIndexEvent    = "markPriceUpdate"
+)
+
+var (
+	_ Provider             = (*BinanceIndexProvider)(nil)
+	_ EndpointProvider     = (*BinanceIndexProvider)(nil)
+	_ SubscriptionProvider = (*BinanceIndexProvider)(nil)
+)
+
+type (
+	// BinanceIndexProvider defines an Oracle provider reporting the index
+	// prices of the Binance USDⓈ-M futures, weighted by a synthetic volume.
+	//
+	// REF: https://binance-docs.github.io/apidocs/futures/en/#mark-price-stream
+	// REF: https://binance-docs.github.io/apidocs/futures/en/#mark-price
+	BinanceIndexProvider struct {
+		wsShards  *WebsocketShards
+		logger    zerolog.Logger
+		endpoints *EndpointPool
+		*indexPriceTracker
+		*subscriptionTracker
+	}
+
+	// BinanceMarkPrice defines the mark price stream response. Every field is
+	// tagged since the keys only differ by their case.
+	BinanceMarkPrice struct {
+		Event                string `json:"e"` // Event type ex.: markPriceUpdate
+		EventTime            int64  `json:"E"` // Event time in unix milliseconds ex.: 1562305380000
+		Symbol               string `json:"s"` // Symbol ex.: BTCUSDT
+		MarkPrice            string `json:"p"` // Mark price ex.: 11794.15000000
+		IndexPrice           string `json:"i"` // Index price ex.: 11784.62659091
+		EstimatedSettlePrice string `json:"P"` // Estimated settle price ex.: 11784.25641265
+		FundingRate          string `json:"r"` // Funding rate ex.: 0.00038167
+		NextFundingTime      int64  `json:"T"` // Next funding time in unix milliseconds
+	}
+
+	// BinancePremiumIndex defines the response structure of the Binance
+	// premium index.
+	BinancePremiumIndex struct {
+		Symbol string `json:"symbol"` // Symbol ex.: BTCUSDT
+	}
+)
+
+// NewBinanceIndexProvider creates a new BinanceIndexProvider.
+func NewBinanceIndexProvider(
+	ctx context.Context,
+	logger zerolog.Logger,
+	endpoints config.ProviderEndpoint,
+	pairs ...types.CurrencyPair,
+) (*BinanceIndexProvider, error) {
+	endpoints = withDefaultEndpoint(endpoints, config.ProviderEndpoint{
+		Name:      config.ProviderBinanceIndex,
+		Rest:      binanceIndexRestHost,
+		Websocket: binanceIndexWSHost,
+	})
+
+	wsURL := url.URL{
+		Scheme: "wss",
+		Host:   endpoints.Websocket,
+		Path:   binanceIndexWSPath,
+	}
+
+	endpointPool, err := NewEndpointPool(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	indexPriceTracker, err := newIndexPriceTracker(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &BinanceIndexProvider{
+		logger:            logger.With().Str("provider", config.ProviderBinanceIndex).Logger(),
+		endpoints:         endpointPool,
+		indexPriceTracker: indexPriceTracker,
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderBinanceIndex, endpointPool.SilenceTimeout()),
+	}
+
+	provider.wsShards = NewWebsocketShards(
+		ctx,
+		config.ProviderBinanceIndex,
+		wsURL,
+		provider.endpoints,
+		endpoints.Connections,
+		provider.getSubscriptionMsgs,
+		provider.messageReceived,
+		disabledPingDuration,
+		websocket.PingMessage,
+		provider.logger,
+	)
+
+	if err := provider.SubscribeCurrencyPairs(pairs...); err != nil {
+		return nil, err
+	}
+
+	go provider.retrySubscriptions(ctx, provider.logger, provider.wsShards.ResubscribeCurrencyPairs)
+
+	return provider, nil
+}
+
+// SubscribeCurrencyPairs subscribe all currency pairs into the mark price
+// stream.
+func (p *BinanceIndexProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
+	if len(cps) == 0 {
+		return fmt.Errorf("currency pairs is empty")
+	}
+
+	p.trackSubscriptions(cps...)
+	return p.wsShards.SubscribeCurrencyPairs(cps...)
+}
+
+// getSubscriptionMsgs returns the message subscribing to the mark price stream
+// of the currency pairs.
+func (p *BinanceIndexProvider) getSubscriptionMsgs(cps ...types.CurrencyPair) []interface{} {
+	params := make([]string, len(cps))
+	for i, cp := range cps {
+		params[i] = currencyPairToBinanceMarkPricePair(cp)
+	}
+
+	return []interface{}{newBinanceSubscriptionMsg(params...)}
+}
+
+func (p *BinanceIndexProvider) messageReceived(messageType int, bz []byte) {
+	if messageType != websocket.TextMessage {
+		return
+	}
+
+	var markPrice BinanceMarkPrice
+	err := json.Unmarshal(bz, &markPrice)
+	if markPrice.Event == binanceIndexEvent {
+		p.setMarkPrice(markPrice)
+		return
+	}
+
+	// the subscription acknowledgement ex.: {"result":null,"id":1}
+	if strings.Contains(string(bz), `"result"`) {
+		return
+	}
+
+	p.logger.Error().
+		Int("length", len(bz)).
+		AnErr("index", err).
+		Msg("Error on receive message")
+}
+
+func (p *BinanceIndexProvider) setMarkPrice(markPrice BinanceMarkPrice) {
+	if err := p.setIndexPrice(markPrice.Symbol, markPrice.IndexPrice, markPrice.EventTime); err != nil {
+		p.logger.Warn().Err(err).Msg("failed to parse index price")
+		return
+	}
+
+	p.setSubscriptionActive(markPrice.Symbol)
+	telemetry.IncrCounter(
+		1,
+		"websocket",
+		"message",
+		"type",
+		"ticker",
+		"provider",
+		config.ProviderBinanceIndex,
+	)
+}
+
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *BinanceIndexProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
+}
+
+// GetAvailablePairs returns all the futures symbols publishing an index price.
+func (p *BinanceIndexProvider) GetAvailablePairs() (map[string]struct{}, error) {
+	resp, err := p.endpoints.HTTPGet(binanceIndexRestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var premiumIndices []BinancePremiumIndex
+	if err := json.NewDecoder(resp.Body).Decode(&premiumIndices); err != nil {
+		return nil, err
+	}
+
+	availablePairs := make(map[string]struct{}, len(premiumIndices))
+	for _, premiumIndex := range premiumIndices {
+		availablePairs[strings.ToUpper(premiumIndex.Symbol)] = struct{}{}
+	}
+
+	return availablePairs, nil
+}
+
+// currencyPairToBinanceMarkPricePair receives a currency pair and return the
+// binance mark price stream btcusdt@markPrice@1s.
+func currencyPairToBinanceMarkPricePair(cp types.CurrencyPair) string {
+	return strings.ToLower(cp.String()) + "@markPrice@1s"
+}
@@ -2,8 +2,11 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
@@ -116,3 +119,40 @@ func TestBinanceCurrencyPairToBinancePair(t *testing.T) {
 	binanceSymbol := currencyPairToBinanceTickerPair(cp)
 	require.Equal(t, binanceSymbol, "atomusdt@ticker")
 }
+
+func TestBinanceIndexProvider(t *testing.T) {
+	btcUSDT := types.CurrencyPair{Base: "BTC", Quote: "USDT"}
+	p, err := NewBinanceIndexProvider(
+		context.TODO(),
+		zerolog.Nop(),
+		config.ProviderEndpoint{},
+		btcUSDT,
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, []interface{}{BinanceSubscriptionMsg{
+		Method: "SUBSCRIBE",
+		Params: []string{"btcusdt@markPrice@1s"},
+		ID:     1,
+	}}, p.getSubscriptionMsgs(btcUSDT))
+
+	ts := time.Now().UnixMilli()
+	p.messageReceived(websocket.TextMessage, []byte(`{"result":null,"id":1}`))
+	p.messageReceived(websocket.TextMessage, []byte(fmt.Sprintf(
+		`{"e":"markPriceUpdate","E":%d,"s":"BTCUSDT","p":"11794.15000000","P":"11784.25641265",`+
+			`"i":"11784.62659091","r":"0.00038167","T":1562306400000}`,
+		ts,
+	)))
+
+	prices, err := p.GetTickerPrices(btcUSDT)
+	require.NoError(t, err)
+	require.Equal(t, math.LegacyMustNewDecFromStr("11784.62659091"), prices["BTCUSDT"].Price)
+	require.Equal(t, math.LegacyOneDec(), prices["BTCUSDT"].Volume)
+
+	candles, err := p.GetCandlePrices(btcUSDT)
+	require.NoError(t, err)
+	require.Len(t, candles["BTCUSDT"], 1)
+	require.Equal(t, ts, candles["BTCUSDT"][0].TimeStamp)
+
+	require.Equal(t, SubscriptionActive, p.SubscriptionStatuses()["BTCUSDT"].State)
+}
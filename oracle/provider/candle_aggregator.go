@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cosmossdk.io/math"
+)
+
+const (
+	// defaultTradeCandlePeriod is the width of the bars built from trades.
+	defaultTradeCandlePeriod = time.Minute
+)
+
+type (
+	// TradeCandleAggregator incrementally builds time-aligned OHLCV candles from
+	// a stream of trades. It is meant to be used by providers which only expose
+	// a trade feed (ex.: Coinbase matches) so they can report proper candles
+	// without keeping every trade in memory. Each symbol keeps at most
+	// retention / period candles, older candles are dropped as new ones arrive.
+	TradeCandleAggregator struct {
+		mtx        sync.RWMutex
+		period     int64                    // candle period in milliseconds
+		maxCandles int                      // max amount of candles kept per symbol
+		candles    map[string][]OHLCVCandle // Symbol => []OHLCVCandle (oldest -> newest)
+	}
+
+	// OHLCVCandle defines an open, high, low, close and volume bar for a given
+	// time window [StartTime, EndTime).
+	OHLCVCandle struct {
+		Open      math.LegacyDec
+		High      math.LegacyDec
+		Low       math.LegacyDec
+		Close     math.LegacyDec
+		Volume    math.LegacyDec
+		StartTime int64 // bar open time in unix milliseconds
+		EndTime   int64 // bar close time in unix milliseconds (exclusive)
+		FirstTime int64 // timestamp of the first trade added to the bar
+		LastTime  int64 // timestamp of the last trade added to the bar
+	}
+)
+
+// NewTradeCandleAggregator returns a new TradeCandleAggregator that builds
+// candles of the given period and keeps them for the retention duration.
+func NewTradeCandleAggregator(period, retention time.Duration) *TradeCandleAggregator {
+	if period <= 0 {
+		period = defaultTradeCandlePeriod
+	}
+
+	maxCandles := int(retention / period)
+	if maxCandles < 1 {
+		maxCandles = 1
+	}
+
+	return &TradeCandleAggregator{
+		period:     period.Milliseconds(),
+		maxCandles: maxCandles,
+		candles:    map[string][]OHLCVCandle{},
+	}
+}
+
+// AddTrade adds a trade to the candle its timestamp belongs to. Trades may
+// arrive out of order, in which case the open and close of the bar are only
+// updated if the trade is older than the first or newer than the last trade
+// already in the bar. Trades older than the retained window are ignored.
+func (a *TradeCandleAggregator) AddTrade(symbol string, price, size math.LegacyDec, timeStamp int64) error {
+	if !price.IsPositive() {
+		return fmt.Errorf("invalid trade price %s for %s", price, symbol)
+	}
+	if size.IsNegative() {
+		return fmt.Errorf("invalid trade size %s for %s", size, symbol)
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	startTime := timeStamp - timeStamp%a.period
+	candles := a.candles[symbol]
+
+	// find the position of the bar, candles are sorted by start time
+	i := sort.Search(len(candles), func(i int) bool {
+		return candles[i].StartTime >= startTime
+	})
+
+	if i < len(candles) && candles[i].StartTime == startTime {
+		candles[i].addTrade(price, size, timeStamp)
+		return nil
+	}
+
+	// the trade is older than every bar we still keep
+	if i == 0 && len(candles) >= a.maxCandles {
+		return nil
+	}
+
+	candle := OHLCVCandle{
+		Open:      price,
+		High:      price,
+		Low:       price,
+		Close:     price,
+		Volume:    size,
+		StartTime: startTime,
+		EndTime:   startTime + a.period,
+		FirstTime: timeStamp,
+		LastTime:  timeStamp,
+	}
+
+	candles = append(candles, OHLCVCandle{})
+	copy(candles[i+1:], candles[i:])
+	candles[i] = candle
+
+	// drop the oldest bars to keep memory bounded
+	if len(candles) > a.maxCandles {
+		candles = append([]OHLCVCandle{}, candles[len(candles)-a.maxCandles:]...)
+	}
+
+	a.candles[symbol] = candles
+	return nil
+}
+
+// GetOHLCVCandles returns a copy of the candles built for the symbol, sorted
+// from oldest to newest.
+func (a *TradeCandleAggregator) GetOHLCVCandles(symbol string) ([]OHLCVCandle, error) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+
+	candles, ok := a.candles[symbol]
+	if !ok || len(candles) == 0 {
+		return nil, fmt.Errorf("no trades have been received for %s", symbol)
+	}
+
+	return append([]OHLCVCandle{}, candles...), nil
+}
+
+// GetCandlePrices returns the candles built for the symbol as CandlePrices,
+// skipping any candle that was last updated before staleTime.
+func (a *TradeCandleAggregator) GetCandlePrices(symbol string, staleTime int64) ([]CandlePrice, error) {
+	candles, err := a.GetOHLCVCandles(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	candlePrices := make([]CandlePrice, 0, len(candles))
+	for _, candle := range candles {
+		if candle.LastTime <= staleTime {
+			continue
+		}
+		candlePrices = append(candlePrices, candle.toCandlePrice())
+	}
+
+	if len(candlePrices) == 0 {
+		return nil, fmt.Errorf("no recent trades have been received for %s", symbol)
+	}
+
+	return candlePrices, nil
+}
+
+// addTrade updates the bar with a trade that belongs to its time window.
+func (c *OHLCVCandle) addTrade(price, size math.LegacyDec, timeStamp int64) {
+	if timeStamp < c.FirstTime {
+		c.Open = price
+		c.FirstTime = timeStamp
+	}
+	if timeStamp >= c.LastTime {
+		c.Close = price
+		c.LastTime = timeStamp
+	}
+	if price.GT(c.High) {
+		c.High = price
+	}
+	if price.LT(c.Low) {
+		c.Low = price
+	}
+	c.Volume = c.Volume.Add(size)
+}
+
+// toCandlePrice converts the bar to a CandlePrice using the close price and
+// the time of the last trade, so an in-progress bar is never dated in the future.
+func (c OHLCVCandle) toCandlePrice() CandlePrice {
+	return CandlePrice{
+		Price:     c.Close,
+		Volume:    c.Volume,
+		TimeStamp: c.LastTime,
+	}
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+)
+
+func TestTradeCandleAggregator_AddTrade(t *testing.T) {
+	const symbol = "ATOM-USDT"
+	minute := time.Minute.Milliseconds()
+	start := int64(1_700_000_040_000) // aligned to the minute
+
+	t.Run("builds_ohlcv_bar", func(t *testing.T) {
+		a := NewTradeCandleAggregator(time.Minute, 10*time.Minute)
+
+		require.NoError(t, a.AddTrade(symbol, math.LegacyMustNewDecFromStr("10"), math.LegacyMustNewDecFromStr("1"), start+1000))
+		require.NoError(t, a.AddTrade(symbol, math.LegacyMustNewDecFromStr("12"), math.LegacyMustNewDecFromStr("2"), start+2000))
+		require.NoError(t, a.AddTrade(symbol, math.LegacyMustNewDecFromStr("9"), math.LegacyMustNewDecFromStr("3"), start+3000))
+		require.NoError(t, a.AddTrade(symbol, math.LegacyMustNewDecFromStr("11"), math.LegacyMustNewDecFromStr("4"), start+4000))
+
+		candles, err := a.GetOHLCVCandles(symbol)
+		require.NoError(t, err)
+		require.Len(t, candles, 1)
+		require.Equal(t, math.LegacyMustNewDecFromStr("10"), candles[0].Open)
+		require.Equal(t, math.LegacyMustNewDecFromStr("12"), candles[0].High)
+		require.Equal(t, math.LegacyMustNewDecFromStr("9"), candles[0].Low)
+		require.Equal(t, math.LegacyMustNewDecFromStr("11"), candles[0].Close)
+		require.Equal(t, math.LegacyMustNewDecFromStr("10"), candles[0].Volume)
+		require.Equal(t, start, candles[0].StartTime)
+		require.Equal(t, start+minute, candles[0].EndTime)
+	})
+
+	t.Run("out_of_order_trades", func(t *testing.T) {
+		a := NewTradeCandleAggregator(time.Minute, 10*time.Minute)
+
+		require.NoError(t, a.AddTrade(symbol, math.LegacyMustNewDecFromStr("11"), math.LegacyOneDec(), start+minute+500))
+		require.NoError(t, a.AddTrade(symbol, math.LegacyMustNewDecFromStr("10"), math.LegacyOneDec(), start+2000))
+		require.NoError(t, a.AddTrade(symbol, math.LegacyMustNewDecFromStr("8"), math.LegacyOneDec(), start+1000))
+
+		candles, err := a.GetOHLCVCandles(symbol)
+		require.NoError(t, err)
+		require.Len(t, candles, 2)
+		require.Equal(t, start, candles[0].StartTime)
+		require.Equal(t, math.LegacyMustNewDecFromStr("8"), candles[0].Open)
+		require.Equal(t, math.LegacyMustNewDecFromStr("10"), candles[0].Close)
+		require.Equal(t, start+minute, candles[1].StartTime)
+		require.Equal(t, math.LegacyMustNewDecFromStr("11"), candles[1].Close)
+	})
+
+	t.Run("bounded_memory", func(t *testing.T) {
+		a := NewTradeCandleAggregator(time.Minute, 3*time.Minute)
+
+		for i := int64(0); i < 5; i++ {
+			require.NoError(t, a.AddTrade(symbol, math.LegacyOneDec(), math.LegacyOneDec(), start+i*minute))
+		}
+		// a trade older than the retained window is ignored
+		require.NoError(t, a.AddTrade(symbol, math.LegacyOneDec(), math.LegacyOneDec(), start))
+
+		candles, err := a.GetOHLCVCandles(symbol)
+		require.NoError(t, err)
+		require.Len(t, candles, 3)
+		require.Equal(t, start+2*minute, candles[0].StartTime)
+		require.Equal(t, start+4*minute, candles[2].StartTime)
+	})
+
+	t.Run("invalid_trade", func(t *testing.T) {
+		a := NewTradeCandleAggregator(time.Minute, 10*time.Minute)
+
+		require.Error(t, a.AddTrade(symbol, math.LegacyZeroDec(), math.LegacyOneDec(), start))
+		require.Error(t, a.AddTrade(symbol, math.LegacyOneDec(), math.LegacyNewDec(-1), start))
+
+		_, err := a.GetOHLCVCandles(symbol)
+		require.Error(t, err)
+	})
+}
+
+func TestTradeCandleAggregator_GetCandlePrices(t *testing.T) {
+	const symbol = "ATOM-USDT"
+	a := NewTradeCandleAggregator(time.Minute, 10*time.Minute)
+
+	oldTrade := PastUnixTime(20 * time.Minute)
+	recentTrade := PastUnixTime(30 * time.Second)
+
+	require.NoError(t, a.AddTrade(symbol, math.LegacyMustNewDecFromStr("10"), math.LegacyOneDec(), oldTrade))
+	require.NoError(t, a.AddTrade(symbol, math.LegacyMustNewDecFromStr("12"), math.LegacyMustNewDecFromStr("2"), recentTrade))
+
+	candles, err := a.GetCandlePrices(symbol, PastUnixTime(providerCandlePeriod))
+	require.NoError(t, err)
+	require.Len(t, candles, 1)
+	require.Equal(t, math.LegacyMustNewDecFromStr("12"), candles[0].Price)
+	require.Equal(t, math.LegacyMustNewDecFromStr("2"), candles[0].Volume)
+	require.Equal(t, recentTrade, candles[0].TimeStamp)
+
+	_, err = a.GetCandlePrices("FOO-BAR", 0)
+	require.Error(t, err)
+}
@@ -3,10 +3,10 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
-	"sort"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -28,26 +28,46 @@ const (
 	coinbaseRestHost  = "https://api.exchange.coinbase.com"
 	coinbaseRestPath  = "/products"
 	timeLayout        = "2006-01-02T15:04:05.000000Z"
-	unixMinute        = 60000
+
+	// Advanced Trade API, used when the provider has credentials
+	coinbaseAdvancedWSHost   = "advanced-trade-ws.coinbase.com"
+	coinbaseAdvancedRestHost = "https://api.coinbase.com"
+	coinbaseAdvancedRestPath = "/api/v3/brokerage/products"
 )
 
-var _ Provider = (*CoinbaseProvider)(nil)
+// coinbaseAdvancedChannels are the Advanced Trade channels subscribed for every
+// pair, the heartbeats keep the connection open when the pairs are illiquid.
+var coinbaseAdvancedChannels = []string{"ticker", "market_trades", "heartbeats"}
+
+var (
+	_ Provider             = (*CoinbaseProvider)(nil)
+	_ SubscriptionProvider = (*CoinbaseProvider)(nil)
+
+	// coinbaseErrorProductIDRegex matches the product of a subscription error
+	// ex.: "FOO-USDT is not a valid product".
+	coinbaseErrorProductIDRegex = regexp.MustCompile(`[A-Z0-9]+-[A-Z0-9]+`)
+)
 
 type (
 	// CoinbaseProvider defines an Oracle provider implemented by the Coinbase public
-	// API.
+	// API. When credentials are configured, it uses the authenticated Advanced
+	// Trade API instead.
 	//
 	// REF: https://www.coinbase.io/docs/websocket/index.html
+	// REF: https://docs.cdp.coinbase.com/advanced-trade/docs/ws-overview
 	CoinbaseProvider struct {
 		wsURL           url.URL
 		wsClient        *websocket.Conn
+		writeMtx        sync.Mutex // serializes the writes to wsClient
 		logger          zerolog.Logger
 		reconnectTimer  *time.Ticker
 		mtx             sync.RWMutex
-		endpoints       config.ProviderEndpoint
-		trades          map[string][]CoinbaseTrade    // Symbol => []CoinbaseTrade
+		endpoints       *EndpointPool
+		signer          *coinbaseJWTSigner            // nil unless authenticated
+		tradeCandles    *TradeCandleAggregator        // builds candles from the "matches" channel
 		tickers         map[string]CoinbaseTicker     // Symbol => CoinbaseTicker
 		subscribedPairs map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		*subscriptionTracker
 	}
 
 	// CoinbaseSubscriptionMsg Msg to subscribe to all channels.
@@ -66,14 +86,6 @@ type (
 		Price     string `json:"price"`      // ex.: 14.02
 	}
 
-	// CoinbaseTrade defines the trade info we'd like to save.
-	CoinbaseTrade struct {
-		ProductID string // ex.: ATOM-USDT
-		Time      int64  // Time in unix epoch ex.: 164732388700
-		Size      string // Size of the trade ex.: 10.41
-		Price     string // ex.: 14.02
-	}
-
 	// CoinbaseTicker defines the ticker info we'd like to save.
 	CoinbaseTicker struct {
 		ProductID string `json:"product_id"` // ex.: ATOM-USDT
@@ -87,11 +99,77 @@ type (
 		Reason string `json:"reason"` // ex.: "tickers" is not a valid channel
 	}
 
+	// CoinbaseSubscriptionsResponse defines the response body acknowledging
+	// the subscriptions.
+	CoinbaseSubscriptionsResponse struct {
+		Type     string `json:"type"` // should be "subscriptions"
+		Channels []struct {
+			Name       string   `json:"name"`        // ex.: "ticker"
+			ProductIDs []string `json:"product_ids"` // ex.: ["ATOM-USDT", ...]
+		} `json:"channels"`
+	}
+
 	// CoinbasePairSummary defines the response structure for a Coinbase pair summary.
 	CoinbasePairSummary struct {
 		Base  string `json:"base_currency"`
 		Quote string `json:"quote_currency"`
 	}
+
+	// CoinbaseAdvancedSubscriptionMsg Msg to subscribe to an Advanced Trade
+	// channel.
+	CoinbaseAdvancedSubscriptionMsg struct {
+		Type       string   `json:"type"`        // ex. "subscribe"
+		ProductIDs []string `json:"product_ids"` // ex.: ["ATOM-USDT", ...]
+		Channel    string   `json:"channel"`     // ex.: "ticker"
+		JWT        string   `json:"jwt"`         // authenticates the subscription
+	}
+
+	// CoinbaseAdvancedMessage defines the envelope of the Advanced Trade
+	// websocket messages.
+	CoinbaseAdvancedMessage struct {
+		Channel string          `json:"channel"` // ex.: "ticker", "market_trades"
+		Type    string          `json:"type"`    // "error" for errors
+		Message string          `json:"message"` // error message
+		Events  json.RawMessage `json:"events"`
+	}
+
+	// CoinbaseAdvancedTickerEvent defines an event of the Advanced Trade
+	// "ticker" channel.
+	CoinbaseAdvancedTickerEvent struct {
+		Type    string                   `json:"type"` // "snapshot" or "update"
+		Tickers []CoinbaseAdvancedTicker `json:"tickers"`
+	}
+
+	// CoinbaseAdvancedTicker defines an Advanced Trade ticker.
+	CoinbaseAdvancedTicker struct {
+		ProductID string `json:"product_id"`  // ex.: ATOM-USDT
+		Price     string `json:"price"`       // ex.: 523.0
+		Volume    string `json:"volume_24_h"` // 24-hour volume
+	}
+
+	// CoinbaseAdvancedTradeEvent defines an event of the Advanced Trade
+	// "market_trades" channel.
+	CoinbaseAdvancedTradeEvent struct {
+		Type   string                  `json:"type"` // "snapshot" or "update"
+		Trades []CoinbaseAdvancedTrade `json:"trades"`
+	}
+
+	// CoinbaseAdvancedTrade defines an Advanced Trade trade.
+	CoinbaseAdvancedTrade struct {
+		ProductID string `json:"product_id"` // ex.: ATOM-USDT
+		Price     string `json:"price"`      // ex.: 14.02
+		Size      string `json:"size"`       // ex.: 10.41
+		Time      string `json:"time"`       // RFC3339 ex.: 2019-08-14T20:42:27.265Z
+	}
+
+	// CoinbaseAdvancedProducts defines the response structure of the Advanced
+	// Trade products.
+	CoinbaseAdvancedProducts struct {
+		Products []struct {
+			Base  string `json:"base_currency_id"`
+			Quote string `json:"quote_currency_id"`
+		} `json:"products"`
+	}
 )
 
 // NewCoinbaseProvider creates a new CoinbaseProvider.
@@ -101,24 +179,40 @@ func NewCoinbaseProvider(
 	endpoints config.ProviderEndpoint,
 	pairs ...types.CurrencyPair,
 ) (*CoinbaseProvider, error) {
-	if endpoints.Name != config.ProviderCoinbase {
-		endpoints = config.ProviderEndpoint{
-			Name:      config.ProviderCoinbase,
-			Rest:      coinbaseRestHost,
-			Websocket: coinbaseWSHost,
+	defaultEndpoint := config.ProviderEndpoint{
+		Name:      config.ProviderCoinbase,
+		Rest:      coinbaseRestHost,
+		Websocket: coinbaseWSHost,
+	}
+
+	var signer *coinbaseJWTSigner
+	if endpoints.Name == config.ProviderCoinbase {
+		credentials, err := endpoints.Credentials.Load()
+		if err != nil {
+			return nil, fmt.Errorf("coinbase: %w", err)
+		}
+
+		if !credentials.IsEmpty() {
+			if signer, err = newCoinbaseJWTSigner(credentials); err != nil {
+				return nil, err
+			}
+			defaultEndpoint.Rest = coinbaseAdvancedRestHost
+			defaultEndpoint.Websocket = coinbaseAdvancedWSHost
 		}
 	}
+
+	endpoints = withDefaultEndpoint(endpoints, defaultEndpoint)
 	wsURL := url.URL{
 		Scheme: "wss",
 		Host:   endpoints.Websocket,
 	}
 
-	wsConn, response, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	endpointPool, err := NewEndpointPool(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	wsConn, err := endpointPool.DialWebsocket(wsURL)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to Coinbase websocket: %w", err)
 	}
@@ -128,10 +222,13 @@ func NewCoinbaseProvider(
 		wsClient:        wsConn,
 		logger:          logger.With().Str("provider", "coinbase").Logger(),
 		reconnectTimer:  time.NewTicker(coinbasePingCheck),
-		endpoints:       endpoints,
-		trades:          map[string][]CoinbaseTrade{},
+		endpoints:       endpointPool,
+		signer:          signer,
+		tradeCandles:    NewTradeCandleAggregator(defaultTradeCandlePeriod, providerCandlePeriod),
 		tickers:         map[string]CoinbaseTicker{},
 		subscribedPairs: map[string]types.CurrencyPair{},
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderCoinbase, endpointPool.SilenceTimeout()),
 	}
 	provider.wsClient.SetPongHandler(provider.pongHandler)
 
@@ -140,6 +237,7 @@ func NewCoinbaseProvider(
 	}
 
 	go provider.handleReceivedMessages(ctx)
+	go provider.retrySubscriptions(ctx, provider.logger, provider.subscribe)
 
 	return provider, nil
 }
@@ -161,74 +259,24 @@ func (p *CoinbaseProvider) GetTickerPrices(pairs ...types.CurrencyPair) (map[str
 	return tickerPrices, nil
 }
 
-// GetCandlePrices returns candles based off of the saved trades map.
-// Candles need to be cut up into one-minute intervals.
+// GetCandlePrices returns the one-minute candles built from the trades
+// received on the "matches" channel.
 func (p *CoinbaseProvider) GetCandlePrices(pairs ...types.CurrencyPair) (map[string][]CandlePrice, error) {
-	tradeMap := make(map[string][]CoinbaseTrade, len(pairs))
+	candles := make(map[string][]CandlePrice, len(pairs))
+	staleTime := PastUnixTime(providerCandlePeriod)
 
 	for _, cp := range pairs {
-		key := currencyPairToCoinbasePair(cp)
-		tradeSet, err := p.getTradePrices(key)
+		candlePrices, err := p.tradeCandles.GetCandlePrices(currencyPairToCoinbasePair(cp), staleTime)
 		if err != nil {
 			p.logger.Debug().AnErr("err", err).Msg(fmt.Sprint("failed to fetch candles for pair ", cp))
 			continue
 		}
-		tradeMap[key] = tradeSet
+		candles[cp.String()] = candlePrices
 	}
-	if len(tradeMap) == 0 {
+	if len(candles) == 0 {
 		return nil, fmt.Errorf("no trades have been received")
 	}
 
-	candles := make(map[string][]CandlePrice)
-
-	for cp := range tradeMap {
-		trades := tradeMap[cp]
-		// sort oldest -> newest
-		sort.Slice(trades, func(i, j int) bool {
-			return time.Unix(trades[i].Time, 0).Before(time.Unix(trades[j].Time, 0))
-		})
-
-		candleSlice := []CandlePrice{
-			{
-				Price:  math.LegacyZeroDec(),
-				Volume: math.LegacyZeroDec(),
-			},
-		}
-		startTime := trades[0].Time
-		index := 0
-
-		// divide into chunks by minute
-		for _, trade := range trades {
-			// every minute, reset the time period
-			if trade.Time-startTime > unixMinute {
-				index++
-				startTime = trade.Time
-				candleSlice = append(candleSlice, CandlePrice{
-					Price:  math.LegacyZeroDec(),
-					Volume: math.LegacyZeroDec(),
-				})
-			}
-
-			size, err := math.LegacyNewDecFromStr(trade.Size)
-			if err != nil {
-				return nil, err
-			}
-			price, err := math.LegacyNewDecFromStr(trade.Price)
-			if err != nil {
-				return nil, err
-			}
-
-			volume := candleSlice[index].Volume.Add(size)
-			candleSlice[index] = CandlePrice{
-				Volume:    volume,     // aggregate size
-				Price:     price,      // most recent price
-				TimeStamp: trade.Time, // most recent timestamp
-			}
-		}
-
-		candles[coinbasePairToCurrencyPair(cp)] = candleSlice
-	}
-
 	return candles, nil
 }
 
@@ -243,6 +291,7 @@ func (p *CoinbaseProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) err
 	}
 
 	p.setSubscribedPairs(cps...)
+	p.trackSubscriptions(cps...)
 	telemetry.IncrCounter(
 		float32(len(cps)),
 		"websocket",
@@ -254,9 +303,18 @@ func (p *CoinbaseProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) err
 	return nil
 }
 
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *CoinbaseProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
+}
+
 // GetAvailablePairs returns all pairs to which the provider can subscribe.
 func (p *CoinbaseProvider) GetAvailablePairs() (map[string]struct{}, error) {
-	resp, err := http.Get(p.endpoints.Rest + coinbaseRestPath)
+	if p.signer != nil {
+		return p.getAdvancedAvailablePairs()
+	}
+
+	resp, err := p.endpoints.HTTPGet(coinbaseRestPath)
 	if err != nil {
 		return nil, err
 	}
@@ -279,7 +337,33 @@ func (p *CoinbaseProvider) GetAvailablePairs() (map[string]struct{}, error) {
 	return availablePairs, nil
 }
 
-// subscribe subscribes to the coinbase "ticker" and "match" websockets.
+// getAdvancedAvailablePairs returns all pairs of the Advanced Trade API.
+func (p *CoinbaseProvider) getAdvancedAvailablePairs() (map[string]struct{}, error) {
+	resp, err := p.endpoints.HTTPGetAuthenticated(coinbaseAdvancedRestPath, p.signer.authenticateRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var products CoinbaseAdvancedProducts
+	if err := json.NewDecoder(resp.Body).Decode(&products); err != nil {
+		return nil, err
+	}
+
+	availablePairs := make(map[string]struct{}, len(products.Products))
+	for _, product := range products.Products {
+		cp := types.CurrencyPair{
+			Base:  strings.ToUpper(product.Base),
+			Quote: strings.ToUpper(product.Quote),
+		}
+		availablePairs[cp.String()] = struct{}{}
+	}
+
+	return availablePairs, nil
+}
+
+// subscribe subscribes to the coinbase "ticker" and "match" websockets, or
+// their Advanced Trade equivalents when authenticated.
 func (p *CoinbaseProvider) subscribe(cps ...types.CurrencyPair) error {
 	topics := make([]string, len(cps))
 	index := 0
@@ -289,10 +373,37 @@ func (p *CoinbaseProvider) subscribe(cps ...types.CurrencyPair) error {
 		index++
 	}
 
+	if p.signer != nil {
+		return p.subscribeAdvanced(topics...)
+	}
+
 	tickerMsg := newCoinbaseSubscription(topics...)
 	return p.subscribePairs(tickerMsg)
 }
 
+// subscribeAdvanced sends an authenticated subscription to every Advanced
+// Trade channel, each signed with a fresh JWT.
+func (p *CoinbaseProvider) subscribeAdvanced(productIDs ...string) error {
+	for _, channel := range coinbaseAdvancedChannels {
+		jwt, err := p.signer.websocketJWT()
+		if err != nil {
+			return fmt.Errorf("coinbase: failed to sign subscription: %w", err)
+		}
+
+		msg := CoinbaseAdvancedSubscriptionMsg{
+			Type:       "subscribe",
+			ProductIDs: productIDs,
+			Channel:    channel,
+			JWT:        jwt,
+		}
+		if err := p.sendJSON(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // subscribedPairsToSlice returns the map of subscribed pairs as a slice.
 func (p *CoinbaseProvider) subscribedPairsToSlice() []types.CurrencyPair {
 	p.mtx.RLock()
@@ -313,26 +424,22 @@ func (p *CoinbaseProvider) getTickerPrice(cp types.CurrencyPair) (TickerPrice, e
 	return TickerPrice{}, fmt.Errorf("failed to get ticker price for %s", gp)
 }
 
-func (p *CoinbaseProvider) getTradePrices(key string) ([]CoinbaseTrade, error) {
-	p.mtx.RLock()
-	defer p.mtx.RUnlock()
-
-	trades, ok := p.trades[key]
-	if !ok {
-		return []CoinbaseTrade{}, fmt.Errorf("failed to get trades for %s", key)
-	}
-
-	return trades, nil
-}
-
 func (p *CoinbaseProvider) handleReceivedMessages(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-time.After(defaultReadNewWSMessage):
-			messageType, bz, err := p.wsClient.ReadMessage()
+			messageType, bz, err := p.endpoints.ReadMessage(p.wsClient)
 			if err != nil {
+				if errors.Is(err, ErrSilentConnection) {
+					p.logger.Warn().Err(err).Msg("reconnecting silent websocket")
+					if err := p.reconnect(); err != nil {
+						p.logger.Err(err).Msg("error reconnecting")
+					}
+					continue
+				}
+
 				// if some error occurs continue to try to read the next message.
 				p.logger.Err(err).Msg("could not read message")
 				if err := p.ping(); err != nil {
@@ -361,6 +468,11 @@ func (p *CoinbaseProvider) messageReceived(messageType int, bz []byte) {
 		return
 	}
 
+	if p.signer != nil {
+		p.advancedMessageReceived(bz)
+		return
+	}
+
 	var coinbaseTrade CoinbaseTradeResponse
 	if err := json.Unmarshal(bz, &coinbaseTrade); err != nil {
 		p.logger.Error().Err(err).Msg("unable to unmarshal response")
@@ -373,10 +485,21 @@ func (p *CoinbaseProvider) messageReceived(messageType int, bz []byte) {
 			p.logger.Debug().Err(err).Msg("unable to unmarshal error response")
 		}
 		p.logger.Error().Msg(coinbaseErr.Reason)
+		p.setSubscriptionsFailed(coinbaseErr.Reason)
 		return
 	}
 
 	if coinbaseTrade.Type == "subscriptions" { // successful subscription message
+		var subscriptions CoinbaseSubscriptionsResponse
+		if err := json.Unmarshal(bz, &subscriptions); err != nil {
+			p.logger.Debug().Err(err).Msg("unable to unmarshal subscriptions response")
+			return
+		}
+		for _, channel := range subscriptions.Channels {
+			for _, productID := range channel.ProductIDs {
+				p.setSubscriptionActive(coinbasePairToCurrencyPair(productID))
+			}
+		}
 		return
 	}
 
@@ -411,6 +534,75 @@ func (p *CoinbaseProvider) messageReceived(messageType int, bz []byte) {
 	p.setTradePair(coinbaseTrade)
 }
 
+// advancedMessageReceived handles the messages of the Advanced Trade channels.
+func (p *CoinbaseProvider) advancedMessageReceived(bz []byte) {
+	var msg CoinbaseAdvancedMessage
+	if err := json.Unmarshal(bz, &msg); err != nil {
+		p.logger.Error().Err(err).Msg("unable to unmarshal response")
+		return
+	}
+
+	if msg.Type == "error" {
+		p.logger.Error().Msg(msg.Message)
+		p.setSubscriptionsFailed(msg.Message)
+		return
+	}
+
+	switch msg.Channel {
+	case "ticker":
+		var events []CoinbaseAdvancedTickerEvent
+		if err := json.Unmarshal(msg.Events, &events); err != nil {
+			p.logger.Error().Err(err).Msg("unable to unmarshal ticker events")
+			return
+		}
+
+		for _, event := range events {
+			for _, ticker := range event.Tickers {
+				p.setTickerPair(CoinbaseTicker{
+					ProductID: ticker.ProductID,
+					Price:     ticker.Price,
+					Volume:    ticker.Volume,
+				})
+			}
+		}
+		telemetry.IncrCounter(
+			1,
+			"websocket",
+			"message",
+			"type",
+			"ticker",
+			"provider",
+			config.ProviderCoinbase,
+		)
+
+	case "market_trades":
+		var events []CoinbaseAdvancedTradeEvent
+		if err := json.Unmarshal(msg.Events, &events); err != nil {
+			p.logger.Error().Err(err).Msg("unable to unmarshal trade events")
+			return
+		}
+
+		for _, event := range events {
+			for _, trade := range event.Trades {
+				var timeStamp int64
+				if t, err := time.Parse(time.RFC3339Nano, trade.Time); err == nil {
+					timeStamp = t.UnixMilli()
+				}
+				p.addTrade(trade.ProductID, trade.Price, trade.Size, timeStamp)
+			}
+		}
+		telemetry.IncrCounter(
+			1,
+			"websocket",
+			"message",
+			"type",
+			"trade",
+			"provider",
+			config.ProviderCoinbase,
+		)
+	}
+}
+
 // timeToUnix converts a Time in format "2006-01-02T15:04:05.000000Z" to unix
 func (tr CoinbaseTradeResponse) timeToUnix() int64 {
 	t, err := time.Parse(timeLayout, tr.Time)
@@ -420,43 +612,57 @@ func (tr CoinbaseTradeResponse) timeToUnix() int64 {
 	return t.UnixMilli()
 }
 
-func (tr CoinbaseTradeResponse) toTrade() CoinbaseTrade {
-	return CoinbaseTrade{
-		Time:      tr.timeToUnix(),
-		Price:     tr.Price,
-		ProductID: tr.ProductID,
-		Size:      tr.Size,
-	}
-}
-
 func (p *CoinbaseProvider) setTickerPair(ticker CoinbaseTicker) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
 	p.tickers[ticker.ProductID] = ticker
+	p.setSubscriptionActive(coinbasePairToCurrencyPair(ticker.ProductID))
+}
+
+// setSubscriptionsFailed sets the pairs of the products named in the error
+// as failed.
+func (p *CoinbaseProvider) setSubscriptionsFailed(reason string) {
+	for _, productID := range coinbaseErrorProductIDRegex.FindAllString(reason, -1) {
+		p.setSubscriptionFailed(coinbasePairToCurrencyPair(productID), reason)
+	}
 }
 
-// setTradePair takes a CoinbaseTradeResponse, converts its date into unix epoch,
-// and then will add it to a copy of the trade slice. Then it filters out any
-// "stale" trades, and sets the trade slice in memory to the copy.
+// setTradePair parses a CoinbaseTradeResponse and adds it to the candle
+// aggregator of its product.
 func (p *CoinbaseProvider) setTradePair(tradeResponse CoinbaseTradeResponse) {
-	p.mtx.Lock()
-	defer p.mtx.Unlock()
-	staleTime := PastUnixTime(providerCandlePeriod)
-	tradeList := []CoinbaseTrade{
-		tradeResponse.toTrade(),
+	p.addTrade(tradeResponse.ProductID, tradeResponse.Price, tradeResponse.Size, tradeResponse.timeToUnix())
+}
+
+// addTrade parses the price and size of a trade and adds it to the candle
+// aggregator of its product.
+func (p *CoinbaseProvider) addTrade(productID, tradePrice, tradeSize string, timeStamp int64) {
+	price, err := math.LegacyNewDecFromStr(tradePrice)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("coinbase: failed to parse trade price")
+		return
+	}
+	size, err := math.LegacyNewDecFromStr(tradeSize)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("coinbase: failed to parse trade size")
+		return
 	}
 
-	for _, t := range p.trades[tradeResponse.ProductID] {
-		if staleTime < t.Time {
-			tradeList = append(tradeList, t)
-		}
+	if err := p.tradeCandles.AddTrade(productID, price, size, timeStamp); err != nil {
+		p.logger.Warn().Err(err).Msg("coinbase: failed to add trade")
 	}
-	p.trades[tradeResponse.ProductID] = tradeList
 }
 
 // subscribePairs write the subscription msg to the provider.
 func (p *CoinbaseProvider) subscribePairs(msg CoinbaseSubscriptionMsg) error {
+	return p.sendJSON(msg)
+}
+
+// sendJSON writes the msg to the websocket.
+func (p *CoinbaseProvider) sendJSON(msg interface{}) error {
+	p.writeMtx.Lock()
+	defer p.writeMtx.Unlock()
+
 	return p.wsClient.WriteJSON(msg)
 }
 
@@ -486,19 +692,17 @@ func (p *CoinbaseProvider) resetReconnectTimer() {
 // N seconds, please raise an error or reconnect.
 func (p *CoinbaseProvider) reconnect() error {
 	p.wsClient.Close()
+	p.endpoints.ReportDisconnect()
 
 	p.logger.Debug().Msg("reconnecting websocket")
-	wsConn, response, err := websocket.DefaultDialer.Dial(p.wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	wsConn, err := p.endpoints.DialWebsocket(p.wsURL)
 	if err != nil {
 		return fmt.Errorf("error reconnecting to Coinbase websocket: %w", err)
 	}
 	wsConn.SetPongHandler(p.pongHandler)
+	p.writeMtx.Lock()
 	p.wsClient = wsConn
+	p.writeMtx.Unlock()
 
 	currencyPairs := p.subscribedPairsToSlice()
 
@@ -514,6 +718,9 @@ func (p *CoinbaseProvider) reconnect() error {
 
 // ping to check websocket connection.
 func (p *CoinbaseProvider) ping() error {
+	p.writeMtx.Lock()
+	defer p.writeMtx.Unlock()
+
 	return p.wsClient.WriteMessage(websocket.PingMessage, ping)
 }
 
@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+const (
+	coinbaseJWTIssuer   = "cdp"
+	coinbaseJWTLifetime = 2 * time.Minute
+)
+
+type (
+	// coinbaseJWTSigner signs the ES256 JWTs authenticating the websocket
+	// subscriptions and rest requests of the Coinbase Advanced Trade API.
+	//
+	// REF: https://docs.cdp.coinbase.com/advanced-trade/docs/rest-api-auth
+	coinbaseJWTSigner struct {
+		keyName    string // ex.: organizations/{org_id}/apiKeys/{key_id}
+		privateKey *ecdsa.PrivateKey
+	}
+
+	coinbaseJWTHeader struct {
+		Alg   string `json:"alg"`
+		Kid   string `json:"kid"`
+		Nonce string `json:"nonce"`
+		Typ   string `json:"typ"`
+	}
+
+	coinbaseJWTClaims struct {
+		Sub string `json:"sub"`
+		Iss string `json:"iss"`
+		Nbf int64  `json:"nbf"`
+		Exp int64  `json:"exp"`
+		URI string `json:"uri,omitempty"` // ex.: "GET api.coinbase.com/api/v3/brokerage/products"
+	}
+)
+
+// newCoinbaseJWTSigner returns a signer using the API key name and its PEM
+// encoded EC private key. Escaped newlines in the private key, as found in
+// environment variables, are supported.
+func newCoinbaseJWTSigner(credentials config.Credentials) (*coinbaseJWTSigner, error) {
+	secret := strings.ReplaceAll(credentials.Secret, `\n`, "\n")
+	block, _ := pem.Decode([]byte(secret))
+	if block == nil {
+		return nil, fmt.Errorf("coinbase: api secret is not a PEM encoded private key")
+	}
+
+	privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		key, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return nil, fmt.Errorf("coinbase: failed to parse api secret: %w", err)
+		}
+
+		var ok bool
+		if privateKey, ok = key.(*ecdsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("coinbase: api secret must be an EC private key")
+		}
+	}
+
+	if privateKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("coinbase: api secret must be a P-256 private key")
+	}
+
+	return &coinbaseJWTSigner{
+		keyName:    credentials.Key,
+		privateKey: privateKey,
+	}, nil
+}
+
+// websocketJWT returns a JWT authenticating a websocket subscription.
+func (s *coinbaseJWTSigner) websocketJWT() (string, error) {
+	return s.sign("")
+}
+
+// authenticateRequest sets the bearer JWT authenticating a single rest request.
+func (s *coinbaseJWTSigner) authenticateRequest(req *http.Request) error {
+	jwt, err := s.sign(req.Method + " " + req.URL.Host + req.URL.Path)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	return nil
+}
+
+func (s *coinbaseJWTSigner) sign(uri string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	header, err := json.Marshal(coinbaseJWTHeader{
+		Alg:   "ES256",
+		Kid:   s.keyName,
+		Nonce: hex.EncodeToString(nonce),
+		Typ:   "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(coinbaseJWTClaims{
+		Sub: s.keyName,
+		Iss: coinbaseJWTIssuer,
+		Nbf: now.Unix(),
+		Exp: now.Add(coinbaseJWTLifetime).Unix(),
+		URI: uri,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	r, sig, err := ecdsa.Sign(rand.Reader, s.privateKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// ES256 signatures are the 32 bytes big-endian r and s concatenated
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+const testCoinbaseKeyName = "organizations/org/apiKeys/key"
+
+// newTestCoinbaseSecret returns a P-256 private key and its PEM encoding with
+// escaped newlines, as set in an environment variable.
+func newTestCoinbaseSecret(t *testing.T) (*ecdsa.PrivateKey, string) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	require.NoError(t, err)
+
+	secret := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	return privateKey, strings.ReplaceAll(string(secret), "\n", `\n`)
+}
+
+// verifyCoinbaseJWT checks the signature of the JWT and returns its claims.
+func verifyCoinbaseJWT(t *testing.T, publicKey *ecdsa.PublicKey, jwt string) coinbaseJWTClaims {
+	parts := strings.Split(jwt, ".")
+	require.Len(t, parts, 3)
+
+	var header coinbaseJWTHeader
+	bz, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(bz, &header))
+	require.Equal(t, "ES256", header.Alg)
+	require.Equal(t, testCoinbaseKeyName, header.Kid)
+	require.Len(t, header.Nonce, 32)
+
+	var claims coinbaseJWTClaims
+	bz, err = base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(bz, &claims))
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	require.Len(t, signature, 64)
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	require.True(t, ecdsa.Verify(publicKey, digest[:], r, s))
+
+	return claims
+}
+
+func TestCoinbaseJWTSigner(t *testing.T) {
+	privateKey, secret := newTestCoinbaseSecret(t)
+
+	signer, err := newCoinbaseJWTSigner(config.Credentials{Key: testCoinbaseKeyName, Secret: secret})
+	require.NoError(t, err)
+
+	jwt, err := signer.websocketJWT()
+	require.NoError(t, err)
+
+	claims := verifyCoinbaseJWT(t, &privateKey.PublicKey, jwt)
+	require.Equal(t, testCoinbaseKeyName, claims.Sub)
+	require.Equal(t, coinbaseJWTIssuer, claims.Iss)
+	require.Equal(t, int64(coinbaseJWTLifetime.Seconds()), claims.Exp-claims.Nbf)
+	require.Empty(t, claims.URI)
+
+	req, err := http.NewRequest(http.MethodGet, coinbaseAdvancedRestHost+coinbaseAdvancedRestPath, nil)
+	require.NoError(t, err)
+	require.NoError(t, signer.authenticateRequest(req))
+
+	jwt, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	require.True(t, found)
+	claims = verifyCoinbaseJWT(t, &privateKey.PublicKey, jwt)
+	require.Equal(t, "GET api.coinbase.com/api/v3/brokerage/products", claims.URI)
+
+	_, err = newCoinbaseJWTSigner(config.Credentials{Key: testCoinbaseKeyName, Secret: "secret"})
+	require.ErrorContains(t, err, "not a PEM encoded private key")
+}
+
+func TestCoinbaseProvider_Authenticated(t *testing.T) {
+	_, secret := newTestCoinbaseSecret(t)
+	t.Setenv("TEST_COINBASE_API_KEY", testCoinbaseKeyName)
+	t.Setenv("TEST_COINBASE_API_SECRET", secret)
+
+	subscriptions := make(chan CoinbaseAdvancedSubscriptionMsg, len(coinbaseAdvancedChannels))
+	server := NewMockProviderServer()
+	server.SetHandler(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		for range coinbaseAdvancedChannels {
+			var msg CoinbaseAdvancedSubscriptionMsg
+			if err := c.ReadJSON(&msg); err != nil {
+				return
+			}
+			subscriptions <- msg
+		}
+
+		_ = c.WriteMessage(1, []byte(`{"channel":"ticker","events":[{"type":"snapshot","tickers":[
+			{"type":"ticker","product_id":"ATOM-USDT","price":"10.5","volume_24_h":"1200"}]}]}`))
+		_ = c.WriteMessage(1, []byte(`{"channel":"market_trades","events":[{"type":"update","trades":[
+			{"product_id":"ATOM-USDT","price":"10.4","size":"3","side":"BUY","time":"`+
+			time.Now().UTC().Format(time.RFC3339Nano)+`"}]}]}`))
+
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	atomUSDT := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
+	p, err := NewCoinbaseProvider(
+		context.TODO(),
+		zerolog.Nop(),
+		config.ProviderEndpoint{
+			Name:      config.ProviderCoinbase,
+			Rest:      "https://" + server.GetBaseURL(),
+			Websocket: server.GetBaseURL(),
+			Credentials: config.ProviderCredentials{
+				KeyEnv:    "TEST_COINBASE_API_KEY",
+				SecretEnv: "TEST_COINBASE_API_SECRET",
+			},
+		},
+		atomUSDT,
+	)
+	require.NoError(t, err)
+
+	for _, channel := range coinbaseAdvancedChannels {
+		msg := <-subscriptions
+		require.Equal(t, channel, msg.Channel)
+		require.Equal(t, []string{"ATOM-USDT"}, msg.ProductIDs)
+		require.NotEmpty(t, msg.JWT)
+	}
+
+	require.Eventually(t, func() bool {
+		prices, _ := p.GetTickerPrices(atomUSDT)
+		candles, _ := p.GetCandlePrices(atomUSDT)
+		return len(prices) == 1 && len(candles) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	prices, err := p.GetTickerPrices(atomUSDT)
+	require.NoError(t, err)
+	require.Equal(t, math.LegacyMustNewDecFromStr("10.5"), prices["ATOMUSDT"].Price)
+	require.Equal(t, math.LegacyMustNewDecFromStr("1200"), prices["ATOMUSDT"].Volume)
+
+	candles, err := p.GetCandlePrices(atomUSDT)
+	require.NoError(t, err)
+	require.Equal(t, math.LegacyMustNewDecFromStr("10.4"), candles["ATOMUSDT"][0].Price)
+	require.Equal(t, math.LegacyMustNewDecFromStr("3"), candles["ATOMUSDT"][0].Volume)
+}
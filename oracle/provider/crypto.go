@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"net/url"
 	"strings"
 	"sync"
@@ -32,7 +31,10 @@ const (
 	cryptoCandleMsgPrefix    = "candlestick.5m."
 )
 
-var _ Provider = (*CryptoProvider)(nil)
+var (
+	_ Provider             = (*CryptoProvider)(nil)
+	_ SubscriptionProvider = (*CryptoProvider)(nil)
+)
 
 type (
 	// CryptoProvider defines an Oracle provider implemented by the Crypto.com public
@@ -43,10 +45,11 @@ type (
 		wsc             *WebsocketController
 		logger          zerolog.Logger
 		mtx             sync.RWMutex
-		endpoint        config.ProviderEndpoint
+		endpoints       *EndpointPool
 		tickers         map[string]TickerPrice        // Symbol => TickerPrice
 		candles         map[string][]CandlePrice      // Symbol => CandlePrice
 		subscribedPairs map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		*subscriptionTracker
 	}
 
 	CryptoTickerResponse struct {
@@ -110,13 +113,11 @@ func NewCryptoProvider(
 	endpoint config.ProviderEndpoint,
 	pairs ...types.CurrencyPair,
 ) (*CryptoProvider, error) {
-	if endpoint.Name != config.ProviderCrypto {
-		endpoint = config.ProviderEndpoint{
-			Name:      config.ProviderCrypto,
-			Rest:      cryptoRestHost,
-			Websocket: cryptoWSHost,
-		}
-	}
+	endpoint = withDefaultEndpoint(endpoint, config.ProviderEndpoint{
+		Name:      config.ProviderCrypto,
+		Rest:      cryptoRestHost,
+		Websocket: cryptoWSHost,
+	})
 
 	wsURL := url.URL{
 		Scheme: "wss",
@@ -124,20 +125,29 @@ func NewCryptoProvider(
 		Path:   cryptoWSPath,
 	}
 
+	endpointPool, err := NewEndpointPool(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
 	provider := &CryptoProvider{
 		logger:          logger.With().Str("provider", "crypto").Logger(),
-		endpoint:        endpoint,
+		endpoints:       endpointPool,
 		tickers:         map[string]TickerPrice{},
 		candles:         map[string][]CandlePrice{},
 		subscribedPairs: map[string]types.CurrencyPair{},
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderCrypto, endpointPool.SilenceTimeout()),
 	}
 
 	provider.setSubscribedPairs(pairs...)
+	provider.trackSubscriptions(pairs...)
 
 	provider.wsc = NewWebsocketController(
 		ctx,
 		config.ProviderCrypto,
 		wsURL,
+		provider.endpoints,
 		provider.getSubscriptionMsgs(pairs...),
 		provider.messageReceived,
 		disabledPingDuration,
@@ -146,6 +156,7 @@ func NewCryptoProvider(
 	)
 
 	go provider.wsc.Start()
+	go provider.retrySubscriptions(ctx, provider.logger, provider.resubscribeCurrencyPairs)
 
 	return provider, nil
 }
@@ -185,9 +196,16 @@ func (p *CryptoProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error
 	}
 
 	p.setSubscribedPairs(newPairs...)
+	p.trackSubscriptions(newPairs...)
 	return nil
 }
 
+// resubscribeCurrencyPairs sends the subscription messages of already
+// subscribed pairs again.
+func (p *CryptoProvider) resubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
+	return p.wsc.subscribe(p.getSubscriptionMsgs(cps...))
+}
+
 // GetTickerPrices returns the tickerPrices based on the saved map.
 func (p *CryptoProvider) GetTickerPrices(pairs ...types.CurrencyPair) (map[string]TickerPrice, error) {
 	tickerPrices := make(map[string]TickerPrice, len(pairs))
@@ -347,6 +365,7 @@ func (p *CryptoProvider) setTickerPair(symbol string, tickerPair CryptoTicker) {
 	}
 
 	p.tickers[symbol] = tickerPrice
+	p.setSubscriptionActive(strings.ReplaceAll(symbol, "_", ""))
 }
 
 func (p *CryptoProvider) setCandlePair(symbol string, candlePair CryptoCandle) {
@@ -385,10 +404,15 @@ func (p *CryptoProvider) setSubscribedPairs(cps ...types.CurrencyPair) {
 	}
 }
 
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *CryptoProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
+}
+
 // GetAvailablePairs returns all pairs to which the provider can subscribe.
 // ex.: map["ATOMUSDT" => {}, "UMEEUSDC" => {}].
 func (p *CryptoProvider) GetAvailablePairs() (map[string]struct{}, error) {
-	resp, err := http.Get(p.endpoint.Rest + cryptoRestPath)
+	resp, err := p.endpoints.HTTPGet(cryptoRestPath)
 	if err != nil {
 		return nil, err
 	}
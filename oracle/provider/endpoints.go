@@ -0,0 +1,312 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-metrics"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+const (
+	// endpointDisconnectWindow is the period in which the disconnects of the
+	// active endpoint are counted.
+	endpointDisconnectWindow = 5 * time.Minute
+	// maxEndpointDisconnects is the amount of disconnects within the
+	// endpointDisconnectWindow after which the next endpoint is used.
+	maxEndpointDisconnects = 3
+)
+
+// ErrSilentConnection is returned when a websocket connection has not received
+// any message within the silence timeout of its endpoint.
+var ErrSilentConnection = errors.New("websocket connection is silent")
+
+// EndpointPool holds the ordered endpoints of a provider. The first endpoint
+// is used until its websocket fails to dial or disconnects repeatedly, then
+// the pool rotates to the next one, wrapping around after the last endpoint.
+// Both the rest and websocket connections go through the proxy of the
+// endpoint, if any, and the websocket connections negotiate permessage-deflate
+// compression if enabled.
+type EndpointPool struct {
+	mtx         sync.RWMutex
+	provider    string
+	endpoints   []config.ProviderEndpoint
+	active      int
+	disconnects []int64 // unix milliseconds of the recent disconnects of the active endpoint
+	proxy       *url.URL
+	httpClient  *http.Client
+	// silenceTimeout is the time without messages after which a websocket
+	// connection or the subscription of a pair is considered silent
+	silenceTimeout time.Duration
+	// compression enables the negotiation of permessage-deflate compression
+	compression bool
+	// frameDecoder decodes the frames read from the websocket connections
+	frameDecoder FrameDecoder
+}
+
+// withDefaultEndpoint returns the endpoint if it belongs to the provider,
+// otherwise its default endpoint. An endpoint only overriding settings such as
+// the proxy or the credentials keeps the default rest and websocket endpoints.
+func withDefaultEndpoint(endpoint, defaultEndpoint config.ProviderEndpoint) config.ProviderEndpoint {
+	if endpoint.Name != defaultEndpoint.Name {
+		return defaultEndpoint
+	}
+
+	if len(endpoint.Rest) > 0 || len(endpoint.Websocket) > 0 {
+		return endpoint
+	}
+
+	endpoint.Rest = defaultEndpoint.Rest
+	endpoint.Websocket = defaultEndpoint.Websocket
+	if len(endpoint.Fallbacks) == 0 {
+		endpoint.Fallbacks = defaultEndpoint.Fallbacks
+	}
+	return endpoint
+}
+
+// NewEndpointPool returns an EndpointPool with the endpoint followed by its
+// fallbacks.
+func NewEndpointPool(endpoint config.ProviderEndpoint) (*EndpointPool, error) {
+	endpoints := []config.ProviderEndpoint{{
+		Name:      endpoint.Name,
+		Rest:      endpoint.Rest,
+		Websocket: endpoint.Websocket,
+	}}
+	for _, fallback := range endpoint.Fallbacks {
+		endpoints = append(endpoints, config.ProviderEndpoint{
+			Name:      endpoint.Name,
+			Rest:      fallback.Rest,
+			Websocket: fallback.Websocket,
+		})
+	}
+
+	pool := &EndpointPool{
+		provider:       endpoint.Name,
+		endpoints:      endpoints,
+		httpClient:     http.DefaultClient,
+		silenceTimeout: defaultSilenceTimeout,
+		compression:    endpoint.EnableCompression,
+	}
+
+	if len(endpoint.SilenceTimeout) > 0 {
+		silenceTimeout, err := time.ParseDuration(endpoint.SilenceTimeout)
+		if err != nil {
+			return nil, err
+		}
+		pool.silenceTimeout = silenceTimeout
+	}
+
+	if len(endpoint.Proxy) > 0 {
+		proxyURL, err := config.ParseProxyURL(endpoint.Proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+
+		pool.proxy = proxyURL
+		pool.httpClient = &http.Client{Transport: transport}
+	}
+
+	pool.setActiveGauge()
+
+	return pool, nil
+}
+
+// Active returns the endpoint currently in use.
+func (ep *EndpointPool) Active() config.ProviderEndpoint {
+	ep.mtx.RLock()
+	defer ep.mtx.RUnlock()
+
+	return ep.endpoints[ep.active]
+}
+
+// SilenceTimeout returns the time without messages after which a websocket
+// connection is reconnected and a pair is resubscribed.
+func (ep *EndpointPool) SilenceTimeout() time.Duration {
+	return ep.silenceTimeout
+}
+
+// SetFrameDecoder sets the decoder of the frames read by ReadMessage.
+func (ep *EndpointPool) SetFrameDecoder(decoder FrameDecoder) {
+	ep.mtx.Lock()
+	defer ep.mtx.Unlock()
+
+	ep.frameDecoder = decoder
+}
+
+// ReadMessage reads the next message of the websocket connection, failing
+// with a timeout error once no message is received within the silence
+// timeout. The message is decoded by the frame decoder, if any, skipping the
+// frames failing to decode.
+func (ep *EndpointPool) ReadMessage(conn *websocket.Conn) (int, []byte, error) {
+	ep.mtx.RLock()
+	decoder := ep.frameDecoder
+	ep.mtx.RUnlock()
+
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(ep.silenceTimeout)); err != nil {
+			return 0, nil, err
+		}
+
+		messageType, bz, err := conn.ReadMessage()
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			telemetry.IncrCounter(1, "websocket", "silence", "provider", ep.provider)
+			return messageType, bz, fmt.Errorf("%w: no message received within %s", ErrSilentConnection, ep.silenceTimeout)
+		}
+		if err != nil || decoder == nil || len(bz) == 0 {
+			return messageType, bz, err
+		}
+
+		decodedType, decoded, err := decoder(messageType, bz)
+		if err != nil {
+			telemetry.IncrCounter(1, "websocket", "frame", "decode_error", "provider", ep.provider)
+			continue
+		}
+		return decodedType, decoded, nil
+	}
+}
+
+// DialWebsocket dials the websocket of the active endpoint using the scheme,
+// path and query of wsURL. On failure, the next endpoints are dialed in order
+// until one succeeds or all of them have failed.
+func (ep *EndpointPool) DialWebsocket(wsURL url.URL) (*websocket.Conn, error) {
+	ep.mtx.RLock()
+	attempts := len(ep.endpoints)
+	ep.mtx.RUnlock()
+
+	var errs error
+	for i := 0; i < attempts; i++ {
+		endpoint := ep.Active()
+		wsURL.Host = endpoint.Websocket
+
+		conn, response, err := ep.websocketDialer().Dial(wsURL.String(), nil)
+		if response != nil {
+			response.Body.Close()
+		}
+		if err == nil {
+			return conn, nil
+		}
+
+		errs = errors.Join(errs, fmt.Errorf("%s: %w", endpoint.Websocket, err))
+		ep.rotate()
+	}
+
+	return nil, errs
+}
+
+// HTTPGet issues a GET to the path of the active rest endpoint.
+func (ep *EndpointPool) HTTPGet(path string) (*http.Response, error) {
+	return ep.httpClient.Get(ep.Active().Rest + path)
+}
+
+// HTTPGetAuthenticated issues a GET to the path of the active rest endpoint
+// after authenticate has signed the request, ex. by setting its headers.
+func (ep *EndpointPool) HTTPGetAuthenticated(
+	path string,
+	authenticate func(*http.Request) error,
+) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, ep.Active().Rest+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authenticate(req); err != nil {
+		return nil, err
+	}
+
+	return ep.httpClient.Do(req)
+}
+
+// websocketDialer returns the default dialer, going through the proxy if any
+// and offering permessage-deflate compression if enabled. Servers not
+// supporting the compression ignore the offer.
+func (ep *EndpointPool) websocketDialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = ep.compression
+	if ep.proxy != nil {
+		dialer.Proxy = http.ProxyURL(ep.proxy)
+	}
+	return &dialer
+}
+
+// ReportDisconnect records a disconnect of the active endpoint and rotates to
+// the next endpoint once it disconnected maxEndpointDisconnects times within
+// the endpointDisconnectWindow.
+func (ep *EndpointPool) ReportDisconnect() {
+	ep.mtx.Lock()
+	staleTime := PastUnixTime(endpointDisconnectWindow)
+	disconnects := []int64{time.Now().UnixMilli()}
+	for _, ts := range ep.disconnects {
+		if ts > staleTime {
+			disconnects = append(disconnects, ts)
+		}
+	}
+	ep.disconnects = disconnects
+	shouldRotate := len(disconnects) >= maxEndpointDisconnects
+	ep.mtx.Unlock()
+
+	if shouldRotate {
+		ep.rotate()
+	}
+}
+
+// rotate switches to the next endpoint, if any.
+func (ep *EndpointPool) rotate() {
+	ep.mtx.Lock()
+	defer ep.mtx.Unlock()
+
+	ep.disconnects = nil
+	if len(ep.endpoints) < 2 {
+		return
+	}
+
+	ep.active = (ep.active + 1) % len(ep.endpoints)
+	ep.setActiveGaugeLocked()
+
+	telemetry.IncrCounter(
+		1,
+		"websocket",
+		"endpoint",
+		"rotate",
+		"provider",
+		ep.provider,
+	)
+}
+
+func (ep *EndpointPool) setActiveGauge() {
+	ep.mtx.RLock()
+	defer ep.mtx.RUnlock()
+
+	ep.setActiveGaugeLocked()
+}
+
+// setActiveGaugeLocked reports 1 for the active endpoint and 0 for the others.
+func (ep *EndpointPool) setActiveGaugeLocked() {
+	for i, endpoint := range ep.endpoints {
+		var value float32
+		if i == ep.active {
+			value = 1
+		}
+
+		telemetry.SetGaugeWithLabels(
+			[]string{"websocket", "endpoint", "active"},
+			value,
+			[]metrics.Label{
+				telemetry.NewLabel("provider", ep.provider),
+				telemetry.NewLabel("endpoint", endpoint.Websocket),
+			},
+		)
+	}
+}
@@ -0,0 +1,346 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+func TestEndpointPool_DialWebsocket(t *testing.T) {
+	s := NewMockProviderServer()
+	s.Start()
+	defer s.Close()
+
+	pool, err := NewEndpointPool(config.ProviderEndpoint{
+		Name:      config.ProviderMock,
+		Rest:      "https://unreachable",
+		Websocket: "127.0.0.1:1",
+		Fallbacks: []config.EndpointFallback{
+			{Rest: "https://" + s.GetBaseURL(), Websocket: s.GetBaseURL()},
+		},
+	})
+	require.NoError(t, err)
+
+	conn, err := pool.DialWebsocket(url.URL{Scheme: "wss", Path: "/ws"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the pool rotated to the fallback after the first endpoint failed to dial
+	require.Equal(t, s.GetBaseURL(), pool.Active().Websocket)
+	require.Equal(t, "https://"+s.GetBaseURL(), pool.Active().Rest)
+}
+
+func TestEndpointPool_DialWebsocketAllFailing(t *testing.T) {
+	pool, err := NewEndpointPool(config.ProviderEndpoint{
+		Name:      config.ProviderMock,
+		Websocket: "127.0.0.1:1",
+		Fallbacks: []config.EndpointFallback{
+			{Websocket: "127.0.0.1:2"},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = pool.DialWebsocket(url.URL{Scheme: "ws"})
+	require.ErrorContains(t, err, "127.0.0.1:1")
+	require.ErrorContains(t, err, "127.0.0.1:2")
+
+	// every endpoint was tried, so the pool wrapped around to the first one
+	require.Equal(t, "127.0.0.1:1", pool.Active().Websocket)
+}
+
+func TestEndpointPool_ReadMessage(t *testing.T) {
+	s := NewMockProviderServer()
+	s.Start()
+	defer s.Close()
+
+	pool, err := NewEndpointPool(config.ProviderEndpoint{
+		Name:           config.ProviderMock,
+		Websocket:      s.GetBaseURL(),
+		SilenceTimeout: "200ms",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 200*time.Millisecond, pool.SilenceTimeout())
+
+	conn, err := pool.DialWebsocket(url.URL{Scheme: "wss"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the mock server echoes the messages
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	_, bz, err := pool.ReadMessage(conn)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(bz))
+
+	_, _, err = pool.ReadMessage(conn)
+	require.ErrorIs(t, err, ErrSilentConnection)
+
+	_, err = NewEndpointPool(config.ProviderEndpoint{Name: config.ProviderMock, SilenceTimeout: "5"})
+	require.Error(t, err)
+}
+
+func TestEndpointPool_FrameDecoder(t *testing.T) {
+	s := NewMockProviderServer()
+	s.Start()
+	defer s.Close()
+
+	pool, err := NewEndpointPool(config.ProviderEndpoint{
+		Name:      config.ProviderMock,
+		Websocket: s.GetBaseURL(),
+	})
+	require.NoError(t, err)
+	pool.SetFrameDecoder(GzipFrameDecoder)
+
+	conn, err := pool.DialWebsocket(url.URL{Scheme: "wss"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err = gw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	// the frames failing to decode are skipped
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte("invalid")))
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, gzipped.Bytes()))
+	messageType, bz, err := pool.ReadMessage(conn)
+	require.NoError(t, err)
+	require.Equal(t, websocket.TextMessage, messageType)
+	require.Equal(t, "hello", string(bz))
+}
+
+func TestEndpointPool_Compression(t *testing.T) {
+	testCases := []struct {
+		name    string
+		enabled bool
+		offer   string
+	}{
+		{"enabled", true, "permessage-deflate; server_no_context_takeover; client_no_context_takeover"},
+		{"disabled", false, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			extensions := make(chan string, 1)
+			s := NewMockProviderServer()
+			s.SetHandler(func(w http.ResponseWriter, r *http.Request) {
+				compressionUpgrader := websocket.Upgrader{EnableCompression: true}
+				c, err := compressionUpgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer c.Close()
+
+				extensions <- r.Header.Get("Sec-Websocket-Extensions")
+				_ = c.WriteMessage(websocket.TextMessage, []byte("hello"))
+				_, _, _ = c.ReadMessage()
+			})
+			defer s.Close()
+
+			pool, err := NewEndpointPool(config.ProviderEndpoint{
+				Name:              config.ProviderMock,
+				Websocket:         s.GetBaseURL(),
+				EnableCompression: tc.enabled,
+			})
+			require.NoError(t, err)
+
+			conn, err := pool.DialWebsocket(url.URL{Scheme: "wss"})
+			require.NoError(t, err)
+			defer conn.Close()
+
+			require.Equal(t, tc.offer, <-extensions)
+			_, bz, err := pool.ReadMessage(conn)
+			require.NoError(t, err)
+			require.Equal(t, "hello", string(bz))
+		})
+	}
+}
+
+func TestEndpointPool_ReportDisconnect(t *testing.T) {
+	pool, err := NewEndpointPool(config.ProviderEndpoint{
+		Name:      config.ProviderMock,
+		Websocket: "first",
+		Fallbacks: []config.EndpointFallback{
+			{Websocket: "second"},
+		},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < maxEndpointDisconnects-1; i++ {
+		pool.ReportDisconnect()
+	}
+	require.Equal(t, "first", pool.Active().Websocket)
+
+	pool.ReportDisconnect()
+	require.Equal(t, "second", pool.Active().Websocket)
+
+	// the disconnects of the previous endpoint are not carried over
+	pool.ReportDisconnect()
+	require.Equal(t, "second", pool.Active().Websocket)
+
+	single, err := NewEndpointPool(config.ProviderEndpoint{Name: config.ProviderMock, Websocket: "only"})
+	require.NoError(t, err)
+	for i := 0; i < maxEndpointDisconnects; i++ {
+		single.ReportDisconnect()
+	}
+	require.Equal(t, "only", single.Active().Websocket)
+}
+
+// newTestHTTPProxy returns a HTTP proxy supporting CONNECT tunnels which
+// records the target of every request.
+func newTestHTTPProxy(t *testing.T, targets chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expectedAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+		if r.Header.Get("Proxy-Authorization") != expectedAuth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		if r.Method != http.MethodConnect {
+			targets <- r.URL.String()
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		targets <- r.Host
+		upstream, err := net.Dial("tcp", r.Host)
+		require.NoError(t, err)
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		require.NoError(t, err)
+
+		go func() {
+			defer upstream.Close()
+			_, _ = io.Copy(upstream, conn)
+		}()
+		go func() {
+			defer conn.Close()
+			_, _ = io.Copy(conn, upstream)
+		}()
+	}))
+}
+
+func TestEndpointPool_Proxy(t *testing.T) {
+	targets := make(chan string, 2)
+	proxy := newTestHTTPProxy(t, targets)
+	defer proxy.Close()
+
+	exchange := httptest.NewServer(http.HandlerFunc(echo))
+	defer exchange.Close()
+	exchangeHost := strings.TrimPrefix(exchange.URL, "http://")
+
+	pool, err := NewEndpointPool(config.ProviderEndpoint{
+		Name:      config.ProviderMock,
+		Rest:      "http://exchange.test",
+		Websocket: exchangeHost,
+		Proxy:     "http://user:pass@" + strings.TrimPrefix(proxy.URL, "http://"),
+	})
+	require.NoError(t, err)
+
+	resp, err := pool.HTTPGet("/api/v3/ticker/price")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "http://exchange.test/api/v3/ticker/price", <-targets)
+
+	conn, err := pool.DialWebsocket(url.URL{Scheme: "ws"})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, exchangeHost, <-targets)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	_, bz, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(bz))
+
+	_, err = NewEndpointPool(config.ProviderEndpoint{Name: config.ProviderMock, Proxy: "ftp://127.0.0.1"})
+	require.ErrorContains(t, err, "unsupported proxy scheme")
+}
+
+func TestWithDefaultEndpoint(t *testing.T) {
+	defaultEndpoint := config.ProviderEndpoint{
+		Name:      config.ProviderBinance,
+		Rest:      "https://api1.binance.com",
+		Websocket: "stream.binance.com:9443",
+	}
+
+	testCases := []struct {
+		name     string
+		endpoint config.ProviderEndpoint
+		expected config.ProviderEndpoint
+	}{
+		{
+			name:     "not_configured",
+			endpoint: config.ProviderEndpoint{},
+			expected: defaultEndpoint,
+		},
+		{
+			name: "other_provider",
+			endpoint: config.ProviderEndpoint{
+				Name:  config.ProviderOkx,
+				Proxy: "socks5://127.0.0.1:1080",
+			},
+			expected: defaultEndpoint,
+		},
+		{
+			name: "proxy_only",
+			endpoint: config.ProviderEndpoint{
+				Name:  config.ProviderBinance,
+				Proxy: "socks5://127.0.0.1:1080",
+			},
+			expected: config.ProviderEndpoint{
+				Name:      config.ProviderBinance,
+				Rest:      "https://api1.binance.com",
+				Websocket: "stream.binance.com:9443",
+				Proxy:     "socks5://127.0.0.1:1080",
+			},
+		},
+		{
+			name: "connections_only",
+			endpoint: config.ProviderEndpoint{
+				Name:        config.ProviderBinance,
+				Connections: 4,
+			},
+			expected: config.ProviderEndpoint{
+				Name:        config.ProviderBinance,
+				Rest:        "https://api1.binance.com",
+				Websocket:   "stream.binance.com:9443",
+				Connections: 4,
+			},
+		},
+		{
+			name: "overridden",
+			endpoint: config.ProviderEndpoint{
+				Name:      config.ProviderBinance,
+				Rest:      "https://api2.binance.com",
+				Websocket: "stream.binance.com:443",
+			},
+			expected: config.ProviderEndpoint{
+				Name:      config.ProviderBinance,
+				Rest:      "https://api2.binance.com",
+				Websocket: "stream.binance.com:443",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, withDefaultEndpoint(tc.endpoint, defaultEndpoint))
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/gorilla/websocket"
+)
+
+// FrameDecoder decodes the payload of a websocket frame before it reaches the
+// message handler of the provider, returning the decoded message type and
+// payload. It is set per provider on its EndpointPool, so the read loops and
+// the WebsocketController relay already decoded messages.
+//
+// Unlike the negotiated permessage-deflate compression, which the websocket
+// library handles transparently, these decoders undo the encodings applied by
+// the venues themselves to the frames payload, ex. gzip for Huobi and protobuf
+// for the MEXC v3 API.
+type FrameDecoder func(messageType int, bz []byte) (int, []byte, error)
+
+// GzipFrameDecoder decompresses the gzip compressed binary frames into text
+// messages, ex. every frame of the Huobi market API. Text frames are kept as
+// is.
+func GzipFrameDecoder(messageType int, bz []byte) (int, []byte, error) {
+	if messageType != websocket.BinaryMessage {
+		return messageType, bz, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(bz))
+	if err != nil {
+		return messageType, nil, err
+	}
+	defer r.Close()
+
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return messageType, nil, err
+	}
+	return websocket.TextMessage, decoded, nil
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameDecoders(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write([]byte(`{"ping":1}`))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	testCases := []struct {
+		name        string
+		decoder     FrameDecoder
+		messageType int
+		bz          []byte
+		expectType  int
+		expected    string
+		expectErr   bool
+	}{
+		{"gzip binary", GzipFrameDecoder, websocket.BinaryMessage, gzipped.Bytes(), websocket.TextMessage, `{"ping":1}`, false},
+		{"gzip text", GzipFrameDecoder, websocket.TextMessage, []byte("pong"), websocket.TextMessage, "pong", false},
+		{"gzip invalid", GzipFrameDecoder, websocket.BinaryMessage, []byte("pong"), 0, "", true},
+		{
+			"mexc protobuf",
+			MexcProtoFrameDecoder,
+			websocket.BinaryMessage,
+			newMexcPushData("spot@public.kline.v3.api.pb@ATOMUSDT@Min1", "ATOMUSDT", mexcWrapperKlineField,
+				newMexcKline(1700000000, "10.5", "120")),
+			websocket.TextMessage,
+			`{"channel":"spot@public.kline.v3.api.pb@ATOMUSDT@Min1","symbol":"ATOMUSDT","sendTime":1700000000123,` +
+				`"kline":{"interval":"Min1","windowStart":1700000000,"closingPrice":"10.5","volume":"120","windowEnd":1700000060}}`,
+			false,
+		},
+		{"mexc text", MexcProtoFrameDecoder, websocket.TextMessage, []byte(`{"msg":"PONG"}`), websocket.TextMessage, `{"msg":"PONG"}`, false},
+		{"mexc invalid", MexcProtoFrameDecoder, websocket.BinaryMessage, []byte{0x0a, 0x05, 'A'}, 0, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			messageType, bz, err := tc.decoder(tc.messageType, tc.bz)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectType, messageType)
+			require.Equal(t, tc.expected, string(bz))
+		})
+	}
+}
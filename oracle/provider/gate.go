@@ -3,8 +3,8 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -28,7 +28,10 @@ const (
 	gateRestPath  = "/api/v4/spot/currency_pairs"
 )
 
-var _ Provider = (*GateProvider)(nil)
+var (
+	_ Provider             = (*GateProvider)(nil)
+	_ SubscriptionProvider = (*GateProvider)(nil)
+)
 
 type (
 	// GateProvider defines an Oracle provider implemented by the Gate public
@@ -38,13 +41,15 @@ type (
 	GateProvider struct {
 		wsURL           url.URL
 		wsClient        *websocket.Conn
+		writeMtx        sync.Mutex // serializes the writes to wsClient
 		logger          zerolog.Logger
 		reconnectTimer  *time.Ticker
 		mtx             sync.RWMutex
-		endpoints       config.ProviderEndpoint
+		endpoints       *EndpointPool
 		tickers         map[string]GateTicker         // Symbol => GateTicker
 		candles         map[string][]GateCandle       // Symbol => GateCandle
 		subscribedPairs map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		*subscriptionTracker
 	}
 
 	GateTicker struct {
@@ -151,13 +156,11 @@ func NewGateProvider(
 	endpoints config.ProviderEndpoint,
 	pairs ...types.CurrencyPair,
 ) (*GateProvider, error) {
-	if endpoints.Name != config.ProviderGate {
-		endpoints = config.ProviderEndpoint{
-			Name:      config.ProviderGate,
-			Rest:      gateRestHost,
-			Websocket: gateWSHost,
-		}
-	}
+	endpoints = withDefaultEndpoint(endpoints, config.ProviderEndpoint{
+		Name:      config.ProviderGate,
+		Rest:      gateRestHost,
+		Websocket: gateWSHost,
+	})
 
 	wsURL := url.URL{
 		Scheme: "wss",
@@ -165,12 +168,12 @@ func NewGateProvider(
 		Path:   gateWSPath,
 	}
 
-	wsConn, response, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	endpointPool, err := NewEndpointPool(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	wsConn, err := endpointPool.DialWebsocket(wsURL)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to Gate websocket: %w", err)
 	}
@@ -180,10 +183,12 @@ func NewGateProvider(
 		wsClient:        wsConn,
 		logger:          logger.With().Str("provider", "gate").Logger(),
 		reconnectTimer:  time.NewTicker(gatePingCheck),
-		endpoints:       endpoints,
+		endpoints:       endpointPool,
 		tickers:         map[string]GateTicker{},
 		candles:         map[string][]GateCandle{},
 		subscribedPairs: map[string]types.CurrencyPair{},
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderGate, endpointPool.SilenceTimeout()),
 	}
 	provider.wsClient.SetPongHandler(provider.pongHandler)
 
@@ -192,6 +197,7 @@ func NewGateProvider(
 	}
 
 	go provider.handleReceivedTickers(ctx)
+	go provider.retrySubscriptions(ctx, provider.logger, provider.subscribeTickers)
 
 	return provider, nil
 }
@@ -264,6 +270,7 @@ func (p *GateProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
 		return err
 	}
 	p.setSubscribedPairs(cps...)
+	p.trackSubscriptions(cps...)
 	telemetry.IncrCounter(
 		float32(len(cps)),
 		"websocket",
@@ -335,8 +342,16 @@ func (p *GateProvider) handleReceivedTickers(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-time.After(defaultReadNewWSMessage):
-			messageType, bz, err := p.wsClient.ReadMessage()
+			messageType, bz, err := p.endpoints.ReadMessage(p.wsClient)
 			if err != nil {
+				if errors.Is(err, ErrSilentConnection) {
+					p.logger.Warn().Err(err).Msg("reconnecting silent websocket")
+					if err := p.reconnect(); err != nil {
+						p.logger.Err(err).Msg("error reconnecting")
+					}
+					continue
+				}
+
 				// if some error occurs continue to try to read the next message.
 				p.logger.Err(err).Msg("could not read message")
 				if err := p.ping(); err != nil {
@@ -529,6 +544,7 @@ func (p *GateProvider) setTickerPair(ticker GateTicker) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 	p.tickers[ticker.Symbol] = ticker
+	p.setSubscriptionActive(strings.ReplaceAll(ticker.Symbol, "_", ""))
 }
 
 func (p *GateProvider) setCandlePair(candle GateCandle) {
@@ -550,11 +566,19 @@ func (p *GateProvider) setCandlePair(candle GateCandle) {
 
 // subscribeTickerPairs write the subscription msg to the provider.
 func (p *GateProvider) subscribeTickerPairs(msg GateTickerSubscriptionMsg) error {
-	return p.wsClient.WriteJSON(msg)
+	return p.sendJSON(msg)
 }
 
 // subscribeCandlePair write the subscription msg to the provider.
 func (p *GateProvider) subscribeCandlePair(msg GateCandleSubscriptionMsg) error {
+	return p.sendJSON(msg)
+}
+
+// sendJSON writes the msg to the websocket.
+func (p *GateProvider) sendJSON(msg interface{}) error {
+	p.writeMtx.Lock()
+	defer p.writeMtx.Unlock()
+
 	return p.wsClient.WriteJSON(msg)
 }
 
@@ -584,19 +608,17 @@ func (p *GateProvider) resetReconnectTimer() {
 // N seconds, please raise an error or reconnect.
 func (p *GateProvider) reconnect() error {
 	p.wsClient.Close()
+	p.endpoints.ReportDisconnect()
 
 	p.logger.Debug().Msg("reconnecting websocket")
-	wsConn, response, err := websocket.DefaultDialer.Dial(p.wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	wsConn, err := p.endpoints.DialWebsocket(p.wsURL)
 	if err != nil {
 		return fmt.Errorf("error reconnecting to Gate websocket: %w", err)
 	}
 	wsConn.SetPongHandler(p.pongHandler)
+	p.writeMtx.Lock()
 	p.wsClient = wsConn
+	p.writeMtx.Unlock()
 
 	currencyPairs := p.subscribedPairsToSlice()
 
@@ -612,6 +634,9 @@ func (p *GateProvider) reconnect() error {
 
 // ping to check websocket connection.
 func (p *GateProvider) ping() error {
+	p.writeMtx.Lock()
+	defer p.writeMtx.Unlock()
+
 	return p.wsClient.WriteMessage(websocket.PingMessage, ping)
 }
 
@@ -620,9 +645,14 @@ func (p *GateProvider) pongHandler(_ string) error {
 	return nil
 }
 
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *GateProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
+}
+
 // GetAvailablePairs returns all pairs to which the provider can subscribe.
 func (p *GateProvider) GetAvailablePairs() (map[string]struct{}, error) {
-	resp, err := http.Get(p.endpoints.Rest + gateRestPath)
+	resp, err := p.endpoints.HTTPGet(gateRestPath)
 	if err != nil {
 		return nil, err
 	}
@@ -2,12 +2,10 @@ package provider
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -31,7 +29,10 @@ const (
 	huobiRestPath      = "/market/tickers"
 )
 
-var _ Provider = (*HuobiProvider)(nil)
+var (
+	_ OrderBookProvider    = (*HuobiProvider)(nil)
+	_ SubscriptionProvider = (*HuobiProvider)(nil)
+)
 
 type (
 	// HuobiProvider defines an Oracle provider implemented by the Huobi public
@@ -40,22 +41,26 @@ type (
 	// REF: https://huobiapi.github.io/docs/spot/v1/en/#market-ticker
 	// REF: https://huobiapi.github.io/docs/spot/v1/en/#get-klines-candles
 	HuobiProvider struct {
-		wsURL           url.URL
-		wsClient        *websocket.Conn
-		logger          zerolog.Logger
-		mtx             sync.RWMutex
-		endpoints       config.ProviderEndpoint
-		tickers         map[string]HuobiTicker        // market.$symbol.ticker => HuobiTicker
-		candles         map[string][]HuobiCandle      // market.$symbol.kline.$period => HuobiCandle
-		subscribedPairs map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		wsURL             url.URL
+		wsClient          *websocket.Conn
+		writeMtx          sync.Mutex // serializes the writes to wsClient
+		logger            zerolog.Logger
+		mtx               sync.RWMutex
+		endpoints         *EndpointPool
+		tickers           map[string]HuobiTicker        // market.$symbol.ticker => HuobiTicker
+		candles           map[string][]HuobiCandle      // market.$symbol.kline.$period => HuobiCandle
+		subscribedPairs   map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		*orderBookTracker                               // market.$symbol.depth.step0 => OrderBook
+		*subscriptionTracker
 	}
 
 	// HuobiSubscriptionResult defines the response type for the subscription
 	HuobiSubscriptionResult struct {
 		ID     *string `json:"id"`
-		Status string  `json:"status"` // "ok" or "error"
-		Subbed string  `json:"subbed"` // Channel name. Format：market.$symbol.ticker
-		TS     int64   `json:"ts"`     // Timestamp in milliseconds
+		Status string  `json:"status"`  // "ok" or "error"
+		Subbed string  `json:"subbed"`  // Channel name. Format：market.$symbol.ticker
+		TS     int64   `json:"ts"`      // Timestamp in milliseconds
+		ErrMsg string  `json:"err-msg"` // error description ex.: "invalid topic market.foousdt.ticker"
 	}
 
 	// HuobiTicker defines the response type for the channel and the tick object for a
@@ -86,6 +91,19 @@ type (
 		Volume    float64 `json:"vol"`   // Volume during this period
 	}
 
+	// HuobiDepth defines the response type for the channel and the tick object for a
+	// given order book.
+	HuobiDepth struct {
+		CH   string         `json:"ch"` // Channel name. Format：market.$symbol.depth.step0
+		Tick HuobiDepthTick `json:"tick"`
+	}
+
+	// HuobiDepthTick defines the response type for the order book snapshot.
+	HuobiDepthTick struct {
+		Bids [][]float64 `json:"bids"` // Bids ex.: [[41006.3, 0.3]] [price, size]
+		Asks [][]float64 `json:"asks"` // Asks ex.: [[41006.8, 0.6]] [price, size]
+	}
+
 	// HuobiSubscriptionMsg Msg to subscribe to one ticker channel at time.
 	HuobiSubscriptionMsg struct {
 		Sub string `json:"sub"` // channel to subscribe market.$symbol.ticker
@@ -110,13 +128,11 @@ func NewHuobiProvider(
 	endpoints config.ProviderEndpoint,
 	pairs ...types.CurrencyPair,
 ) (*HuobiProvider, error) {
-	if endpoints.Name != config.ProviderHuobi {
-		endpoints = config.ProviderEndpoint{
-			Name:      config.ProviderHuobi,
-			Rest:      huobiRestHost,
-			Websocket: huobiWSHost,
-		}
-	}
+	endpoints = withDefaultEndpoint(endpoints, config.ProviderEndpoint{
+		Name:      config.ProviderHuobi,
+		Rest:      huobiRestHost,
+		Websocket: huobiWSHost,
+	})
 
 	wsURL := url.URL{
 		Scheme: "wss",
@@ -124,24 +140,29 @@ func NewHuobiProvider(
 		Path:   huobiWSPath,
 	}
 
-	wsConn, response, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	endpointPool, err := NewEndpointPool(endpoints)
+	if err != nil {
+		return nil, err
+	}
+	// all data returned from the websocket market APIs is compressed with gzip
+	endpointPool.SetFrameDecoder(GzipFrameDecoder)
+
+	wsConn, err := endpointPool.DialWebsocket(wsURL)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to Huobi websocket: %w", err)
 	}
 
 	provider := &HuobiProvider{
-		wsURL:           wsURL,
-		wsClient:        wsConn,
-		logger:          logger.With().Str("provider", "huobi").Logger(),
-		endpoints:       endpoints,
-		tickers:         map[string]HuobiTicker{},
-		candles:         map[string][]HuobiCandle{},
-		subscribedPairs: map[string]types.CurrencyPair{},
+		wsURL:            wsURL,
+		wsClient:         wsConn,
+		logger:           logger.With().Str("provider", "huobi").Logger(),
+		endpoints:        endpointPool,
+		tickers:          map[string]HuobiTicker{},
+		candles:          map[string][]HuobiCandle{},
+		subscribedPairs:  map[string]types.CurrencyPair{},
+		orderBookTracker: newOrderBookTracker(config.ProviderHuobi),
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderHuobi, endpointPool.SilenceTimeout()),
 	}
 
 	if err := provider.SubscribeCurrencyPairs(pairs...); err != nil {
@@ -149,6 +170,7 @@ func NewHuobiProvider(
 	}
 
 	go provider.handleWebSocketMsgs(ctx)
+	go provider.retrySubscriptions(ctx, provider.logger, provider.subscribeTickers)
 
 	return provider, nil
 }
@@ -196,6 +218,7 @@ func (p *HuobiProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error
 	}
 
 	p.setSubscribedPairs(cps...)
+	p.trackSubscriptions(cps...)
 	telemetry.IncrCounter(
 		float32(len(cps)),
 		"websocket",
@@ -207,13 +230,28 @@ func (p *HuobiProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error
 	return nil
 }
 
+// SetOrderBookPricing subscribes to the depth channel of the pair and reports
+// its ticker price from the order book using the given pricing.
+func (p *HuobiProvider) SetOrderBookPricing(cp types.CurrencyPair, pricing OrderBookPricing) error {
+	if err := p.subscribeDepthPair(cp); err != nil {
+		return err
+	}
+
+	p.setPricing(currencyPairToHuobiDepthPair(cp), pricing)
+	return nil
+}
+
 // subscribeChannels subscribe all currency pairs into ticker and candle channels.
 func (p *HuobiProvider) subscribeChannels(cps ...types.CurrencyPair) error {
 	if err := p.subscribeTickers(cps...); err != nil {
 		return err
 	}
 
-	return p.subscribeCandles(cps...)
+	if err := p.subscribeCandles(cps...); err != nil {
+		return err
+	}
+
+	return p.subscribeDepths(cps...)
 }
 
 // subscribeTickers subscribe all currency pairs into ticker channel.
@@ -238,6 +276,22 @@ func (p *HuobiProvider) subscribeCandles(cps ...types.CurrencyPair) error {
 	return nil
 }
 
+// subscribeDepths subscribe the currency pairs priced from their order book
+// into depth channel.
+func (p *HuobiProvider) subscribeDepths(cps ...types.CurrencyPair) error {
+	pricedSymbols := p.pricedSymbols()
+	for _, cp := range cps {
+		if _, ok := pricedSymbols[currencyPairToHuobiDepthPair(cp)]; !ok {
+			continue
+		}
+		if err := p.subscribeDepthPair(cp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // subscribedPairsToSlice returns the map of subscribed pairs as slice
 func (p *HuobiProvider) subscribedPairsToSlice() []types.CurrencyPair {
 	p.mtx.RLock()
@@ -253,8 +307,16 @@ func (p *HuobiProvider) handleWebSocketMsgs(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-time.After(defaultReadNewWSMessage):
-			messageType, bz, err := p.wsClient.ReadMessage()
+			messageType, bz, err := p.endpoints.ReadMessage(p.wsClient)
 			if err != nil {
+				if errors.Is(err, ErrSilentConnection) {
+					p.logger.Warn().Err(err).Msg("reconnecting silent websocket")
+					if err := p.reconnect(); err != nil {
+						p.logger.Err(err).Msg("error reconnecting")
+					}
+					continue
+				}
+
 				// If some error occurs, check if connection is alive
 				// and continue to try to read the next message.
 				p.logger.Err(err).Msg("failed to read message")
@@ -281,17 +343,10 @@ func (p *HuobiProvider) handleWebSocketMsgs(ctx context.Context) {
 	}
 }
 
-// messageReceived handles the received data from the Huobi websocket. All return
-// data of websocket Market APIs are compressed with GZIP so they need to be
-// decompressed.
+// messageReceived handles the received data from the Huobi websocket, already
+// decompressed by the GzipFrameDecoder of the endpoints.
 func (p *HuobiProvider) messageReceived(messageType int, bz []byte, reconnectTicker *time.Ticker) {
-	if messageType != websocket.BinaryMessage {
-		return
-	}
-
-	bz, err := decompressGzip(bz)
-	if err != nil {
-		p.logger.Err(err).Msg("failed to decompress gziped message")
+	if messageType != websocket.TextMessage {
 		return
 	}
 
@@ -305,12 +360,15 @@ func (p *HuobiProvider) messageReceived(messageType int, bz []byte, reconnectTic
 		tickerErr  error
 		candleResp HuobiCandle
 		candleErr  error
+		depthResp  HuobiDepth
+		depthErr   error
 	)
 
 	// sometimes the message received is not a ticker or a candle response.
 	tickerErr = json.Unmarshal(bz, &tickerResp)
 	if tickerResp.Tick.LastPrice != 0 {
 		p.setTickerPair(tickerResp)
+		p.setTickerSubscription(tickerResp.CH, "")
 		telemetry.IncrCounter(
 			1,
 			"websocket",
@@ -338,10 +396,31 @@ func (p *HuobiProvider) messageReceived(messageType int, bz []byte, reconnectTic
 		return
 	}
 
+	depthErr = json.Unmarshal(bz, &depthResp)
+	if strings.Contains(depthResp.CH, ".depth.") {
+		p.setDepthPair(depthResp)
+		telemetry.IncrCounter(
+			1,
+			"websocket",
+			"message",
+			"type",
+			"order_book",
+			"provider",
+			config.ProviderHuobi,
+		)
+		return
+	}
+
 	// Check if the message is a subscription result
 	var subResult HuobiSubscriptionResult
 	subscriptionErr := json.Unmarshal(bz, &subResult)
-	if subResult.Status == "ok" {
+	switch subResult.Status {
+	case "ok":
+		p.setTickerSubscription(subResult.Subbed, "")
+		return
+	case "error":
+		p.logger.Error().Msg(subResult.ErrMsg)
+		p.setTickerSubscription(subResult.ErrMsg, subResult.ErrMsg)
 		return
 	}
 
@@ -349,6 +428,7 @@ func (p *HuobiProvider) messageReceived(messageType int, bz []byte, reconnectTic
 		Int("length", len(bz)).
 		AnErr("ticker", tickerErr).
 		AnErr("candle", candleErr).
+		AnErr("depth", depthErr).
 		AnErr("subscription", subscriptionErr).
 		Msg("Error on receive message")
 }
@@ -370,7 +450,7 @@ func (p *HuobiProvider) pong(bz []byte, reconnectTicker *time.Ticker) {
 		return
 	}
 
-	if err := p.wsClient.WriteJSON(struct {
+	if err := p.sendJSON(struct {
 		Pong uint64 `json:"pong"`
 	}{Pong: heartbeat.Ping}); err != nil {
 		p.logger.Err(err).Msg("could not send pong message back")
@@ -379,6 +459,9 @@ func (p *HuobiProvider) pong(bz []byte, reconnectTicker *time.Ticker) {
 
 // ping to check websocket connection
 func (p *HuobiProvider) ping() error {
+	p.writeMtx.Lock()
+	defer p.writeMtx.Unlock()
+
 	return p.wsClient.WriteMessage(websocket.PingMessage, ping)
 }
 
@@ -388,6 +471,23 @@ func (p *HuobiProvider) setTickerPair(ticker HuobiTicker) {
 	p.tickers[ticker.CH] = ticker
 }
 
+// setTickerSubscription sets the pair whose ticker channel is contained in the
+// message as active, or as failed if a failure reason is given.
+func (p *HuobiProvider) setTickerSubscription(msg, reason string) {
+	for _, cp := range p.subscribedPairsToSlice() {
+		if !strings.Contains(msg, currencyPairToHuobiTickerPair(cp)) {
+			continue
+		}
+
+		if reason != "" {
+			p.setSubscriptionFailed(cp.String(), reason)
+		} else {
+			p.setSubscriptionActive(cp.String())
+		}
+		return
+	}
+}
+
 func (p *HuobiProvider) setCandlePair(candle HuobiCandle) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
@@ -405,21 +505,35 @@ func (p *HuobiProvider) setCandlePair(candle HuobiCandle) {
 	p.candles[candle.CH] = candleList
 }
 
+func (p *HuobiProvider) setDepthPair(depth HuobiDepth) {
+	bids, err := huobiDepthToOrderBookLevels(depth.Tick.Bids)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("failed to parse order book")
+		return
+	}
+
+	asks, err := huobiDepthToOrderBookLevels(depth.Tick.Asks)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("failed to parse order book")
+		return
+	}
+
+	p.setOrderBook(depth.CH, NewOrderBook(bids, asks, time.Now().UnixMilli()))
+}
+
 // reconnect closes the last WS connection and create a new one.
 func (p *HuobiProvider) reconnect() error {
 	p.wsClient.Close()
+	p.endpoints.ReportDisconnect()
 
 	p.logger.Debug().Msg("reconnecting websocket")
-	wsConn, response, err := websocket.DefaultDialer.Dial(p.wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	wsConn, err := p.endpoints.DialWebsocket(p.wsURL)
 	if err != nil {
 		return fmt.Errorf("error reconnecting to Huobi websocket: %w", err)
 	}
+	p.writeMtx.Lock()
 	p.wsClient = wsConn
+	p.writeMtx.Unlock()
 
 	currencyPairs := p.subscribedPairsToSlice()
 
@@ -436,13 +550,27 @@ func (p *HuobiProvider) reconnect() error {
 // subscribeTickerPair write the subscription ticker msg to the provider.
 func (p *HuobiProvider) subscribeTickerPair(cp types.CurrencyPair) error {
 	huobiSubscriptionMsg := newHuobiTickerSubscriptionMsg(cp)
-	return p.wsClient.WriteJSON(huobiSubscriptionMsg)
+	return p.sendJSON(huobiSubscriptionMsg)
 }
 
 // subscribeCandlePair write the subscription candle msg to the provider.
 func (p *HuobiProvider) subscribeCandlePair(cp types.CurrencyPair) error {
 	huobiSubscriptionCandleMsg := newHuobiCandleSubscriptionMsg(cp)
-	return p.wsClient.WriteJSON(huobiSubscriptionCandleMsg)
+	return p.sendJSON(huobiSubscriptionCandleMsg)
+}
+
+// subscribeDepthPair write the subscription depth msg to the provider.
+func (p *HuobiProvider) subscribeDepthPair(cp types.CurrencyPair) error {
+	huobiSubscriptionDepthMsg := newHuobiDepthSubscriptionMsg(cp)
+	return p.sendJSON(huobiSubscriptionDepthMsg)
+}
+
+// sendJSON writes the msg to the websocket.
+func (p *HuobiProvider) sendJSON(msg interface{}) error {
+	p.writeMtx.Lock()
+	defer p.writeMtx.Unlock()
+
+	return p.wsClient.WriteJSON(msg)
 }
 
 func (p *HuobiProvider) getTickerPrice(cp types.CurrencyPair) (TickerPrice, error) {
@@ -454,7 +582,12 @@ func (p *HuobiProvider) getTickerPrice(cp types.CurrencyPair) (TickerPrice, erro
 		return TickerPrice{}, fmt.Errorf("failed to get ticker price for %s", cp.String())
 	}
 
-	return ticker.toTickerPrice()
+	tickerPrice, err := ticker.toTickerPrice()
+	if err != nil {
+		return TickerPrice{}, err
+	}
+
+	return p.applyOrderBookPricing(p.logger, currencyPairToHuobiDepthPair(cp), tickerPrice), nil
 }
 
 func (p *HuobiProvider) getCandlePrices(cp types.CurrencyPair) ([]CandlePrice, error) {
@@ -487,9 +620,14 @@ func (p *HuobiProvider) setSubscribedPairs(cps ...types.CurrencyPair) {
 	}
 }
 
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *HuobiProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
+}
+
 // GetAvailablePairs returns all pairs to which the provider can subscribe.
 func (p *HuobiProvider) GetAvailablePairs() (map[string]struct{}, error) {
-	resp, err := http.Get(p.endpoints.Rest + huobiRestPath)
+	resp, err := p.endpoints.HTTPGet(huobiRestPath)
 	if err != nil {
 		return nil, err
 	}
@@ -508,17 +646,6 @@ func (p *HuobiProvider) GetAvailablePairs() (map[string]struct{}, error) {
 	return availablePairs, nil
 }
 
-// decompressGzip uncompress gzip compressed messages. All data returned from the
-// websocket Market APIs is compressed with GZIP, so it needs to be unzipped.
-func decompressGzip(bz []byte) ([]byte, error) {
-	r, err := gzip.NewReader(bytes.NewReader(bz))
-	if err != nil {
-		return nil, err
-	}
-
-	return io.ReadAll(r)
-}
-
 // toTickerPrice converts current HuobiTicker to TickerPrice.
 func (ticker HuobiTicker) toTickerPrice() (TickerPrice, error) {
 	return newTickerPrice(
@@ -539,6 +666,21 @@ func (candle HuobiCandle) toCandlePrice() (CandlePrice, error) {
 	)
 }
 
+// huobiDepthToOrderBookLevels converts the [price, size] pairs of a depth tick
+// to order book levels.
+func huobiDepthToOrderBookLevels(levels [][]float64) ([]OrderBookLevel, error) {
+	strLevels := make([][]string, 0, len(levels))
+	for _, level := range levels {
+		strLevel := make([]string, len(level))
+		for i, value := range level {
+			strLevel[i] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		strLevels = append(strLevels, strLevel)
+	}
+
+	return newOrderBookLevels("Huobi", strLevels)
+}
+
 // newHuobiTickerSubscriptionMsg returns a new ticker subscription Msg.
 func newHuobiTickerSubscriptionMsg(cp types.CurrencyPair) HuobiSubscriptionMsg {
 	return HuobiSubscriptionMsg{
@@ -564,3 +706,16 @@ func newHuobiCandleSubscriptionMsg(cp types.CurrencyPair) HuobiSubscriptionMsg {
 func currencyPairToHuobiCandlePair(cp types.CurrencyPair) string {
 	return strings.ToLower("market." + cp.String() + ".kline.1min")
 }
+
+// newHuobiDepthSubscriptionMsg returns a new depth subscription Msg.
+func newHuobiDepthSubscriptionMsg(cp types.CurrencyPair) HuobiSubscriptionMsg {
+	return HuobiSubscriptionMsg{
+		Sub: currencyPairToHuobiDepthPair(cp),
+	}
+}
+
+// currencyPairToHuobiDepthPair returns the channel name in the following format:
+// "market.$symbol.depth.step0".
+func currencyPairToHuobiDepthPair(cp types.CurrencyPair) string {
+	return strings.ToLower("market." + cp.String() + ".depth.step0")
+}
@@ -3,8 +3,10 @@ package provider
 import (
 	"context"
 	"strconv"
+	"sync"
 	"testing"
 
+	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
@@ -102,6 +104,34 @@ func TestHuobiProvider_SubscribeCurrencyPairs(t *testing.T) {
 	})
 }
 
+func TestHuobiProvider_ConcurrentWrites(t *testing.T) {
+	server := NewMockProviderServer()
+	server.Start()
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(server.GetWebsocketURL(), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	p := &HuobiProvider{wsClient: conn}
+	cp := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
+
+	// the retries write from their own goroutine while the read loop pings
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, p.subscribeTickers(cp))
+		}()
+		go func() {
+			defer wg.Done()
+			require.NoError(t, p.ping())
+		}()
+	}
+	wg.Wait()
+}
+
 func TestHuobiCurrencyPairToHuobiPair(t *testing.T) {
 	cp := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
 	binanceSymbol := currencyPairToHuobiTickerPair(cp)
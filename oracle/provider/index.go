@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+// indexCandleDuration is the window of the index candles, each keeping the
+// latest index price of its window.
+const indexCandleDuration = time.Minute
+
+// defaultIndexVolume is the synthetic volume of the index prices unless the
+// provider endpoint sets its own.
+var defaultIndexVolume = math.LegacyOneDec()
+
+type (
+	// indexPriceTracker keeps the index prices of the pairs of an index
+	// provider. Since an index is not traded, the ticker is reported with the
+	// synthetic volume, and the updates are kept as one candle per minute so
+	// the index prices feed the TVWAP like the candles of the other providers.
+	// The synthetic volume is split across the candles of the candle period,
+	// weighing the index the same in the VWAP and the TVWAP. It is embedded by
+	// the index providers.
+	indexPriceTracker struct {
+		indexMtx     sync.RWMutex
+		provider     string
+		volume       math.LegacyDec
+		candleVolume math.LegacyDec           // volume / candles of the candle period
+		tickers      map[string]TickerPrice   // Symbol => TickerPrice
+		candles      map[string][]CandlePrice // Symbol => CandlePrice (newest first)
+	}
+)
+
+// newIndexPriceTracker returns an indexPriceTracker using the synthetic volume
+// of the endpoint.
+func newIndexPriceTracker(endpoint config.ProviderEndpoint) (*indexPriceTracker, error) {
+	volume := defaultIndexVolume
+	if len(endpoint.SyntheticVolume) > 0 {
+		var err error
+		volume, err = math.LegacyNewDecFromStr(endpoint.SyntheticVolume)
+		if err != nil || !volume.IsPositive() {
+			return nil, fmt.Errorf("invalid %s synthetic volume %s", endpoint.Name, endpoint.SyntheticVolume)
+		}
+	}
+
+	return &indexPriceTracker{
+		provider:     endpoint.Name,
+		volume:       volume,
+		candleVolume: volume.QuoInt64(int64(providerCandlePeriod / indexCandleDuration)),
+		tickers:      map[string]TickerPrice{},
+		candles:      map[string][]CandlePrice{},
+	}, nil
+}
+
+// GetTickerPrices returns the latest index price of the pairs.
+func (t *indexPriceTracker) GetTickerPrices(pairs ...types.CurrencyPair) (map[string]TickerPrice, error) {
+	t.indexMtx.RLock()
+	defer t.indexMtx.RUnlock()
+
+	tickerPrices := make(map[string]TickerPrice, len(pairs))
+	for _, cp := range pairs {
+		if ticker, ok := t.tickers[cp.String()]; ok {
+			tickerPrices[cp.String()] = ticker
+		}
+	}
+
+	return tickerPrices, nil
+}
+
+// GetCandlePrices returns the index prices of the pairs within the candle
+// period.
+func (t *indexPriceTracker) GetCandlePrices(pairs ...types.CurrencyPair) (map[string][]CandlePrice, error) {
+	t.indexMtx.RLock()
+	defer t.indexMtx.RUnlock()
+
+	candlePrices := make(map[string][]CandlePrice, len(pairs))
+	for _, cp := range pairs {
+		if candles, ok := t.candles[cp.String()]; ok {
+			candlePrices[cp.String()] = append([]CandlePrice{}, candles...)
+		}
+	}
+
+	return candlePrices, nil
+}
+
+// setIndexPrice stores the index price of the symbol published at the
+// timestamp in unix milliseconds. Only the latest price of every minute is
+// kept as a candle, so frequent updates do not outweigh the others.
+func (t *indexPriceTracker) setIndexPrice(symbol, price string, timeStamp int64) error {
+	candle, err := newCandlePrice(t.provider, symbol, price, t.candleVolume.String(), timeStamp)
+	if err != nil {
+		return err
+	}
+
+	t.indexMtx.Lock()
+	defer t.indexMtx.Unlock()
+
+	t.tickers[symbol] = TickerPrice{Price: candle.Price, Volume: t.volume}
+
+	window := indexCandleDuration.Milliseconds()
+	staleTime := PastUnixTime(providerCandlePeriod)
+	candleList := []CandlePrice{candle}
+	for _, c := range t.candles[symbol] {
+		if staleTime < c.TimeStamp && c.TimeStamp/window != timeStamp/window {
+			candleList = append(candleList, c)
+		}
+	}
+	t.candles[symbol] = candleList
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+func TestIndexPriceTracker(t *testing.T) {
+	btcUSDT := types.CurrencyPair{Base: "BTC", Quote: "USDT"}
+
+	tracker, err := newIndexPriceTracker(config.ProviderEndpoint{
+		Name:            config.ProviderOkxIndex,
+		SyntheticVolume: "1000",
+	})
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Minute).UnixMilli()
+	minute := time.Minute.Milliseconds()
+	require.NoError(t, tracker.setIndexPrice("BTCUSDT", "30000", now-2*minute))
+	require.NoError(t, tracker.setIndexPrice("BTCUSDT", "30100", now-minute))
+	// only the latest price of a minute is kept
+	require.NoError(t, tracker.setIndexPrice("BTCUSDT", "30200", now-minute+1000))
+	// stale prices are dropped once another price is set
+	tracker.candles["BTCUSDT"] = append(tracker.candles["BTCUSDT"], CandlePrice{
+		Price:     math.LegacyMustNewDecFromStr("1"),
+		Volume:    math.LegacyMustNewDecFromStr("1000"),
+		TimeStamp: PastUnixTime(2 * providerCandlePeriod),
+	})
+	require.NoError(t, tracker.setIndexPrice("BTCUSDT", "30300", now))
+	require.ErrorContains(t, tracker.setIndexPrice("BTCUSDT", "foo", now), "failed to parse")
+
+	tickers, err := tracker.GetTickerPrices(btcUSDT, types.CurrencyPair{Base: "FOO", Quote: "USDT"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]TickerPrice{
+		"BTCUSDT": {
+			Price:  math.LegacyMustNewDecFromStr("30300"),
+			Volume: math.LegacyMustNewDecFromStr("1000"),
+		},
+	}, tickers)
+
+	candles, err := tracker.GetCandlePrices(btcUSDT)
+	require.NoError(t, err)
+	require.Len(t, candles["BTCUSDT"], 3)
+	for i, price := range []string{"30300", "30200", "30000"} {
+		require.Equal(t, math.LegacyMustNewDecFromStr(price), candles["BTCUSDT"][i].Price)
+		// the synthetic volume is split across the 10 candles of the period
+		require.Equal(t, math.LegacyMustNewDecFromStr("100"), candles["BTCUSDT"][i].Volume)
+	}
+}
+
+func TestNewIndexPriceTracker(t *testing.T) {
+	tracker, err := newIndexPriceTracker(config.ProviderEndpoint{Name: config.ProviderBinanceIndex})
+	require.NoError(t, err)
+	require.Equal(t, math.LegacyOneDec(), tracker.volume)
+
+	_, err = newIndexPriceTracker(config.ProviderEndpoint{
+		Name:            config.ProviderBinanceIndex,
+		SyntheticVolume: "-1",
+	})
+	require.ErrorContains(t, err, "invalid binance-index synthetic volume -1")
+}
@@ -3,8 +3,8 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -28,22 +28,29 @@ const (
 	krakenEventSubscriptionStatus = "subscriptionStatus"
 )
 
-var _ Provider = (*KrakenProvider)(nil)
+var (
+	_ Provider             = (*KrakenProvider)(nil)
+	_ SubscriptionProvider = (*KrakenProvider)(nil)
+)
 
 type (
 	// KrakenProvider defines an Oracle provider implemented by the Kraken public
-	// API.
+	// API, using either the v1 or the v2 websocket API.
 	//
 	// REF: https://docs.kraken.com/websockets/#overview
+	// REF: https://docs.kraken.com/api/docs/websocket-v2/ticker
 	KrakenProvider struct {
 		wsURL           url.URL
 		wsClient        *websocket.Conn
+		writeMtx        sync.Mutex // serializes the writes to wsClient
 		logger          zerolog.Logger
 		mtx             sync.RWMutex
-		endpoints       config.ProviderEndpoint
+		endpoints       *EndpointPool
+		apiVersion      string                        // config.KrakenAPIV1 or config.KrakenAPIV2
 		tickers         map[string]TickerPrice        // Symbol => TickerPrice
 		candles         map[string][]KrakenCandle     // Symbol => KrakenCandle
 		subscribedPairs map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		*subscriptionTracker
 	}
 
 	// KrakenTicker ticker price response from Kraken ticker channel.
@@ -109,25 +116,31 @@ func NewKrakenProvider(
 	endpoints config.ProviderEndpoint,
 	pairs ...types.CurrencyPair,
 ) (*KrakenProvider, error) {
-	if endpoints.Name != config.ProviderKraken {
-		endpoints = config.ProviderEndpoint{
-			Name:      config.ProviderKraken,
-			Rest:      KrakenRestHost,
-			Websocket: krakenWSHost,
-		}
+	apiVersion := config.KrakenAPIV1
+	if endpoints.Name == config.ProviderKraken && len(endpoints.APIVersion) > 0 {
+		apiVersion = endpoints.APIVersion
 	}
 
+	endpoints = withDefaultEndpoint(endpoints, config.ProviderEndpoint{
+		Name:      config.ProviderKraken,
+		Rest:      KrakenRestHost,
+		Websocket: krakenWSHost,
+	})
+
 	wsURL := url.URL{
 		Scheme: "wss",
 		Host:   endpoints.Websocket,
 	}
+	if apiVersion == config.KrakenAPIV2 {
+		wsURL.Path = krakenV2WSPath
+	}
 
-	wsConn, response, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	endpointPool, err := NewEndpointPool(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	wsConn, err := endpointPool.DialWebsocket(wsURL)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to websocket: %w", err)
 	}
@@ -136,10 +149,13 @@ func NewKrakenProvider(
 		wsURL:           wsURL,
 		wsClient:        wsConn,
 		logger:          logger.With().Str("provider", "kraken").Logger(),
-		endpoints:       endpoints,
+		endpoints:       endpointPool,
+		apiVersion:      apiVersion,
 		tickers:         map[string]TickerPrice{},
 		candles:         map[string][]KrakenCandle{},
 		subscribedPairs: map[string]types.CurrencyPair{},
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderKraken, endpointPool.SilenceTimeout()),
 	}
 
 	if err := provider.SubscribeCurrencyPairs(pairs...); err != nil {
@@ -147,6 +163,7 @@ func NewKrakenProvider(
 	}
 
 	go provider.handleWebSocketMsgs(ctx)
+	go provider.retrySubscriptions(ctx, provider.logger, provider.resubscribeTickers)
 
 	return provider, nil
 }
@@ -200,6 +217,7 @@ func (p *KrakenProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error
 	}
 
 	p.setSubscribedPairs(cps...)
+	p.trackSubscriptions(cps...)
 	telemetry.IncrCounter(
 		float32(len(cps)),
 		"websocket",
@@ -213,6 +231,10 @@ func (p *KrakenProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error
 
 // subscribeChannels subscribe all currency pairs into ticker and candle channels.
 func (p *KrakenProvider) subscribeChannels(cps ...types.CurrencyPair) error {
+	if p.apiVersion == config.KrakenAPIV2 {
+		return p.subscribeV2Channels(cps...)
+	}
+
 	pairs := make([]string, len(cps))
 
 	for i, cp := range cps {
@@ -226,6 +248,27 @@ func (p *KrakenProvider) subscribeChannels(cps ...types.CurrencyPair) error {
 	return p.subscribeCandles(pairs...)
 }
 
+// resubscribeTickers subscribes the pairs into the ticker channel again, whose
+// subscription status is tracked, adding back the pairs removed on failure.
+func (p *KrakenProvider) resubscribeTickers(cps ...types.CurrencyPair) error {
+	if p.apiVersion == config.KrakenAPIV2 {
+		if err := p.subscribeV2Tickers(krakenV2Symbols(cps...)); err != nil {
+			return err
+		}
+	} else {
+		pairs := make([]string, len(cps))
+		for i, cp := range cps {
+			pairs[i] = currencyPairToKrakenPair(cp)
+		}
+		if err := p.subscribeTickers(pairs...); err != nil {
+			return err
+		}
+	}
+
+	p.setSubscribedPairs(cps...)
+	return nil
+}
+
 // subscribedPairsToSlice returns the map of subscribed pairs as slice
 func (p *KrakenProvider) subscribedPairsToSlice() []types.CurrencyPair {
 	p.mtx.RLock()
@@ -275,8 +318,17 @@ func (p *KrakenProvider) handleWebSocketMsgs(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-time.After(defaultReadNewWSMessage):
-			messageType, bz, err := p.wsClient.ReadMessage()
+			messageType, bz, err := p.endpoints.ReadMessage(p.wsClient)
 			if err != nil {
+				if errors.Is(err, ErrSilentConnection) {
+					p.logger.Warn().Err(err).Msg("reconnecting silent websocket")
+					if err := p.reconnect(); err != nil {
+						p.logger.Err(err).Msg("attempted to reconnect")
+						p.keepReconnecting()
+					}
+					continue
+				}
+
 				if websocket.IsCloseError(err, websocket.CloseAbnormalClosure) {
 					p.logger.Err(err).Msg("WebSocket closed unexpectedly")
 					p.keepReconnecting()
@@ -313,6 +365,11 @@ func (p *KrakenProvider) messageReceived(messageType int, bz []byte) {
 		return
 	}
 
+	if p.apiVersion == config.KrakenAPIV2 {
+		p.messageReceivedV2(bz)
+		return
+	}
+
 	var (
 		krakenEvent KrakenEvent
 		krakenErr   error
@@ -387,7 +444,6 @@ func (p *KrakenProvider) messageReceivedTickerPrice(bz []byte) error {
 		return err
 	}
 
-	krakenPair = normalizeKrakenBTCPair(krakenPair)
 	currencyPairSymbol := krakenPairToCurrencyPairSymbol(krakenPair)
 
 	tickerPrice, err := krakenTicker.toTickerPrice(currencyPairSymbol)
@@ -477,7 +533,6 @@ func (p *KrakenProvider) messageReceivedCandle(bz []byte) error {
 		return fmt.Errorf("received an unexpected pair")
 	}
 
-	krakenPair = normalizeKrakenBTCPair(krakenPair)
 	currencyPairSymbol := krakenPairToCurrencyPairSymbol(krakenPair)
 	krakenCandle.Symbol = currencyPairSymbol
 
@@ -497,18 +552,16 @@ func (p *KrakenProvider) messageReceivedCandle(bz []byte) error {
 // reconnect closes the last WS connection and create a new one.
 func (p *KrakenProvider) reconnect() error {
 	p.wsClient.Close()
+	p.endpoints.ReportDisconnect()
 	p.logger.Debug().Msg("trying to reconnect")
 
-	wsConn, response, err := websocket.DefaultDialer.Dial(p.wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	wsConn, err := p.endpoints.DialWebsocket(p.wsURL)
 	if err != nil {
 		return fmt.Errorf("error connecting to Kraken websocket: %w", err)
 	}
+	p.writeMtx.Lock()
 	p.wsClient = wsConn
+	p.writeMtx.Unlock()
 
 	currencyPairs := p.subscribedPairsToSlice()
 
@@ -551,14 +604,19 @@ func (p *KrakenProvider) messageReceivedSubscriptionStatus(bz []byte) {
 		return
 	}
 
+	symbol := krakenPairToCurrencyPairSymbol(subscriptionStatus.Pair)
 	switch subscriptionStatus.Status {
+	case "subscribed":
+		p.setSubscriptionActive(symbol)
+		return
 	case "error":
 		p.logger.Error().Msg(subscriptionStatus.ErrorMessage)
-		p.removeSubscribedTickers(krakenPairToCurrencyPairSymbol(subscriptionStatus.Pair))
+		p.removeSubscribedTickers(symbol)
+		p.setSubscriptionFailed(symbol, subscriptionStatus.ErrorMessage)
 		return
 	case "unsubscribed":
 		p.logger.Debug().Msgf("ticker %s was unsubscribed", subscriptionStatus.Pair)
-		p.removeSubscribedTickers(krakenPairToCurrencyPairSymbol(subscriptionStatus.Pair))
+		p.removeSubscribedTickers(symbol)
 		return
 	}
 }
@@ -584,6 +642,7 @@ func (p *KrakenProvider) setTickerPair(symbol string, ticker TickerPrice) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 	p.tickers[symbol] = ticker
+	p.setSubscriptionActive(symbol)
 }
 
 func (p *KrakenProvider) setCandlePair(candle KrakenCandle) {
@@ -605,19 +664,30 @@ func (p *KrakenProvider) setCandlePair(candle KrakenCandle) {
 
 // ping to check websocket connection.
 func (p *KrakenProvider) ping() error {
+	p.writeMtx.Lock()
+	defer p.writeMtx.Unlock()
+
 	return p.wsClient.WriteMessage(websocket.PingMessage, ping)
 }
 
 // subscribeTickers write the subscription msg to the provider.
 func (p *KrakenProvider) subscribeTickers(pairs ...string) error {
 	subsMsg := newKrakenTickerSubscriptionMsg(pairs...)
-	return p.wsClient.WriteJSON(subsMsg)
+	return p.sendJSON(subsMsg)
 }
 
 // subscribeCandles write the subscription msg to the provider.
 func (p *KrakenProvider) subscribeCandles(pairs ...string) error {
 	subsMsg := newKrakenCandleSubscriptionMsg(pairs...)
-	return p.wsClient.WriteJSON(subsMsg)
+	return p.sendJSON(subsMsg)
+}
+
+// sendJSON writes the msg to the websocket.
+func (p *KrakenProvider) sendJSON(msg interface{}) error {
+	p.writeMtx.Lock()
+	defer p.writeMtx.Unlock()
+
+	return p.wsClient.WriteJSON(msg)
 }
 
 // setSubscribedPairs sets N currency pairs to the map of subscribed pairs.
@@ -640,9 +710,14 @@ func (p *KrakenProvider) removeSubscribedTickers(tickerSymbols ...string) {
 	}
 }
 
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *KrakenProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
+}
+
 // GetAvailablePairs returns all pairs to which the provider can subscribe.
 func (p *KrakenProvider) GetAvailablePairs() (map[string]struct{}, error) {
-	resp, err := http.Get(p.endpoints.Rest + KrakenRestPath)
+	resp, err := p.endpoints.HTTPGet(KrakenRestPath)
 	if err != nil {
 		return nil, err
 	}
@@ -655,16 +730,10 @@ func (p *KrakenProvider) GetAvailablePairs() (map[string]struct{}, error) {
 
 	availablePairs := make(map[string]struct{}, len(pairsSummary.Result))
 	for _, pair := range pairsSummary.Result {
-		splitPair := strings.Split(pair.WsName, "/")
-		if len(splitPair) != 2 {
+		if len(strings.Split(pair.WsName, "/")) != 2 {
 			continue
 		}
-
-		cp := types.CurrencyPair{
-			Base:  strings.ToUpper(splitPair[0]),
-			Quote: strings.ToUpper(splitPair[1]),
-		}
-		availablePairs[cp.String()] = struct{}{}
+		availablePairs[krakenPairToCurrencyPairSymbol(strings.ToUpper(pair.WsName))] = struct{}{}
 	}
 
 	return availablePairs, nil
@@ -703,19 +772,54 @@ func newKrakenCandleSubscriptionMsg(pairs ...string) KrakenSubscriptionMsg {
 }
 
 // krakenPairToCurrencyPairSymbol receives a kraken pair formated
-// ex.: ATOM/USDT and return currencyPair Symbol ATOMUSDT.
+// ex.: XBT/USDT and return currencyPair Symbol BTCUSDT.
 func krakenPairToCurrencyPairSymbol(krakenPair string) string {
-	return strings.ReplaceAll(krakenPair, "/", "")
+	assets := strings.Split(krakenPair, "/")
+	for i, asset := range assets {
+		assets[i] = krakenAssetToCanonical(asset)
+	}
+	return strings.Join(assets, "")
 }
 
 // currencyPairToKrakenPair receives a currency pair
-// and return kraken ticker symbol ATOM/USDT.
+// and return kraken v1 ticker symbol ex.: XBT/USDT.
 func currencyPairToKrakenPair(cp types.CurrencyPair) string {
-	return strings.ToUpper(cp.Base + "/" + cp.Quote)
+	return strings.ToUpper(canonicalToKrakenAsset(cp.Base) + "/" + canonicalToKrakenAsset(cp.Quote))
 }
 
-// normalizeKrakenBTCPair changes XBT pairs to BTC,
-// since other providers list bitcoin as BTC.
-func normalizeKrakenBTCPair(ticker string) string {
-	return strings.Replace(ticker, "XBT", "BTC", 1)
+// krakenAssetCodes maps the canonical assets to the kraken asset codes which
+// differ from them, used by the v1 websocket and the REST API.
+var krakenAssetCodes = map[string]string{
+	"BTC":  "XBT",
+	"DOGE": "XDG",
+}
+
+// krakenCanonicalAssets maps the kraken asset codes back to the canonical
+// assets.
+var krakenCanonicalAssets = func() map[string]string {
+	assets := make(map[string]string, len(krakenAssetCodes))
+	for canonical, code := range krakenAssetCodes {
+		assets[code] = canonical
+	}
+	return assets
+}()
+
+// krakenAssetToCanonical returns the canonical asset of a kraken asset code
+// ex.: XBT returns BTC, since other providers list bitcoin as BTC.
+func krakenAssetToCanonical(asset string) string {
+	asset = strings.ToUpper(asset)
+	if canonical, ok := krakenCanonicalAssets[asset]; ok {
+		return canonical
+	}
+	return asset
+}
+
+// canonicalToKrakenAsset returns the kraken asset code of a canonical asset
+// ex.: BTC returns XBT.
+func canonicalToKrakenAsset(asset string) string {
+	asset = strings.ToUpper(asset)
+	if code, ok := krakenAssetCodes[asset]; ok {
+		return code
+	}
+	return asset
 }
@@ -2,8 +2,12 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
@@ -104,10 +108,160 @@ func TestKrakenCurrencyPairToKrakenPair(t *testing.T) {
 	require.Equal(t, krakenSymbol, "ATOM/USDT")
 }
 
-func TestNormalizeKrakenBTCPair(t *testing.T) {
-	btcSymbol := normalizeKrakenBTCPair("XBT/USDT")
-	require.Equal(t, btcSymbol, "BTC/USDT")
+func TestKrakenAssetNormalization(t *testing.T) {
+	testCases := []struct {
+		krakenPair string
+		cp         types.CurrencyPair
+	}{
+		{"XBT/USDT", types.CurrencyPair{Base: "BTC", Quote: "USDT"}},
+		{"XDG/USD", types.CurrencyPair{Base: "DOGE", Quote: "USD"}},
+		{"ETH/XBT", types.CurrencyPair{Base: "ETH", Quote: "BTC"}},
+		{"ATOM/USDT", types.CurrencyPair{Base: "ATOM", Quote: "USDT"}},
+		// assets only containing a kraken code are kept
+		{"XBTC/USD", types.CurrencyPair{Base: "XBTC", Quote: "USD"}},
+	}
 
-	atomSymbol := normalizeKrakenBTCPair("ATOM/USDT")
-	require.Equal(t, atomSymbol, "ATOM/USDT")
+	for _, tc := range testCases {
+		t.Run(tc.krakenPair, func(t *testing.T) {
+			require.Equal(t, tc.cp.String(), krakenPairToCurrencyPairSymbol(tc.krakenPair))
+			require.Equal(t, tc.krakenPair, currencyPairToKrakenPair(tc.cp))
+		})
+	}
+
+	require.Equal(t, "BTC", krakenAssetToCanonical("xbt"))
+	require.Equal(t, "XDG", canonicalToKrakenAsset("doge"))
+	require.Equal(t, "BTC/USDT", currencyPairToKrakenV2Pair(types.CurrencyPair{Base: "BTC", Quote: "USDT"}))
+}
+
+func TestKrakenProvider_DefaultAPIVersion(t *testing.T) {
+	paths := make(chan string, 1)
+	subscriptions := make(chan KrakenSubscriptionMsg, 2)
+	server := NewMockProviderServer()
+	server.SetHandler(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		paths <- r.URL.Path
+
+		for {
+			var msg KrakenSubscriptionMsg
+			if err := c.ReadJSON(&msg); err != nil {
+				return
+			}
+			subscriptions <- msg
+		}
+	})
+	defer server.Close()
+
+	// the v1 protocol is used without an api version
+	_, err := NewKrakenProvider(
+		context.TODO(),
+		zerolog.Nop(),
+		config.ProviderEndpoint{
+			Name:      config.ProviderKraken,
+			Rest:      "https://" + server.GetBaseURL(),
+			Websocket: server.GetBaseURL(),
+		},
+		types.CurrencyPair{Base: "BTC", Quote: "USD"},
+	)
+	require.NoError(t, err)
+
+	krakenPair := currencyPairToKrakenPair(types.CurrencyPair{Base: "BTC", Quote: "USD"})
+	require.NotEqual(t, krakenV2WSPath, <-paths)
+	require.Equal(t, newKrakenTickerSubscriptionMsg(krakenPair), <-subscriptions)
+	require.Equal(t, newKrakenCandleSubscriptionMsg(krakenPair), <-subscriptions)
+}
+
+func TestKrakenProvider_V2(t *testing.T) {
+	subscriptions := make(chan KrakenV2Request, 2)
+	server := NewMockProviderServer()
+	server.SetHandler(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		for i := 0; i < 2; i++ {
+			var msg KrakenV2Request
+			if err := c.ReadJSON(&msg); err != nil {
+				return
+			}
+			subscriptions <- msg
+		}
+
+		now := time.Now().UTC()
+		for _, msg := range []string{
+			`{"channel":"status","type":"update","data":[{"api_version":"v2","system":"online","version":"2.0.0"}]}`,
+			`{"method":"subscribe","result":{"channel":"ticker","symbol":"BTC/USD","snapshot":true},"success":true}`,
+			`{"error":"Currency pair not supported FOO/USD","method":"subscribe","success":false,"symbol":"FOO/USD"}`,
+			`{"channel":"heartbeat"}`,
+			`{"channel":"ticker","type":"snapshot","data":[{"symbol":"XBT/USD","last":34123.4,"volume":1234.56}]}`,
+			fmt.Sprintf(
+				`{"channel":"ohlc","type":"snapshot","data":[`+
+					`{"symbol":"BTC/USD","close":34000.1,"volume":10.5,"timestamp":"%s"},`+
+					`{"symbol":"BTC/USD","close":30000,"volume":1,"timestamp":"%s"}]}`,
+				now.Format(time.RFC3339Nano),
+				now.Add(-2*providerCandlePeriod).Format(time.RFC3339Nano),
+			),
+		} {
+			if err := c.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				return
+			}
+		}
+
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	btcUSD := types.CurrencyPair{Base: "BTC", Quote: "USD"}
+	fooUSD := types.CurrencyPair{Base: "FOO", Quote: "USD"}
+	p, err := NewKrakenProvider(
+		context.TODO(),
+		zerolog.Nop(),
+		config.ProviderEndpoint{
+			Name:       config.ProviderKraken,
+			Rest:       "https://" + server.GetBaseURL(),
+			Websocket:  server.GetBaseURL(),
+			APIVersion: config.KrakenAPIV2,
+		},
+		btcUSD,
+		fooUSD,
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, KrakenV2Request{
+		Method: "subscribe",
+		Params: KrakenV2Channel{Channel: "ticker", Symbol: []string{"BTC/USD", "FOO/USD"}},
+	}, <-subscriptions)
+	require.Equal(t, KrakenV2Request{
+		Method: "subscribe",
+		Params: KrakenV2Channel{Channel: "ohlc", Symbol: []string{"BTC/USD", "FOO/USD"}, Interval: 1},
+	}, <-subscriptions)
+
+	require.Eventually(t, func() bool {
+		candles, _ := p.GetCandlePrices(btcUSD)
+		return len(candles) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	prices, err := p.GetTickerPrices(btcUSD)
+	require.NoError(t, err)
+	require.Equal(t, math.LegacyMustNewDecFromStr("34123.4"), prices["BTCUSD"].Price)
+	require.Equal(t, math.LegacyMustNewDecFromStr("1234.56"), prices["BTCUSD"].Volume)
+
+	candles, err := p.GetCandlePrices(btcUSD)
+	require.NoError(t, err)
+	require.Len(t, candles["BTCUSD"], 1)
+	require.Equal(t, math.LegacyMustNewDecFromStr("34000.1"), candles["BTCUSD"][0].Price)
+
+	statuses := p.SubscriptionStatuses()
+	require.Equal(t, SubscriptionActive, statuses["BTCUSD"].State)
+	require.Equal(t, SubscriptionFailed, statuses["FOOUSD"].State)
+	require.Equal(t, "Currency pair not supported FOO/USD", statuses["FOOUSD"].Reason)
 }
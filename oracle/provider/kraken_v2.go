@@ -0,0 +1,254 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+const (
+	krakenV2WSPath          = "/v2"
+	krakenV2ChannelTicker   = "ticker"
+	krakenV2ChannelOHLC     = "ohlc"
+	krakenV2ChannelStatus   = "status"
+	krakenV2CandleInterval  = 1 // minutes
+	krakenV2MethodSubscribe = "subscribe"
+)
+
+type (
+	// KrakenV2Request defines a request of the v2 websocket API.
+	KrakenV2Request struct {
+		Method string          `json:"method"` // subscribe | unsubscribe | ping
+		Params KrakenV2Channel `json:"params"`
+	}
+
+	// KrakenV2Channel defines the channel and symbols of a v2 subscription.
+	KrakenV2Channel struct {
+		Channel  string   `json:"channel"`            // ticker | ohlc
+		Symbol   []string `json:"symbol"`             // ex.: ["BTC/USD"]
+		Interval int      `json:"interval,omitempty"` // ohlc interval in minutes
+	}
+
+	// KrakenV2Message defines the envelope of every message of the v2
+	// websocket API, either a response to a request or a channel message.
+	KrakenV2Message struct {
+		Method  string          `json:"method"`  // set on responses ex.: subscribe | pong
+		Success bool            `json:"success"` // set on responses
+		Error   string          `json:"error"`   // error description of a failed request
+		Symbol  string          `json:"symbol"`  // symbol of a failed subscription
+		Result  KrakenV2Result  `json:"result"`  // result of a successful subscription
+		Channel string          `json:"channel"` // ticker | ohlc | heartbeat | status
+		Type    string          `json:"type"`    // snapshot | update
+		Data    json.RawMessage `json:"data"`    // array of channel updates
+	}
+
+	// KrakenV2Result defines the result of a successful v2 subscription.
+	KrakenV2Result struct {
+		Channel string `json:"channel"` // ex.: ticker
+		Symbol  string `json:"symbol"`  // ex.: BTC/USD
+	}
+
+	// KrakenV2Ticker defines a ticker update of the v2 ticker channel.
+	// REF: https://docs.kraken.com/api/docs/websocket-v2/ticker
+	KrakenV2Ticker struct {
+		Symbol string      `json:"symbol"` // ex.: BTC/USD
+		Last   json.Number `json:"last"`   // last traded price
+		Volume json.Number `json:"volume"` // 24h volume in the base asset
+	}
+
+	// KrakenV2Candle defines a candle update of the v2 ohlc channel.
+	// REF: https://docs.kraken.com/api/docs/websocket-v2/ohlc
+	KrakenV2Candle struct {
+		Symbol    string      `json:"symbol"`    // ex.: BTC/USD
+		Close     json.Number `json:"close"`     // close price of the interval
+		Volume    json.Number `json:"volume"`    // volume of the interval
+		Timestamp time.Time   `json:"timestamp"` // time of the update
+	}
+
+	// KrakenV2Status defines an update of the v2 status channel.
+	KrakenV2Status struct {
+		System string `json:"system"` // online | maintenance | cancel_only | post_only
+	}
+)
+
+// subscribeV2Channels subscribes the pairs to the v2 ticker and ohlc channels.
+func (p *KrakenProvider) subscribeV2Channels(cps ...types.CurrencyPair) error {
+	symbols := krakenV2Symbols(cps...)
+	if err := p.subscribeV2Tickers(symbols); err != nil {
+		return err
+	}
+
+	return p.sendJSON(KrakenV2Request{
+		Method: krakenV2MethodSubscribe,
+		Params: KrakenV2Channel{
+			Channel:  krakenV2ChannelOHLC,
+			Symbol:   symbols,
+			Interval: krakenV2CandleInterval,
+		},
+	})
+}
+
+// subscribeV2Tickers subscribes the symbols to the v2 ticker channel.
+func (p *KrakenProvider) subscribeV2Tickers(symbols []string) error {
+	return p.sendJSON(KrakenV2Request{
+		Method: krakenV2MethodSubscribe,
+		Params: KrakenV2Channel{
+			Channel: krakenV2ChannelTicker,
+			Symbol:  symbols,
+		},
+	})
+}
+
+// messageReceivedV2 handles any message sent by the v2 websocket API.
+func (p *KrakenProvider) messageReceivedV2(bz []byte) {
+	var msg KrakenV2Message
+	if err := json.Unmarshal(bz, &msg); err != nil {
+		p.logger.Error().Int("length", len(bz)).Err(err).Msg("Error on receive message")
+		return
+	}
+
+	if len(msg.Method) > 0 {
+		p.messageReceivedV2Response(msg)
+		return
+	}
+
+	var err error
+	switch msg.Channel {
+	case krakenV2ChannelTicker:
+		err = p.messageReceivedV2Tickers(msg.Data)
+	case krakenV2ChannelOHLC:
+		err = p.messageReceivedV2Candles(msg.Data)
+	case krakenV2ChannelStatus:
+		err = p.messageReceivedV2Status(msg.Data)
+	}
+	if err != nil {
+		p.logger.Error().
+			Int("length", len(bz)).
+			Str("channel", msg.Channel).
+			Err(err).
+			Msg("Error on receive message")
+	}
+}
+
+// messageReceivedV2Response handles the responses to the subscriptions, a
+// subscription to the ticker channel marking the pair as active.
+func (p *KrakenProvider) messageReceivedV2Response(msg KrakenV2Message) {
+	if msg.Method != krakenV2MethodSubscribe {
+		return
+	}
+
+	if !msg.Success {
+		p.logger.Error().Msg(msg.Error)
+		if len(msg.Symbol) == 0 {
+			return
+		}
+		symbol := krakenPairToCurrencyPairSymbol(msg.Symbol)
+		p.removeSubscribedTickers(symbol)
+		p.setSubscriptionFailed(symbol, msg.Error)
+		return
+	}
+
+	if msg.Result.Channel == krakenV2ChannelTicker {
+		p.setSubscriptionActive(krakenPairToCurrencyPairSymbol(msg.Result.Symbol))
+	}
+}
+
+// messageReceivedV2Tickers handles the updates of the ticker channel.
+func (p *KrakenProvider) messageReceivedV2Tickers(data json.RawMessage) error {
+	var tickers []KrakenV2Ticker
+	if err := json.Unmarshal(data, &tickers); err != nil {
+		return err
+	}
+
+	for _, ticker := range tickers {
+		symbol := krakenPairToCurrencyPairSymbol(ticker.Symbol)
+		tickerPrice, err := newTickerPrice("Kraken", symbol, ticker.Last.String(), ticker.Volume.String())
+		if err != nil {
+			return err
+		}
+
+		p.setTickerPair(symbol, tickerPrice)
+		telemetry.IncrCounter(
+			1,
+			"websocket",
+			"message",
+			"type",
+			"ticker",
+			"provider",
+			config.ProviderKraken,
+		)
+	}
+	return nil
+}
+
+// messageReceivedV2Candles handles the updates of the ohlc channel, skipping
+// the stale candles of the subscription snapshot.
+func (p *KrakenProvider) messageReceivedV2Candles(data json.RawMessage) error {
+	var candles []KrakenV2Candle
+	if err := json.Unmarshal(data, &candles); err != nil {
+		return err
+	}
+
+	staleTime := PastUnixTime(providerCandlePeriod)
+	for _, candle := range candles {
+		if candle.Timestamp.UnixMilli() <= staleTime {
+			continue
+		}
+
+		p.setCandlePair(KrakenCandle{
+			Close:     candle.Close.String(),
+			TimeStamp: candle.Timestamp.Unix(),
+			Volume:    candle.Volume.String(),
+			Symbol:    krakenPairToCurrencyPairSymbol(candle.Symbol),
+		})
+		telemetry.IncrCounter(
+			1,
+			"websocket",
+			"message",
+			"type",
+			"candle",
+			"provider",
+			config.ProviderKraken,
+		)
+	}
+	return nil
+}
+
+// messageReceivedV2Status handles the status channel and tries to reconnect if
+// the system is not online.
+func (p *KrakenProvider) messageReceivedV2Status(data json.RawMessage) error {
+	var statuses []KrakenV2Status
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if !strings.EqualFold(status.System, "online") {
+			p.logger.Warn().Msg(fmt.Sprint("kraken system status ", status.System))
+			p.keepReconnecting()
+			return nil
+		}
+	}
+	return nil
+}
+
+// currencyPairToKrakenV2Pair receives a currency pair and returns the kraken
+// v2 symbol ex.: BTC/USDT, the v2 API using the canonical asset codes.
+func currencyPairToKrakenV2Pair(cp types.CurrencyPair) string {
+	return strings.ToUpper(cp.Base + "/" + cp.Quote)
+}
+
+// krakenV2Symbols returns the v2 symbols of the pairs, ex.: "ATOM/USD".
+func krakenV2Symbols(cps ...types.CurrencyPair) []string {
+	symbols := make([]string, len(cps))
+	for i, cp := range cps {
+		symbols[i] = currencyPairToKrakenV2Pair(cp)
+	}
+	return symbols
+}
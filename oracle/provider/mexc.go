@@ -3,8 +3,8 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -27,24 +27,32 @@ const (
 	mexcRestPath = "/open/api/v2/market/ticker"
 )
 
-var _ Provider = (*MexcProvider)(nil)
+var (
+	_ Provider             = (*MexcProvider)(nil)
+	_ SubscriptionProvider = (*MexcProvider)(nil)
+)
 
 type (
 	// MexcProvider defines an Oracle provider implemented by the Mexc public
-	// API.
+	// API. It uses the protobuf streams of the v3 API unless the v2 API is
+	// configured.
 	//
 	// REF: https://mxcdevelop.github.io/apidocs/spot_v2_en/#ticker-information
 	// REF: https://mxcdevelop.github.io/apidocs/spot_v2_en/#k-line
 	// REF: https://mxcdevelop.github.io/apidocs/spot_v2_en/#overview
+	// REF: https://mexcdevelop.github.io/apidocs/spot_v3_en/#websocket-market-streams
 	MexcProvider struct {
 		wsURL           url.URL
 		wsClient        *websocket.Conn
+		writeMtx        sync.Mutex // serializes the writes to wsClient
 		logger          zerolog.Logger
 		mtx             sync.RWMutex
-		endpoints       config.ProviderEndpoint
+		endpoints       *EndpointPool
+		apiVersion      string                        // config.MexcAPIV2 or config.MexcAPIV3
 		tickers         map[string]MexcTicker         // Symbol => MexcTicker
 		candles         map[string][]MexcCandle       // Symbol => MexcCandle
 		subscribedPairs map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		*subscriptionTracker
 	}
 
 	// MexcTicker ticker price response. https://pkg.go.dev/encoding/json#Unmarshal
@@ -109,26 +117,38 @@ func NewMexcProvider(
 	endpoints config.ProviderEndpoint,
 	pairs ...types.CurrencyPair,
 ) (*MexcProvider, error) {
-	if (endpoints.Name) != config.ProviderMexc {
-		endpoints = config.ProviderEndpoint{
-			Name:      config.ProviderMexc,
-			Rest:      mexcRestHost,
-			Websocket: mexcWSHost,
-		}
+	apiVersion := config.MexcAPIV3
+	if endpoints.Name == config.ProviderMexc && len(endpoints.APIVersion) > 0 {
+		apiVersion = endpoints.APIVersion
 	}
 
 	wsURL := url.URL{
 		Scheme: "wss",
-		Host:   endpoints.Websocket,
-		Path:   mexcWSPath,
+		Path:   mexcV3WSPath,
+	}
+	defaultEndpoint := config.ProviderEndpoint{
+		Name:      config.ProviderMexc,
+		Rest:      mexcV3RestHost,
+		Websocket: mexcV3WSHost,
+	}
+	if apiVersion == config.MexcAPIV2 {
+		wsURL.Path = mexcWSPath
+		defaultEndpoint.Rest = mexcRestHost
+		defaultEndpoint.Websocket = mexcWSHost
 	}
 
-	wsConn, response, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	endpoints = withDefaultEndpoint(endpoints, defaultEndpoint)
+	wsURL.Host = endpoints.Websocket
+
+	endpointPool, err := NewEndpointPool(endpoints)
+	if err != nil {
+		return nil, err
+	}
+	if apiVersion == config.MexcAPIV3 {
+		endpointPool.SetFrameDecoder(MexcProtoFrameDecoder)
+	}
+
+	wsConn, err := endpointPool.DialWebsocket(wsURL)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to mexc websocket: %w", err)
 	}
@@ -137,10 +157,13 @@ func NewMexcProvider(
 		wsURL:           wsURL,
 		wsClient:        wsConn,
 		logger:          logger.With().Str("provider", "mexc").Logger(),
-		endpoints:       endpoints,
+		endpoints:       endpointPool,
+		apiVersion:      apiVersion,
 		tickers:         map[string]MexcTicker{},
 		candles:         map[string][]MexcCandle{},
 		subscribedPairs: map[string]types.CurrencyPair{},
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderMexc, endpointPool.SilenceTimeout()),
 	}
 
 	if err := provider.SubscribeCurrencyPairs(pairs...); err != nil {
@@ -148,6 +171,10 @@ func NewMexcProvider(
 	}
 
 	go provider.handleWebSocketMsgs(ctx)
+	go provider.retrySubscriptions(ctx, provider.logger, provider.SubscribeCurrencyPairs)
+	if apiVersion == config.MexcAPIV3 {
+		go provider.pingLoop(ctx)
+	}
 
 	return provider, nil
 }
@@ -197,11 +224,16 @@ func (p *MexcProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
 	}
 
 	p.setSubscribedPairs(cps...)
+	p.trackSubscriptions(cps...)
 	return nil
 }
 
 // subscribeChannels subscribe to the ticker and candle channels for all currency pairs.
 func (p *MexcProvider) subscribeChannels(cps ...types.CurrencyPair) error {
+	if p.apiVersion == config.MexcAPIV3 {
+		return p.subscribeV3Channels(cps...)
+	}
+
 	if err := p.subscribeTickers(cps...); err != nil {
 		return err
 	}
@@ -272,6 +304,11 @@ func (p *MexcProvider) getCandlePrices(key string) ([]CandlePrice, error) {
 }
 
 func (p *MexcProvider) messageReceived(messageType int, bz []byte) {
+	if p.apiVersion == config.MexcAPIV3 {
+		p.messageReceivedV3(messageType, bz)
+		return
+	}
+
 	if messageType != websocket.TextMessage {
 		return
 	}
@@ -334,6 +371,7 @@ func (p *MexcProvider) setTickerPair(symbol string, ticker MexcTickerData) {
 	// msg := mt.Symbol + " - $" + mt.LastPrice + " - V: " + mt.Volume
 	// p.logger.Warn().Msgf("mexc got price: %d", msg)
 	p.tickers[symbol] = mt
+	p.setSubscriptionActive(symbol)
 }
 
 func (p *MexcProvider) setCandlePair(candle MexcCandle) {
@@ -372,8 +410,17 @@ func (p *MexcProvider) handleWebSocketMsgs(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-time.After(defaultReadNewWSMessage):
-			messageType, bz, err := p.wsClient.ReadMessage()
+			messageType, bz, err := p.endpoints.ReadMessage(p.wsClient)
 			if err != nil {
+				if errors.Is(err, ErrSilentConnection) {
+					p.logger.Warn().Err(err).Msg("mexc: reconnecting silent websocket")
+					if err := p.reconnect(); err != nil {
+						p.logger.Err(err).Msg("mexc: error reconnecting")
+						p.keepReconnecting()
+					}
+					continue
+				}
+
 				// if some error occurs continue to try to read the next message.
 				p.logger.Err(err).Msg("mexc: could not read message")
 				continue
@@ -400,18 +447,16 @@ func (p *MexcProvider) handleWebSocketMsgs(ctx context.Context) {
 // send a ping for 10-20 seconds
 func (p *MexcProvider) reconnect() error {
 	p.wsClient.Close()
+	p.endpoints.ReportDisconnect()
 
 	p.logger.Debug().Msg("mexc: reconnecting websocket")
-	wsConn, response, err := websocket.DefaultDialer.Dial(p.wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	wsConn, err := p.endpoints.DialWebsocket(p.wsURL)
 	if err != nil {
 		return fmt.Errorf("mexc: error reconnect to mexc websocket: %w", err)
 	}
+	p.writeMtx.Lock()
 	p.wsClient = wsConn
+	p.writeMtx.Unlock()
 
 	currencyPairs := p.subscribedPairsToSlice()
 
@@ -459,19 +504,36 @@ func (p *MexcProvider) setSubscribedPairs(cps ...types.CurrencyPair) {
 func (p *MexcProvider) subscribePairs(pairs ...string) error {
 	for _, cp := range pairs {
 		subsMsg := newMexcCandleSubscriptionMsg(cp)
-		err := p.wsClient.WriteJSON(subsMsg)
+		err := p.sendJSON(subsMsg)
 		if err != nil {
 			return err
 		}
 	}
 	subsMsg := newMexcTickerSubscriptionMsg()
-	return p.wsClient.WriteJSON(subsMsg)
+	return p.sendJSON(subsMsg)
+}
+
+// sendJSON writes the msg to the websocket.
+func (p *MexcProvider) sendJSON(msg interface{}) error {
+	p.writeMtx.Lock()
+	defer p.writeMtx.Unlock()
+
+	return p.wsClient.WriteJSON(msg)
+}
+
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *MexcProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
 }
 
 // GetAvailablePairs returns all pairs to which the provider can subscribe.
 // ex.: map["ATOMUSDT" => {}, "UMEEUSDC" => {}].
 func (p *MexcProvider) GetAvailablePairs() (map[string]struct{}, error) {
-	resp, err := http.Get(p.endpoints.Rest + mexcRestPath)
+	if p.apiVersion == config.MexcAPIV3 {
+		return p.getV3AvailablePairs()
+	}
+
+	resp, err := p.endpoints.HTTPGet(mexcRestPath)
 	if err != nil {
 		return nil, err
 	}
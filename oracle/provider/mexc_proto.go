@@ -0,0 +1,224 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the MEXC v3 protobuf messages used by the provider.
+//
+// REF: https://github.com/mexcdevelop/websocket-proto
+const (
+	// PushDataV3ApiWrapper
+	mexcWrapperChannelField     protowire.Number = 1
+	mexcWrapperSymbolField      protowire.Number = 3
+	mexcWrapperSendTimeField    protowire.Number = 6
+	mexcWrapperDealsField       protowire.Number = 301 // PublicDealsV3Api
+	mexcWrapperKlineField       protowire.Number = 308 // PublicSpotKlineV3Api
+	mexcWrapperMiniTickerField  protowire.Number = 309 // PublicMiniTickerV3Api
+	mexcWrapperAggreDealsField  protowire.Number = 314 // PublicAggreDealsV3Api
+	mexcDealsItemField          protowire.Number = 1   // repeated deal of the deals messages
+	mexcDealPriceField          protowire.Number = 1
+	mexcDealQuantityField       protowire.Number = 2
+	mexcDealTradeTypeField      protowire.Number = 3
+	mexcDealTimeField           protowire.Number = 4
+	mexcKlineIntervalField      protowire.Number = 1
+	mexcKlineWindowStartField   protowire.Number = 2
+	mexcKlineClosingPriceField  protowire.Number = 4
+	mexcKlineVolumeField        protowire.Number = 7
+	mexcKlineWindowEndField     protowire.Number = 9
+	mexcMiniTickerPriceField    protowire.Number = 2
+	mexcMiniTickerQuantityField protowire.Number = 8 // base asset volume, field 7 being the quote one
+)
+
+type (
+	// MexcPushData defines the fields of a MEXC v3 protobuf push message used
+	// by the provider. Only one of Deals, Kline and MiniTicker is set.
+	MexcPushData struct {
+		Channel    string          `json:"channel"`              // ex.: spot@public.aggre.deals.v3.api.pb@100ms@ATOMUSDT
+		Symbol     string          `json:"symbol"`               // ex.: ATOMUSDT
+		SendTime   int64           `json:"sendTime"`             // unix milliseconds
+		Deals      []MexcDeal      `json:"deals,omitempty"`      // trades of the deals channels
+		Kline      *MexcKline      `json:"kline,omitempty"`      // candle of the kline channel
+		MiniTicker *MexcMiniTicker `json:"miniTicker,omitempty"` // 24h statistics of the mini ticker channel
+	}
+
+	// MexcDeal defines a trade of the MEXC v3 deals channels.
+	MexcDeal struct {
+		Price     string `json:"price"`     // ex.: 10.42
+		Quantity  string `json:"quantity"`  // ex.: 3.1
+		TradeType int32  `json:"tradeType"` // 1 buy, 2 sell
+		Time      int64  `json:"time"`      // unix milliseconds
+	}
+
+	// MexcKline defines a candle of the MEXC v3 kline channel.
+	MexcKline struct {
+		Interval     string `json:"interval"`     // ex.: Min1
+		WindowStart  int64  `json:"windowStart"`  // unix seconds
+		ClosingPrice string `json:"closingPrice"` // ex.: 10.42
+		Volume       string `json:"volume"`       // base asset volume ex.: 1200.5
+		WindowEnd    int64  `json:"windowEnd"`    // unix seconds
+	}
+
+	// MexcMiniTicker defines the 24h statistics of the MEXC v3 mini ticker
+	// channel.
+	MexcMiniTicker struct {
+		Price  string `json:"price"`  // ex.: 10.42
+		Volume string `json:"volume"` // base asset volume of the last 24h ex.: 250000.5
+	}
+)
+
+// MexcProtoFrameDecoder is the FrameDecoder of the MEXC v3 API, decoding the
+// protobuf push data of the binary frames into json MexcPushData text
+// messages. Text frames, the responses to the requests, are kept as is.
+func MexcProtoFrameDecoder(messageType int, bz []byte) (int, []byte, error) {
+	if messageType != websocket.BinaryMessage {
+		return messageType, bz, nil
+	}
+
+	data, err := decodeMexcPushData(bz)
+	if err != nil {
+		return messageType, nil, err
+	}
+
+	decoded, err := json.Marshal(data)
+	if err != nil {
+		return messageType, nil, err
+	}
+	return websocket.TextMessage, decoded, nil
+}
+
+// decodeMexcPushData decodes a MEXC v3 protobuf push message.
+func decodeMexcPushData(bz []byte) (MexcPushData, error) {
+	var data MexcPushData
+	err := consumeProtoFields(bz, func(num protowire.Number, value []byte, varint uint64) error {
+		var err error
+		switch num {
+		case mexcWrapperChannelField:
+			data.Channel = string(value)
+		case mexcWrapperSymbolField:
+			data.Symbol = string(value)
+		case mexcWrapperSendTimeField:
+			data.SendTime = int64(varint)
+		case mexcWrapperDealsField, mexcWrapperAggreDealsField:
+			data.Deals, err = decodeMexcDeals(value)
+		case mexcWrapperKlineField:
+			var kline MexcKline
+			kline, err = decodeMexcKline(value)
+			data.Kline = &kline
+		case mexcWrapperMiniTickerField:
+			var miniTicker MexcMiniTicker
+			miniTicker, err = decodeMexcMiniTicker(value)
+			data.MiniTicker = &miniTicker
+		}
+		return err
+	})
+	if err != nil {
+		return MexcPushData{}, fmt.Errorf("mexc: failed to decode push data: %w", err)
+	}
+
+	return data, nil
+}
+
+// decodeMexcDeals decodes the deals of a PublicDealsV3Api or a
+// PublicAggreDealsV3Api message, which share the same layout.
+func decodeMexcDeals(bz []byte) ([]MexcDeal, error) {
+	deals := []MexcDeal{}
+	err := consumeProtoFields(bz, func(num protowire.Number, value []byte, _ uint64) error {
+		if num != mexcDealsItemField {
+			return nil
+		}
+
+		var deal MexcDeal
+		err := consumeProtoFields(value, func(num protowire.Number, value []byte, varint uint64) error {
+			switch num {
+			case mexcDealPriceField:
+				deal.Price = string(value)
+			case mexcDealQuantityField:
+				deal.Quantity = string(value)
+			case mexcDealTradeTypeField:
+				deal.TradeType = int32(varint)
+			case mexcDealTimeField:
+				deal.Time = int64(varint)
+			}
+			return nil
+		})
+		deals = append(deals, deal)
+		return err
+	})
+	return deals, err
+}
+
+// decodeMexcKline decodes a PublicSpotKlineV3Api message.
+func decodeMexcKline(bz []byte) (MexcKline, error) {
+	var kline MexcKline
+	err := consumeProtoFields(bz, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case mexcKlineIntervalField:
+			kline.Interval = string(value)
+		case mexcKlineWindowStartField:
+			kline.WindowStart = int64(varint)
+		case mexcKlineClosingPriceField:
+			kline.ClosingPrice = string(value)
+		case mexcKlineVolumeField:
+			kline.Volume = string(value)
+		case mexcKlineWindowEndField:
+			kline.WindowEnd = int64(varint)
+		}
+		return nil
+	})
+	return kline, err
+}
+
+// decodeMexcMiniTicker decodes a PublicMiniTickerV3Api message.
+func decodeMexcMiniTicker(bz []byte) (MexcMiniTicker, error) {
+	var miniTicker MexcMiniTicker
+	err := consumeProtoFields(bz, func(num protowire.Number, value []byte, _ uint64) error {
+		switch num {
+		case mexcMiniTickerPriceField:
+			miniTicker.Price = string(value)
+		case mexcMiniTickerQuantityField:
+			miniTicker.Volume = string(value)
+		}
+		return nil
+	})
+	return miniTicker, err
+}
+
+// consumeProtoFields calls fn with every field of the protobuf message, along
+// with its value for length delimited fields or its varint otherwise. Fields
+// of other wire types are skipped.
+func consumeProtoFields(bz []byte, fn func(num protowire.Number, value []byte, varint uint64) error) error {
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		bz = bz[n:]
+
+		var (
+			value  []byte
+			varint uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(bz)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(bz)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, bz)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		bz = bz[n:]
+
+		if err := fn(num, value, varint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,10 +2,14 @@ package provider
 
 import (
 	"context"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
 
 	"cosmossdk.io/math"
 
@@ -102,3 +106,203 @@ func TestMexcCurrencyPairToMexcPair(t *testing.T) {
 	MexcSymbol := currencyPairToMexcPair(cp)
 	require.Equal(t, MexcSymbol, "ATOM_USDT")
 }
+
+// appendMexcDeal appends a PublicAggreDealsV3ApiItem to a deals message.
+func appendMexcDeal(bz []byte, price, quantity string, timeStamp int64) []byte {
+	var deal []byte
+	deal = protowire.AppendTag(deal, mexcDealPriceField, protowire.BytesType)
+	deal = protowire.AppendString(deal, price)
+	deal = protowire.AppendTag(deal, mexcDealQuantityField, protowire.BytesType)
+	deal = protowire.AppendString(deal, quantity)
+	deal = protowire.AppendTag(deal, mexcDealTradeTypeField, protowire.VarintType)
+	deal = protowire.AppendVarint(deal, 1)
+	deal = protowire.AppendTag(deal, mexcDealTimeField, protowire.VarintType)
+	deal = protowire.AppendVarint(deal, uint64(timeStamp))
+
+	bz = protowire.AppendTag(bz, mexcDealsItemField, protowire.BytesType)
+	return protowire.AppendBytes(bz, deal)
+}
+
+// newMexcPushData returns a PushDataV3ApiWrapper with the body in the field.
+func newMexcPushData(channel, symbol string, field protowire.Number, body []byte) []byte {
+	var bz []byte
+	bz = protowire.AppendTag(bz, mexcWrapperChannelField, protowire.BytesType)
+	bz = protowire.AppendString(bz, channel)
+	bz = protowire.AppendTag(bz, mexcWrapperSymbolField, protowire.BytesType)
+	bz = protowire.AppendString(bz, symbol)
+	// unknown fields are skipped
+	bz = protowire.AppendTag(bz, 4, protowire.BytesType)
+	bz = protowire.AppendString(bz, "C02__1234")
+	bz = protowire.AppendTag(bz, mexcWrapperSendTimeField, protowire.VarintType)
+	bz = protowire.AppendVarint(bz, 1700000000123)
+	bz = protowire.AppendTag(bz, field, protowire.BytesType)
+	return protowire.AppendBytes(bz, body)
+}
+
+// newMexcKline returns a PublicSpotKlineV3Api message.
+func newMexcKline(windowStart int64, closingPrice, volume string) []byte {
+	var bz []byte
+	bz = protowire.AppendTag(bz, mexcKlineIntervalField, protowire.BytesType)
+	bz = protowire.AppendString(bz, "Min1")
+	bz = protowire.AppendTag(bz, mexcKlineWindowStartField, protowire.VarintType)
+	bz = protowire.AppendVarint(bz, uint64(windowStart))
+	bz = protowire.AppendTag(bz, mexcKlineClosingPriceField, protowire.BytesType)
+	bz = protowire.AppendString(bz, closingPrice)
+	bz = protowire.AppendTag(bz, mexcKlineVolumeField, protowire.BytesType)
+	bz = protowire.AppendString(bz, volume)
+	bz = protowire.AppendTag(bz, mexcKlineWindowEndField, protowire.VarintType)
+	return protowire.AppendVarint(bz, uint64(windowStart+60))
+}
+
+// newMexcMiniTicker returns a PublicMiniTickerV3Api message.
+func newMexcMiniTicker(price, quantity string) []byte {
+	var bz []byte
+	bz = protowire.AppendTag(bz, mexcMiniTickerPriceField, protowire.BytesType)
+	bz = protowire.AppendString(bz, price)
+	// the quote volume is skipped
+	bz = protowire.AppendTag(bz, 7, protowire.BytesType)
+	bz = protowire.AppendString(bz, "2500000")
+	bz = protowire.AppendTag(bz, mexcMiniTickerQuantityField, protowire.BytesType)
+	return protowire.AppendString(bz, quantity)
+}
+
+func TestDecodeMexcPushData(t *testing.T) {
+	deals := appendMexcDeal(nil, "10.41", "2", 1700000000000)
+	deals = appendMexcDeal(deals, "10.42", "3", 1700000000100)
+	data, err := decodeMexcPushData(newMexcPushData(
+		"spot@public.aggre.deals.v3.api.pb@100ms@ATOMUSDT", "ATOMUSDT", mexcWrapperAggreDealsField, deals,
+	))
+	require.NoError(t, err)
+	require.Equal(t, "spot@public.aggre.deals.v3.api.pb@100ms@ATOMUSDT", data.Channel)
+	require.Equal(t, "ATOMUSDT", data.Symbol)
+	require.Equal(t, int64(1700000000123), data.SendTime)
+	require.Nil(t, data.Kline)
+	require.Equal(t, []MexcDeal{
+		{Price: "10.41", Quantity: "2", TradeType: 1, Time: 1700000000000},
+		{Price: "10.42", Quantity: "3", TradeType: 1, Time: 1700000000100},
+	}, data.Deals)
+
+	data, err = decodeMexcPushData(newMexcPushData(
+		"spot@public.kline.v3.api.pb@ATOMUSDT@Min1", "ATOMUSDT", mexcWrapperKlineField, newMexcKline(1700000000, "10.5", "120"),
+	))
+	require.NoError(t, err)
+	require.Empty(t, data.Deals)
+	require.Equal(t, &MexcKline{
+		Interval:     "Min1",
+		WindowStart:  1700000000,
+		ClosingPrice: "10.5",
+		Volume:       "120",
+		WindowEnd:    1700000060,
+	}, data.Kline)
+
+	data, err = decodeMexcPushData(newMexcPushData(
+		"spot@public.miniTicker.v3.api.pb@ATOMUSDT@UTC+8", "ATOMUSDT", mexcWrapperMiniTickerField, newMexcMiniTicker("10.5", "240000"),
+	))
+	require.NoError(t, err)
+	require.Nil(t, data.Kline)
+	require.Equal(t, &MexcMiniTicker{Price: "10.5", Volume: "240000"}, data.MiniTicker)
+
+	_, err = decodeMexcPushData([]byte{0x0a, 0x05, 'A'})
+	require.ErrorContains(t, err, "failed to decode push data")
+}
+
+func TestMexcProvider_V3(t *testing.T) {
+	subscriptions := make(chan MexcV3Request, 1)
+	server := NewMockProviderServer()
+	server.SetHandler(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		var msg MexcV3Request
+		if err := c.ReadJSON(&msg); err != nil {
+			return
+		}
+		subscriptions <- msg
+
+		_ = c.WriteMessage(websocket.TextMessage, []byte(
+			`{"id":0,"code":0,"msg":"spot@public.aggre.deals.v3.api.pb@100ms@ATOMUSDT,spot@public.kline.v3.api.pb@ATOMUSDT@Min1"}`,
+		))
+		_ = c.WriteMessage(websocket.TextMessage, []byte(
+			`{"id":0,"code":0,"msg":"Not Subscribed successfully! [spot@public.aggre.deals.v3.api.pb@100ms@FOOUSDT].  Reason： Blocked! "}`,
+		))
+
+		now := time.Now()
+		_ = c.WriteMessage(websocket.BinaryMessage, newMexcPushData(
+			"spot@public.kline.v3.api.pb@ATOMUSDT@Min1", "ATOMUSDT", mexcWrapperKlineField,
+			newMexcKline(now.Unix()-30, "10.5", "120"),
+		))
+		_ = c.WriteMessage(websocket.BinaryMessage, newMexcPushData(
+			"spot@public.aggre.deals.v3.api.pb@100ms@ATOMUSDT", "ATOMUSDT", mexcWrapperAggreDealsField,
+			appendMexcDeal(appendMexcDeal(nil, "10.42", "3", now.UnixMilli()), "10.41", "2", now.UnixMilli()-100),
+		))
+		_ = c.WriteMessage(websocket.BinaryMessage, newMexcPushData(
+			"spot@public.miniTicker.v3.api.pb@ATOMUSDT@UTC+8", "ATOMUSDT", mexcWrapperMiniTickerField,
+			newMexcMiniTicker("10.43", "240000"),
+		))
+
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	atomUSDT := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
+	fooUSDT := types.CurrencyPair{Base: "FOO", Quote: "USDT"}
+	p, err := NewMexcProvider(
+		context.TODO(),
+		zerolog.Nop(),
+		config.ProviderEndpoint{
+			Name:      config.ProviderMexc,
+			Rest:      "https://" + server.GetBaseURL(),
+			Websocket: server.GetBaseURL(),
+		},
+		atomUSDT,
+		fooUSDT,
+	)
+	require.NoError(t, err)
+
+	msg := <-subscriptions
+	require.Equal(t, MexcV3Request{
+		Method: "SUBSCRIPTION",
+		Params: []string{
+			"spot@public.aggre.deals.v3.api.pb@100ms@ATOMUSDT",
+			"spot@public.kline.v3.api.pb@ATOMUSDT@Min1",
+			"spot@public.miniTicker.v3.api.pb@ATOMUSDT@UTC+8",
+			"spot@public.aggre.deals.v3.api.pb@100ms@FOOUSDT",
+			"spot@public.kline.v3.api.pb@FOOUSDT@Min1",
+			"spot@public.miniTicker.v3.api.pb@FOOUSDT@UTC+8",
+		},
+	}, msg)
+
+	// the ticker keeps the price of the last deal and the 24h volume of the
+	// mini ticker
+	require.Eventually(t, func() bool {
+		prices, _ := p.GetTickerPrices(atomUSDT)
+		price, ok := prices["ATOMUSDT"]
+		return ok && price.Volume.Equal(math.LegacyMustNewDecFromStr("240000"))
+	}, 5*time.Second, 50*time.Millisecond)
+
+	prices, err := p.GetTickerPrices(atomUSDT)
+	require.NoError(t, err)
+	require.Equal(t, math.LegacyMustNewDecFromStr("10.42"), prices["ATOMUSDT"].Price)
+
+	candles, err := p.GetCandlePrices(atomUSDT)
+	require.NoError(t, err)
+	require.Len(t, candles["ATOMUSDT"], 1)
+	require.Equal(t, math.LegacyMustNewDecFromStr("10.5"), candles["ATOMUSDT"][0].Price)
+
+	statuses := p.SubscriptionStatuses()
+	require.Equal(t, SubscriptionActive, statuses["ATOMUSDT"].State)
+	require.Equal(t, SubscriptionFailed, statuses["FOOUSDT"].State)
+}
+
+func TestMexcV3ChannelSymbol(t *testing.T) {
+	require.Equal(t, "ATOMUSDT", mexcV3ChannelSymbol("spot@public.aggre.deals.v3.api.pb@100ms@ATOMUSDT"))
+	require.Equal(t, "ATOMUSDT", mexcV3ChannelSymbol("spot@public.kline.v3.api.pb@ATOMUSDT@Min1"))
+	require.Equal(t, "ATOMUSDT", mexcV3ChannelSymbol("spot@public.miniTicker.v3.api.pb@ATOMUSDT@UTC+8"))
+}
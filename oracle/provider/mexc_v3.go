@@ -0,0 +1,298 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+const (
+	mexcV3WSHost             = "wbs-api.mexc.com"
+	mexcV3WSPath             = "/ws"
+	mexcV3RestHost           = "https://api.mexc.com"
+	mexcV3RestPath           = "/api/v3/exchangeInfo"
+	mexcV3PingInterval       = 20 * time.Second
+	mexcV3MaxSubscription    = 30 // channels per subscription message
+	mexcV3DealsChannel       = "spot@public.aggre.deals.v3.api.pb@100ms@"
+	mexcV3KlineChannel       = "spot@public.kline.v3.api.pb@"
+	mexcV3KlineInterval      = "Min1"
+	mexcV3MiniTickerChannel  = "spot@public.miniTicker.v3.api.pb@"
+	mexcV3MiniTickerTimezone = "UTC+8"
+)
+
+// mexcV3ErrorChannelRegex matches the channel of a subscription error ex.:
+// "Not Subscribed successfully! [spot@public.kline.v3.api.pb@FOOUSDT@Min1]. Reason: Blocked!"
+var mexcV3ErrorChannelRegex = regexp.MustCompile(`\[(spot@[^\]]+)\]`)
+
+type (
+	// MexcV3Request defines a subscription or ping request of the v3 API.
+	MexcV3Request struct {
+		Method string   `json:"method"`           // SUBSCRIPTION, UNSUBSCRIPTION or PING
+		Params []string `json:"params,omitempty"` // channels ex.: spot@public.kline.v3.api.pb@ATOMUSDT@Min1
+	}
+
+	// MexcV3Response defines the json response of the v3 API to a request.
+	MexcV3Response struct {
+		ID   int64  `json:"id"`
+		Code int64  `json:"code"`
+		Msg  string `json:"msg"` // subscribed channels, "PONG" or error description
+	}
+
+	// MexcV3ExchangeInfo defines the response structure of the v3 exchange
+	// information.
+	MexcV3ExchangeInfo struct {
+		Symbols []struct {
+			Base  string `json:"baseAsset"`  // ex.: ATOM
+			Quote string `json:"quoteAsset"` // ex.: USDT
+		} `json:"symbols"`
+	}
+)
+
+// subscribeV3Channels subscribes to the deals, kline and mini ticker channels
+// of the pairs, splitting the channels in messages of at most
+// mexcV3MaxSubscription.
+func (p *MexcProvider) subscribeV3Channels(cps ...types.CurrencyPair) error {
+	channels := make([]string, 0, len(cps)*3)
+	for _, cp := range cps {
+		channels = append(channels,
+			mexcV3DealsChannel+cp.String(),
+			mexcV3KlineChannel+cp.String()+"@"+mexcV3KlineInterval,
+			mexcV3MiniTickerChannel+cp.String()+"@"+mexcV3MiniTickerTimezone,
+		)
+	}
+
+	for start := 0; start < len(channels); start += mexcV3MaxSubscription {
+		end := start + mexcV3MaxSubscription
+		if end > len(channels) {
+			end = len(channels)
+		}
+
+		if err := p.sendJSON(MexcV3Request{Method: "SUBSCRIPTION", Params: channels[start:end]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pingLoop keeps the v3 connection alive, the server closes connections
+// without any request for a minute.
+func (p *MexcProvider) pingLoop(ctx context.Context) {
+	ticker := time.NewTicker(mexcV3PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.sendJSON(MexcV3Request{Method: "PING"}); err != nil {
+				p.logger.Err(err).Msg("mexc: failed to send ping")
+			}
+		}
+	}
+}
+
+// messageReceivedV3 handles the json responses and the push data of the v3
+// API, decoded from protobuf by the MexcProtoFrameDecoder of the endpoints.
+func (p *MexcProvider) messageReceivedV3(messageType int, bz []byte) {
+	if messageType != websocket.TextMessage {
+		return
+	}
+
+	var data MexcPushData
+	if err := json.Unmarshal(bz, &data); err != nil {
+		p.logger.Error().Int("length", len(bz)).Err(err).Msg("mexc: error on receive message")
+		return
+	}
+
+	if len(data.Channel) == 0 {
+		p.messageReceivedV3Response(bz)
+		return
+	}
+
+	switch {
+	case len(data.Deals) > 0:
+		p.setV3Deals(data.Symbol, data.Deals)
+		telemetry.IncrCounter(
+			1,
+			"websocket",
+			"message",
+			"type",
+			"ticker",
+			"provider",
+			config.ProviderMexc,
+		)
+
+	case data.Kline != nil:
+		p.setV3Kline(data.Symbol, *data.Kline)
+		telemetry.IncrCounter(
+			1,
+			"websocket",
+			"message",
+			"type",
+			"candle",
+			"provider",
+			config.ProviderMexc,
+		)
+
+	case data.MiniTicker != nil:
+		p.setV3MiniTicker(data.Symbol, *data.MiniTicker)
+		telemetry.IncrCounter(
+			1,
+			"websocket",
+			"message",
+			"type",
+			"mini_ticker",
+			"provider",
+			config.ProviderMexc,
+		)
+	}
+}
+
+// messageReceivedV3Response handles the responses to the subscriptions and
+// pings.
+func (p *MexcProvider) messageReceivedV3Response(bz []byte) {
+	var resp MexcV3Response
+	if err := json.Unmarshal(bz, &resp); err != nil {
+		p.logger.Error().Int("length", len(bz)).Err(err).Msg("mexc: error on receive message")
+		return
+	}
+
+	if resp.Msg == "PONG" {
+		return
+	}
+
+	if match := mexcV3ErrorChannelRegex.FindStringSubmatch(resp.Msg); match != nil {
+		p.logger.Error().Msg(resp.Msg)
+		for _, channel := range strings.Split(match[1], ",") {
+			p.setSubscriptionFailed(mexcV3ChannelSymbol(channel), resp.Msg)
+		}
+		return
+	}
+
+	for _, channel := range strings.Split(resp.Msg, ",") {
+		if strings.HasPrefix(channel, mexcV3DealsChannel) {
+			p.setSubscriptionActive(mexcV3ChannelSymbol(channel))
+		}
+	}
+}
+
+// setV3Deals sets the price of the most recent deal as the ticker price of the
+// symbol, keeping the 24h volume of its mini ticker.
+func (p *MexcProvider) setV3Deals(symbol string, deals []MexcDeal) {
+	latest := deals[0]
+	for _, deal := range deals[1:] {
+		if deal.Time >= latest.Time {
+			latest = deal
+		}
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	ticker, ok := p.tickers[symbol]
+	if !ok {
+		ticker = MexcTicker{Symbol: symbol, Volume: "0"}
+	}
+	ticker.LastPrice = latest.Price
+	p.tickers[symbol] = ticker
+	p.setSubscriptionActive(symbol)
+}
+
+// setV3MiniTicker sets the 24h volume of the mini ticker as the ticker volume
+// of the symbol, and its price until a deal is received.
+func (p *MexcProvider) setV3MiniTicker(symbol string, miniTicker MexcMiniTicker) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	ticker, ok := p.tickers[symbol]
+	if !ok {
+		ticker = MexcTicker{Symbol: symbol, LastPrice: miniTicker.Price}
+	}
+	ticker.Volume = miniTicker.Volume
+	p.tickers[symbol] = ticker
+}
+
+// setV3Kline sets the candle of the symbol, replacing the previous update of
+// the same window.
+func (p *MexcProvider) setV3Kline(symbol string, kline MexcKline) {
+	closePrice, err := strconv.ParseFloat(kline.ClosingPrice, 64)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("mexc: failed to parse candle price")
+		return
+	}
+	volume, err := strconv.ParseFloat(kline.Volume, 64)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("mexc: failed to parse candle volume")
+		return
+	}
+
+	candle := MexcCandle{
+		Symbol: symbol,
+		Metadata: MexcCandleMetadata{
+			Close:     closePrice,
+			TimeStamp: kline.WindowStart * int64(time.Second/time.Millisecond),
+			Volume:    volume,
+		},
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	staleTime := PastUnixTime(providerCandlePeriod)
+	candleList := []MexcCandle{candle}
+	for _, c := range p.candles[symbol] {
+		if staleTime < c.Metadata.TimeStamp && c.Metadata.TimeStamp != candle.Metadata.TimeStamp {
+			candleList = append(candleList, c)
+		}
+	}
+	p.candles[symbol] = candleList
+}
+
+// getV3AvailablePairs returns all pairs to which the provider can subscribe
+// using the v3 exchange information.
+func (p *MexcProvider) getV3AvailablePairs() (map[string]struct{}, error) {
+	resp, err := p.endpoints.HTTPGet(mexcV3RestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var exchangeInfo MexcV3ExchangeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&exchangeInfo); err != nil {
+		return nil, err
+	}
+
+	availablePairs := make(map[string]struct{}, len(exchangeInfo.Symbols))
+	for _, symbol := range exchangeInfo.Symbols {
+		cp := types.CurrencyPair{
+			Base:  strings.ToUpper(symbol.Base),
+			Quote: strings.ToUpper(symbol.Quote),
+		}
+		availablePairs[cp.String()] = struct{}{}
+	}
+
+	return availablePairs, nil
+}
+
+// mexcV3ChannelSymbol returns the symbol of a v3 channel ex.:
+// spot@public.kline.v3.api.pb@ATOMUSDT@Min1 returns ATOMUSDT.
+func mexcV3ChannelSymbol(channel string) string {
+	channel = strings.TrimSpace(channel)
+	parts := strings.Split(channel, "@")
+	suffixed := strings.HasPrefix(channel, mexcV3KlineChannel) || strings.HasPrefix(channel, mexcV3MiniTickerChannel)
+	if suffixed && len(parts) > 1 {
+		return parts[len(parts)-2]
+	}
+	return parts[len(parts)-1]
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,7 +28,20 @@ const (
 	okxRestPath  = "/api/v5/market/tickers?instType=SPOT"
 )
 
-var _ Provider = (*OkxProvider)(nil)
+var (
+	_ OrderBookProvider    = (*OkxProvider)(nil)
+	_ EndpointProvider     = (*OkxProvider)(nil)
+	_ SubscriptionProvider = (*OkxProvider)(nil)
+
+	// okxErrorInstIDRegex matches the instrument of a subscription error ex.:
+	// "Wrong URL or channel:tickers,instId:FOO-USDT doesn't exist."
+	okxErrorInstIDRegex = regexp.MustCompile(`instId:([A-Za-z0-9]+-[A-Za-z0-9]+)`)
+
+	// okxFallbacks are the mirrors used when the default endpoint fails.
+	okxFallbacks = []config.EndpointFallback{
+		{Rest: "https://aws.okx.com", Websocket: "wsaws.okx.com:8443"},
+	}
+)
 
 type (
 	// OkxProvider defines an Oracle provider implemented by the Okx public
@@ -36,15 +49,15 @@ type (
 	//
 	// REF: https://www.okx.com/docs-v5/en/#websocket-api-public-channel-tickers-channel
 	OkxProvider struct {
-		wsURL           url.URL
-		wsClient        *websocket.Conn
-		logger          zerolog.Logger
-		reconnectTimer  *time.Ticker
-		mtx             sync.RWMutex
-		endpoints       config.ProviderEndpoint
-		tickers         map[string]OkxTickerPair      // InstId => OkxTickerPair
-		candles         map[string][]OkxCandlePair    // InstId => 0kxCandlePair
-		subscribedPairs map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		wsShards          *WebsocketShards
+		logger            zerolog.Logger
+		mtx               sync.RWMutex
+		endpoints         *EndpointPool
+		tickers           map[string]OkxTickerPair      // InstId => OkxTickerPair
+		candles           map[string][]OkxCandlePair    // InstId => 0kxCandlePair
+		subscribedPairs   map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		*orderBookTracker                               // InstId => OrderBook
+		*subscriptionTracker
 	}
 
 	// OkxInstId defines the id Symbol of an pair.
@@ -85,6 +98,27 @@ type (
 		ID   OkxID      `json:"arg"`
 	}
 
+	// OkxOrderBook defines an order book snapshot of Okx.
+	OkxOrderBook struct {
+		Asks [][]string `json:"asks"` // Asks ex.: [["41006.8", "0.6", "0", "1"]] [price, size, deprecated, orders]
+		Bids [][]string `json:"bids"` // Bids ex.: [["41006.3", "0.3", "0", "2"]] [price, size, deprecated, orders]
+	}
+
+	// OkxOrderBookResponse defines the response structure of a Okx books5 request.
+	OkxOrderBookResponse struct {
+		Data []OkxOrderBook `json:"data"`
+		ID   OkxID          `json:"arg"`
+	}
+
+	// OkxEventResponse defines the response structure of the subscription
+	// acknowledgements and errors.
+	OkxEventResponse struct {
+		Event string `json:"event"` // subscribe or error
+		Code  string `json:"code"`  // error code ex.: 60018
+		Msg   string `json:"msg"`   // error message
+		ID    OkxID  `json:"arg"`   // subscribed topic
+	}
+
 	// OkxSubscriptionTopic Topic with the ticker to be subscribed/unsubscribed.
 	OkxSubscriptionTopic struct {
 		Channel string `json:"channel"` // Channel name ex.: tickers
@@ -110,13 +144,12 @@ func NewOkxProvider(
 	endpoints config.ProviderEndpoint,
 	pairs ...types.CurrencyPair,
 ) (*OkxProvider, error) {
-	if endpoints.Name != config.ProviderOkx {
-		endpoints = config.ProviderEndpoint{
-			Name:      config.ProviderOkx,
-			Rest:      okxRestHost,
-			Websocket: okxWSHost,
-		}
-	}
+	endpoints = withDefaultEndpoint(endpoints, config.ProviderEndpoint{
+		Name:      config.ProviderOkx,
+		Rest:      okxRestHost,
+		Websocket: okxWSHost,
+		Fallbacks: okxFallbacks,
+	})
 
 	wsURL := url.URL{
 		Scheme: "wss",
@@ -124,33 +157,42 @@ func NewOkxProvider(
 		Path:   okxWSPath,
 	}
 
-	wsConn, response, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
+	endpointPool, err := NewEndpointPool(endpoints)
 	if err != nil {
-		return nil, fmt.Errorf("error connecting to Okx websocket: %w", err)
+		return nil, err
 	}
 
 	provider := &OkxProvider{
-		wsURL:           wsURL,
-		wsClient:        wsConn,
-		logger:          logger.With().Str("provider", "okx").Logger(),
-		reconnectTimer:  time.NewTicker(okxPingCheck),
-		endpoints:       endpoints,
-		tickers:         map[string]OkxTickerPair{},
-		candles:         map[string][]OkxCandlePair{},
-		subscribedPairs: map[string]types.CurrencyPair{},
+		logger:           logger.With().Str("provider", "okx").Logger(),
+		endpoints:        endpointPool,
+		tickers:          map[string]OkxTickerPair{},
+		candles:          map[string][]OkxCandlePair{},
+		subscribedPairs:  map[string]types.CurrencyPair{},
+		orderBookTracker: newOrderBookTracker(config.ProviderOkx),
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderOkx, endpointPool.SilenceTimeout()),
 	}
-	provider.wsClient.SetPongHandler(provider.pongHandler)
+
+	// the connection breaks if no data is pushed for 30 seconds, so the
+	// string 'ping' is sent periodically, answered by 'pong'
+	provider.wsShards = NewWebsocketShards(
+		ctx,
+		config.ProviderOkx,
+		wsURL,
+		provider.endpoints,
+		endpoints.Connections,
+		provider.getSubscriptionMsgs,
+		provider.messageReceived,
+		okxPingCheck,
+		websocket.TextMessage,
+		provider.logger,
+	)
 
 	if err := provider.SubscribeCurrencyPairs(pairs...); err != nil {
 		return nil, err
 	}
 
-	go provider.handleReceivedTickers(ctx)
+	go provider.retrySubscriptions(ctx, provider.logger, provider.wsShards.ResubscribeCurrencyPairs)
 
 	return provider, nil
 }
@@ -195,7 +237,8 @@ func (p *OkxProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
 		return fmt.Errorf("currency pairs is empty")
 	}
 
-	if err := p.subscribeChannels(cps...); err != nil {
+	p.trackSubscriptions(cps...)
+	if err := p.wsShards.SubscribeCurrencyPairs(cps...); err != nil {
 		return err
 	}
 
@@ -211,49 +254,56 @@ func (p *OkxProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
 	return nil
 }
 
-// subscribeChannels subscribe all currency pairs into ticker and candle channels.
-func (p *OkxProvider) subscribeChannels(cps ...types.CurrencyPair) error {
-	return p.subscribeTickers(cps...)
-
-	// CONTEXT: we want to no-op the candles subscription because its using a different path and the price feeding provides more instantaneous data using ticker pricing anyways
-	// if err := p.subscribeTickers(cps...); err != nil {
-	// 	return err
-	// }
+// SetOrderBookPricing subscribes to the books5 channel of the pair and reports
+// its ticker price from the order book using the given pricing.
+func (p *OkxProvider) SetOrderBookPricing(cp types.CurrencyPair, pricing OrderBookPricing) error {
+	instID := currencyPairToOkxPair(cp)
+	msg := newOkxSubscriptionMsg(newOkxOrderBookSubscriptionTopic(instID))
+	if err := p.wsShards.AddSubscriptionMsgs(cp, []interface{}{msg}); err != nil {
+		return err
+	}
 
-	// return p.subscribeCandles(cps...)
+	p.setPricing(instID, pricing)
+	return nil
 }
 
-// subscribeTickers subscribe all currency pairs into ticker channel.
-func (p *OkxProvider) subscribeTickers(cps ...types.CurrencyPair) error {
-	topics := make([]OkxSubscriptionTopic, len(cps))
+// getSubscriptionMsgs returns the messages subscribing to the ticker channel
+// of the currency pairs, and to the books5 channel of those priced from their
+// order book.
+func (p *OkxProvider) getSubscriptionMsgs(cps ...types.CurrencyPair) []interface{} {
+	pricedSymbols := p.pricedSymbols()
+	tickerTopics := make([]OkxSubscriptionTopic, len(cps))
+	orderBookTopics := []OkxSubscriptionTopic{}
 
 	for i, cp := range cps {
-		topics[i] = newOkxTickerSubscriptionTopic(currencyPairToOkxPair(cp))
+		instID := currencyPairToOkxPair(cp)
+		tickerTopics[i] = newOkxTickerSubscriptionTopic(instID)
+		if _, ok := pricedSymbols[instID]; ok {
+			orderBookTopics = append(orderBookTopics, newOkxOrderBookSubscriptionTopic(instID))
+		}
 	}
 
-	return p.subscribePairs(topics...)
+	// CONTEXT: we want to no-op the candles subscription because its using a different path and the price feeding provides more instantaneous data using ticker pricing anyways
+	msgs := []interface{}{newOkxSubscriptionMsg(tickerTopics...)}
+	if len(orderBookTopics) > 0 {
+		msgs = append(msgs, newOkxSubscriptionMsg(orderBookTopics...))
+	}
+
+	return msgs
 }
 
 // CONTEXT: commented out because okx candles are currently unused
-// // subscribeCandles subscribe all currency pairs into candle channel.
-// func (p *OkxProvider) subscribeCandles(cps ...types.CurrencyPair) error {
+// // getCandleSubscriptionMsg returns the message subscribing all currency pairs into candle channel.
+// func (p *OkxProvider) getCandleSubscriptionMsg(cps ...types.CurrencyPair) OkxSubscriptionMsg {
 // 	topics := make([]OkxSubscriptionTopic, len(cps))
 
 // 	for i, cp := range cps {
 // 		topics[i] = newOkxCandleSubscriptionTopic(currencyPairToOkxPair(cp))
 // 	}
 
-// 	return p.subscribePairs(topics...)
+// 	return newOkxSubscriptionMsg(topics...)
 // }
 
-// subscribedPairsToSlice returns the map of subscribed pairs as slice
-func (p *OkxProvider) subscribedPairsToSlice() []types.CurrencyPair {
-	p.mtx.RLock()
-	defer p.mtx.RUnlock()
-
-	return types.MapPairsToSlice(p.subscribedPairs)
-}
-
 func (p *OkxProvider) getTickerPrice(cp types.CurrencyPair) (TickerPrice, error) {
 	p.mtx.RLock()
 	defer p.mtx.RUnlock()
@@ -264,7 +314,12 @@ func (p *OkxProvider) getTickerPrice(cp types.CurrencyPair) (TickerPrice, error)
 		return TickerPrice{}, fmt.Errorf("okx provider failed to get ticker price for %s", instrumentID)
 	}
 
-	return tickerPair.toTickerPrice()
+	tickerPrice, err := tickerPair.toTickerPrice()
+	if err != nil {
+		return TickerPrice{}, err
+	}
+
+	return p.applyOrderBookPricing(p.logger, instrumentID, tickerPrice), nil
 }
 
 func (p *OkxProvider) getCandlePrices(cp types.CurrencyPair) ([]CandlePrice, error) {
@@ -288,47 +343,24 @@ func (p *OkxProvider) getCandlePrices(cp types.CurrencyPair) ([]CandlePrice, err
 	return candleList, nil
 }
 
-func (p *OkxProvider) handleReceivedTickers(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(defaultReadNewWSMessage):
-			messageType, bz, err := p.wsClient.ReadMessage()
-			if err != nil {
-				// if some error occurs continue to try to read the next message.
-				p.logger.Err(err).Msg("could not read message")
-				if err := p.ping(); err != nil {
-					p.logger.Err(err).Msg("could not send ping")
-				}
-				continue
-			}
-
-			if len(bz) == 0 {
-				continue
-			}
-
-			p.resetReconnectTimer()
-			p.messageReceived(messageType, bz)
-
-		case <-p.reconnectTimer.C: // reset by the pongHandler.
-			if err := p.reconnect(); err != nil {
-				p.logger.Err(err).Msg("error reconnecting")
-			}
-		}
-	}
-}
-
 func (p *OkxProvider) messageReceived(messageType int, bz []byte) {
 	if messageType != websocket.TextMessage {
 		return
 	}
 
+	var eventResp OkxEventResponse
+	if err := json.Unmarshal(bz, &eventResp); err == nil && len(eventResp.Event) > 0 {
+		p.messageReceivedEvent(eventResp)
+		return
+	}
+
 	var (
-		tickerResp OkxTickerResponse
-		tickerErr  error
-		candleResp OkxCandleResponse
-		candleErr  error
+		tickerResp    OkxTickerResponse
+		tickerErr     error
+		candleResp    OkxCandleResponse
+		candleErr     error
+		orderBookResp OkxOrderBookResponse
+		orderBookErr  error
 	)
 
 	// sometimes the message received is not a ticker or a candle response.
@@ -366,23 +398,70 @@ func (p *OkxProvider) messageReceived(messageType int, bz []byte) {
 		return
 	}
 
+	orderBookErr = json.Unmarshal(bz, &orderBookResp)
+	if orderBookResp.ID.Channel == "books5" {
+		for _, orderBook := range orderBookResp.Data {
+			p.setOrderBookPair(orderBook, orderBookResp.ID.InstID)
+			telemetry.IncrCounter(
+				1,
+				"websocket",
+				"message",
+				"type",
+				"order_book",
+				"provider",
+				config.ProviderOkx,
+			)
+		}
+		return
+	}
+
 	p.logger.Error().
 		Int("length", len(bz)).
 		AnErr("ticker", tickerErr).
 		AnErr("candle", candleErr).
+		AnErr("order_book", orderBookErr).
 		Msg("Error on receive message")
 }
 
+// messageReceivedEvent tracks the subscription state of the pairs from the
+// acknowledgements and errors of their ticker subscription.
+func (p *OkxProvider) messageReceivedEvent(event OkxEventResponse) {
+	switch event.Event {
+	case "subscribe":
+		if event.ID.Channel == "tickers" {
+			p.setSubscriptionActive(okxPairToCurrencyPairSymbol(event.ID.InstID))
+		}
+
+	case "error":
+		p.logger.Error().Str("code", event.Code).Msg(event.Msg)
+		if match := okxErrorInstIDRegex.FindStringSubmatch(event.Msg); match != nil {
+			p.setSubscriptionFailed(okxPairToCurrencyPairSymbol(match[1]), event.Msg)
+		}
+	}
+}
+
 func (p *OkxProvider) setTickerPair(tickerPair OkxTickerPair) {
+	p.setSubscriptionActive(okxPairToCurrencyPairSymbol(tickerPair.InstID))
+
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 	p.tickers[tickerPair.InstID] = tickerPair
 }
 
-// subscribePairs write the subscription msg to the provider.
-func (p *OkxProvider) subscribePairs(pairs ...OkxSubscriptionTopic) error {
-	subsMsg := newOkxSubscriptionMsg(pairs...)
-	return p.wsClient.WriteJSON(subsMsg)
+func (p *OkxProvider) setOrderBookPair(orderBook OkxOrderBook, instID string) {
+	bids, err := newOrderBookLevels("Okx", orderBook.Bids)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("failed to parse order book")
+		return
+	}
+
+	asks, err := newOrderBookLevels("Okx", orderBook.Asks)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("failed to parse order book")
+		return
+	}
+
+	p.setOrderBook(instID, NewOrderBook(bids, asks, time.Now().UnixMilli()))
 }
 
 func (p *OkxProvider) setCandlePair(pairData []string, instID string) {
@@ -422,61 +501,14 @@ func (p *OkxProvider) setSubscribedPairs(cps ...types.CurrencyPair) {
 	}
 }
 
-func (p *OkxProvider) resetReconnectTimer() {
-	p.reconnectTimer.Reset(okxPingCheck)
-}
-
-// reconnect closes the last WS connection and creates a new one. If there’s a
-// network problem, the system will automatically disable the connection. The
-// connection will break automatically if the subscription is not established or
-// data has not been pushed for more than 30 seconds. To keep the connection stable:
-// 1. Set a timer of N seconds whenever a response message is received, where N is
-// less than 30.
-// 2. If the timer is triggered, which means that no new message is received within
-// N seconds, send the String 'ping'.
-// 3. Expect a 'pong' as a response. If the response message is not received within
-// N seconds, please raise an error or reconnect.
-func (p *OkxProvider) reconnect() error {
-	p.wsClient.Close()
-
-	p.logger.Debug().Msg("reconnecting websocket")
-	wsConn, response, err := websocket.DefaultDialer.Dial(p.wsURL.String(), nil)
-	defer func() {
-		if response != nil {
-			response.Body.Close()
-		}
-	}()
-	if err != nil {
-		return fmt.Errorf("error reconnecting to Okx websocket: %w", err)
-	}
-	wsConn.SetPongHandler(p.pongHandler)
-	p.wsClient = wsConn
-
-	currencyPairs := p.subscribedPairsToSlice()
-
-	telemetry.IncrCounter(
-		1,
-		"websocket",
-		"reconnect",
-		"provider",
-		config.ProviderOkx,
-	)
-	return p.subscribeChannels(currencyPairs...)
-}
-
-// ping to check websocket connection.
-func (p *OkxProvider) ping() error {
-	return p.wsClient.WriteMessage(websocket.PingMessage, ping)
-}
-
-func (p *OkxProvider) pongHandler(_ string) error {
-	p.resetReconnectTimer()
-	return nil
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *OkxProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
 }
 
 // GetAvailablePairs return all available pairs symbol to susbscribe.
 func (p *OkxProvider) GetAvailablePairs() (map[string]struct{}, error) {
-	resp, err := http.Get(p.endpoints.Rest + okxRestPath)
+	resp, err := p.endpoints.HTTPGet(okxRestPath)
 	if err != nil {
 		return nil, err
 	}
@@ -521,6 +553,12 @@ func currencyPairToOkxPair(pair types.CurrencyPair) string {
 	return pair.Base + "-" + pair.Quote
 }
 
+// okxPairToCurrencyPairSymbol returns the currency pair symbol of the
+// instrument ID ex.: "BTCUSDT".
+func okxPairToCurrencyPairSymbol(instID string) string {
+	return strings.ReplaceAll(instID, "-", "")
+}
+
 // newOkxTickerSubscriptionTopic returns a new subscription topic.
 func newOkxTickerSubscriptionTopic(instID string) OkxSubscriptionTopic {
 	return OkxSubscriptionTopic{
@@ -529,6 +567,14 @@ func newOkxTickerSubscriptionTopic(instID string) OkxSubscriptionTopic {
 	}
 }
 
+// newOkxOrderBookSubscriptionTopic returns a new order book subscription topic.
+func newOkxOrderBookSubscriptionTopic(instID string) OkxSubscriptionTopic {
+	return OkxSubscriptionTopic{
+		Channel: "books5",
+		InstID:  instID,
+	}
+}
+
 // CONTEXT: commented out because okx candles are unused
 // // newOkxSubscriptionTopic returns a new subscription topic.
 // func newOkxCandleSubscriptionTopic(instID string) OkxSubscriptionTopic {
@@ -0,0 +1,253 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+const (
+	okxIndexChannel  = "index-tickers"
+	okxIndexRestPath = "/api/v5/market/index-tickers?quoteCcy="
+)
+
+var (
+	_ Provider             = (*OkxIndexProvider)(nil)
+	_ EndpointProvider     = (*OkxIndexProvider)(nil)
+	_ SubscriptionProvider = (*OkxIndexProvider)(nil)
+
+	// okxIndexQuotes are the quotes of the indices published by Okx.
+	okxIndexQuotes = []string{"USD", "USDT", "USDC"}
+)
+
+type (
+	// OkxIndexProvider defines an Oracle provider reporting the index prices
+	// published by Okx, weighted by a synthetic volume.
+	//
+	// REF: https://www.okx.com/docs-v5/en/#public-data-websocket-index-tickers-channel
+	OkxIndexProvider struct {
+		wsShards  *WebsocketShards
+		logger    zerolog.Logger
+		endpoints *EndpointPool
+		*indexPriceTracker
+		*subscriptionTracker
+	}
+
+	// OkxIndexTicker defines an index ticker of Okx.
+	OkxIndexTicker struct {
+		OkxInstID
+		IdxPx     string `json:"idxPx"` // Latest index price ex.: 43508.9
+		TimeStamp string `json:"ts"`    // Update time in unix milliseconds ex.: 1597026383085
+	}
+
+	// OkxIndexTickerResponse defines the response structure of a Okx
+	// index-tickers request.
+	OkxIndexTickerResponse struct {
+		Data []OkxIndexTicker `json:"data"`
+		ID   OkxID            `json:"arg"`
+	}
+)
+
+// NewOkxIndexProvider creates a new OkxIndexProvider.
+func NewOkxIndexProvider(
+	ctx context.Context,
+	logger zerolog.Logger,
+	endpoints config.ProviderEndpoint,
+	pairs ...types.CurrencyPair,
+) (*OkxIndexProvider, error) {
+	endpoints = withDefaultEndpoint(endpoints, config.ProviderEndpoint{
+		Name:      config.ProviderOkxIndex,
+		Rest:      okxRestHost,
+		Websocket: okxWSHost,
+		Fallbacks: okxFallbacks,
+	})
+
+	wsURL := url.URL{
+		Scheme: "wss",
+		Host:   endpoints.Websocket,
+		Path:   okxWSPath,
+	}
+
+	endpointPool, err := NewEndpointPool(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	indexPriceTracker, err := newIndexPriceTracker(endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &OkxIndexProvider{
+		logger:            logger.With().Str("provider", config.ProviderOkxIndex).Logger(),
+		endpoints:         endpointPool,
+		indexPriceTracker: indexPriceTracker,
+
+		subscriptionTracker: newSubscriptionTracker(config.ProviderOkxIndex, endpointPool.SilenceTimeout()),
+	}
+
+	// same as the okx provider, the string 'ping' keeps the connection alive
+	provider.wsShards = NewWebsocketShards(
+		ctx,
+		config.ProviderOkxIndex,
+		wsURL,
+		provider.endpoints,
+		endpoints.Connections,
+		provider.getSubscriptionMsgs,
+		provider.messageReceived,
+		okxPingCheck,
+		websocket.TextMessage,
+		provider.logger,
+	)
+
+	if err := provider.SubscribeCurrencyPairs(pairs...); err != nil {
+		return nil, err
+	}
+
+	go provider.retrySubscriptions(ctx, provider.logger, provider.wsShards.ResubscribeCurrencyPairs)
+
+	return provider, nil
+}
+
+// SubscribeCurrencyPairs subscribe all currency pairs into the index tickers
+// channel.
+func (p *OkxIndexProvider) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
+	if len(cps) == 0 {
+		return fmt.Errorf("currency pairs is empty")
+	}
+
+	p.trackSubscriptions(cps...)
+	return p.wsShards.SubscribeCurrencyPairs(cps...)
+}
+
+// getSubscriptionMsgs returns the message subscribing to the index tickers
+// channel of the currency pairs.
+func (p *OkxIndexProvider) getSubscriptionMsgs(cps ...types.CurrencyPair) []interface{} {
+	topics := make([]OkxSubscriptionTopic, len(cps))
+	for i, cp := range cps {
+		topics[i] = OkxSubscriptionTopic{
+			Channel: okxIndexChannel,
+			InstID:  currencyPairToOkxPair(cp),
+		}
+	}
+
+	return []interface{}{newOkxSubscriptionMsg(topics...)}
+}
+
+func (p *OkxIndexProvider) messageReceived(messageType int, bz []byte) {
+	if messageType != websocket.TextMessage {
+		return
+	}
+
+	var eventResp OkxEventResponse
+	if err := json.Unmarshal(bz, &eventResp); err == nil && len(eventResp.Event) > 0 {
+		p.messageReceivedEvent(eventResp)
+		return
+	}
+
+	var tickerResp OkxIndexTickerResponse
+	tickerErr := json.Unmarshal(bz, &tickerResp)
+	if tickerResp.ID.Channel == okxIndexChannel {
+		for _, ticker := range tickerResp.Data {
+			p.setIndexTicker(ticker)
+		}
+		return
+	}
+
+	p.logger.Error().
+		Int("length", len(bz)).
+		AnErr("ticker", tickerErr).
+		Msg("Error on receive message")
+}
+
+// messageReceivedEvent tracks the subscription state of the pairs from the
+// acknowledgements and errors of their index subscription.
+func (p *OkxIndexProvider) messageReceivedEvent(event OkxEventResponse) {
+	switch event.Event {
+	case "subscribe":
+		p.setSubscriptionActive(okxPairToCurrencyPairSymbol(event.ID.InstID))
+
+	case "error":
+		p.logger.Error().Str("code", event.Code).Msg(event.Msg)
+		if match := okxErrorInstIDRegex.FindStringSubmatch(event.Msg); match != nil {
+			p.setSubscriptionFailed(okxPairToCurrencyPairSymbol(match[1]), event.Msg)
+		}
+	}
+}
+
+func (p *OkxIndexProvider) setIndexTicker(ticker OkxIndexTicker) {
+	symbol := okxPairToCurrencyPairSymbol(ticker.InstID)
+
+	ts, err := strconv.ParseInt(ticker.TimeStamp, 10, 64)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("failed to parse index timestamp")
+		return
+	}
+
+	if err := p.setIndexPrice(symbol, ticker.IdxPx, ts); err != nil {
+		p.logger.Warn().Err(err).Msg("failed to parse index price")
+		return
+	}
+
+	p.setSubscriptionActive(symbol)
+	telemetry.IncrCounter(
+		1,
+		"websocket",
+		"message",
+		"type",
+		"ticker",
+		"provider",
+		config.ProviderOkxIndex,
+	)
+}
+
+// ActiveEndpoint returns the endpoint currently used by the provider.
+func (p *OkxIndexProvider) ActiveEndpoint() config.ProviderEndpoint {
+	return p.endpoints.Active()
+}
+
+// GetAvailablePairs return all the indices published by Okx.
+func (p *OkxIndexProvider) GetAvailablePairs() (map[string]struct{}, error) {
+	availablePairs := map[string]struct{}{}
+	for _, quote := range okxIndexQuotes {
+		if err := p.getAvailablePairs(quote, availablePairs); err != nil {
+			return nil, err
+		}
+	}
+
+	return availablePairs, nil
+}
+
+// getAvailablePairs adds the indices of the quote to the available pairs.
+func (p *OkxIndexProvider) getAvailablePairs(quote string, availablePairs map[string]struct{}) error {
+	resp, err := p.endpoints.HTTPGet(okxIndexRestPath + quote)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var pairsSummary OkxPairsSummary
+	if err := json.NewDecoder(resp.Body).Decode(&pairsSummary); err != nil {
+		return err
+	}
+
+	for _, pair := range pairsSummary.Data {
+		if len(strings.Split(pair.InstID, "-")) != 2 {
+			continue
+		}
+		availablePairs[strings.ToUpper(okxPairToCurrencyPairSymbol(pair.InstID))] = struct{}{}
+	}
+
+	return nil
+}
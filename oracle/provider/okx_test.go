@@ -2,8 +2,11 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
@@ -101,8 +104,74 @@ func TestOkxProvider_SubscribeCurrencyPairs(t *testing.T) {
 	})
 }
 
+func TestOkxProvider_SubscriptionStatuses(t *testing.T) {
+	p, err := NewOkxProvider(
+		context.TODO(),
+		zerolog.Nop(),
+		config.ProviderEndpoint{},
+		types.CurrencyPair{Base: "ATOM", Quote: "USDT"},
+		types.CurrencyPair{Base: "FOO", Quote: "USDT"},
+	)
+	require.NoError(t, err)
+
+	p.messageReceived(websocket.TextMessage, []byte(
+		`{"event":"subscribe","arg":{"channel":"tickers","instId":"ATOM-USDT"}}`,
+	))
+	p.messageReceived(websocket.TextMessage, []byte(
+		`{"event":"error","code":"60018","msg":"Wrong URL or channel:tickers,instId:FOO-USDT doesn't exist."}`,
+	))
+
+	statuses := p.SubscriptionStatuses()
+	require.Equal(t, SubscriptionActive, statuses["ATOMUSDT"].State)
+	require.Equal(t, SubscriptionFailed, statuses["FOOUSDT"].State)
+	require.Equal(t, "Wrong URL or channel:tickers,instId:FOO-USDT doesn't exist.", statuses["FOOUSDT"].Reason)
+}
+
 func TestOkxCurrencyPairToOkxPair(t *testing.T) {
 	cp := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
 	okxSymbol := currencyPairToOkxPair(cp)
 	require.Equal(t, okxSymbol, "ATOM-USDT")
 }
+
+func TestOkxIndexProvider(t *testing.T) {
+	btcUSDT := types.CurrencyPair{Base: "BTC", Quote: "USDT"}
+	p, err := NewOkxIndexProvider(
+		context.TODO(),
+		zerolog.Nop(),
+		config.ProviderEndpoint{Name: config.ProviderOkxIndex, SyntheticVolume: "500"},
+		btcUSDT,
+		types.CurrencyPair{Base: "FOO", Quote: "USDT"},
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, []interface{}{OkxSubscriptionMsg{
+		Op:   "subscribe",
+		Args: []OkxSubscriptionTopic{{Channel: "index-tickers", InstID: "BTC-USDT"}},
+	}}, p.getSubscriptionMsgs(btcUSDT))
+
+	ts := time.Now().UnixMilli()
+	p.messageReceived(websocket.TextMessage, []byte(
+		`{"event":"error","code":"60018","msg":"Wrong URL or channel:index-tickers,instId:FOO-USDT doesn't exist."}`,
+	))
+	p.messageReceived(websocket.TextMessage, []byte(fmt.Sprintf(
+		`{"arg":{"channel":"index-tickers","instId":"BTC-USDT"},"data":[{"instId":"BTC-USDT","idxPx":"43508.9","ts":"%d"}]}`,
+		ts,
+	)))
+
+	prices, err := p.GetTickerPrices(btcUSDT)
+	require.NoError(t, err)
+	require.Equal(t, math.LegacyMustNewDecFromStr("43508.9"), prices["BTCUSDT"].Price)
+	require.Equal(t, math.LegacyMustNewDecFromStr("500"), prices["BTCUSDT"].Volume)
+
+	candles, err := p.GetCandlePrices(btcUSDT)
+	require.NoError(t, err)
+	require.Equal(t, []CandlePrice{{
+		Price:     math.LegacyMustNewDecFromStr("43508.9"),
+		Volume:    math.LegacyMustNewDecFromStr("50"),
+		TimeStamp: ts,
+	}}, candles["BTCUSDT"])
+
+	statuses := p.SubscriptionStatuses()
+	require.Equal(t, SubscriptionActive, statuses["BTCUSDT"].State)
+	require.Equal(t, SubscriptionFailed, statuses["FOOUSDT"].State)
+}
@@ -0,0 +1,287 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+const (
+	// orderBookStaleTime is the maximum age of an order book snapshot before
+	// it is no longer used to price a pair.
+	orderBookStaleTime = time.Minute
+)
+
+// basisPoints is the amount of basis points in a unit.
+var basisPoints = math.LegacyNewDec(10000)
+
+type (
+	// OrderBookPricing defines how the ticker price of a pair is derived from
+	// its order book.
+	OrderBookPricing struct {
+		Source   string // config.PriceSourceMid or config.PriceSourceDepth
+		DepthBps uint32 // max distance from the mid-price of the levels used by config.PriceSourceDepth
+	}
+
+	// OrderBookLevel defines a single price level of an order book.
+	OrderBookLevel struct {
+		Price math.LegacyDec
+		Size  math.LegacyDec
+	}
+
+	// OrderBook defines a L2 order book snapshot. Bids are sorted from the
+	// highest to the lowest price and asks from the lowest to the highest.
+	OrderBook struct {
+		Bids      []OrderBookLevel
+		Asks      []OrderBookLevel
+		TimeStamp int64 // time the snapshot was received in unix milliseconds
+	}
+
+	// orderBookTracker keeps the latest order book of the pairs priced from
+	// their order book. It is embedded by the providers implementing
+	// OrderBookProvider.
+	orderBookTracker struct {
+		bookMtx     sync.RWMutex
+		provider    string
+		pricing     map[string]OrderBookPricing // Symbol => OrderBookPricing
+		books       map[string]OrderBook        // Symbol => OrderBook
+		fallingBack map[string]struct{}         // Symbols priced by their last trade price
+	}
+)
+
+// NewOrderBook returns an order book with its levels sorted, ignoring any
+// level without a positive price and size.
+func NewOrderBook(bids, asks []OrderBookLevel, timeStamp int64) OrderBook {
+	book := OrderBook{TimeStamp: timeStamp}
+
+	for _, level := range bids {
+		if level.Price.IsPositive() && level.Size.IsPositive() {
+			book.Bids = append(book.Bids, level)
+		}
+	}
+	for _, level := range asks {
+		if level.Price.IsPositive() && level.Size.IsPositive() {
+			book.Asks = append(book.Asks, level)
+		}
+	}
+
+	sort.SliceStable(book.Bids, func(i, j int) bool {
+		return book.Bids[i].Price.GT(book.Bids[j].Price)
+	})
+	sort.SliceStable(book.Asks, func(i, j int) bool {
+		return book.Asks[i].Price.LT(book.Asks[j].Price)
+	})
+
+	return book
+}
+
+// newOrderBookLevels parses the [price, size] string pairs sent by most
+// exchanges into order book levels.
+func newOrderBookLevels(provider string, levels [][]string) ([]OrderBookLevel, error) {
+	orderBookLevels := make([]OrderBookLevel, 0, len(levels))
+	for _, level := range levels {
+		if len(level) < 2 {
+			return nil, fmt.Errorf("invalid %s order book level %v", provider, level)
+		}
+
+		price, err := math.LegacyNewDecFromStr(level[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s order book price (%s)", provider, level[0])
+		}
+
+		size, err := math.LegacyNewDecFromStr(level[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s order book size (%s)", provider, level[1])
+		}
+
+		orderBookLevels = append(orderBookLevels, OrderBookLevel{Price: price, Size: size})
+	}
+
+	return orderBookLevels, nil
+}
+
+// MidPrice returns the average between the best bid and the best ask.
+func (ob OrderBook) MidPrice() (math.LegacyDec, error) {
+	if len(ob.Bids) == 0 || len(ob.Asks) == 0 {
+		return math.LegacyDec{}, fmt.Errorf("order book has an empty side")
+	}
+
+	bestBid, bestAsk := ob.Bids[0].Price, ob.Asks[0].Price
+	if bestBid.GT(bestAsk) {
+		return math.LegacyDec{}, fmt.Errorf("order book is crossed: bid %s > ask %s", bestBid, bestAsk)
+	}
+
+	return bestBid.Add(bestAsk).QuoInt64(2), nil
+}
+
+// DepthWeightedPrice returns the size weighted average price of every level,
+// on both sides of the book, within bps basis points of the mid-price.
+func (ob OrderBook) DepthWeightedPrice(bps uint32) (math.LegacyDec, error) {
+	mid, err := ob.MidPrice()
+	if err != nil {
+		return math.LegacyDec{}, err
+	}
+
+	margin := mid.Mul(math.LegacyNewDec(int64(bps))).Quo(basisPoints)
+	lower, upper := mid.Sub(margin), mid.Add(margin)
+
+	weightedPrices := math.LegacyZeroDec()
+	sizeSum := math.LegacyZeroDec()
+
+	for _, bid := range ob.Bids {
+		if bid.Price.LT(lower) {
+			break
+		}
+		weightedPrices = weightedPrices.Add(bid.Price.Mul(bid.Size))
+		sizeSum = sizeSum.Add(bid.Size)
+	}
+	for _, ask := range ob.Asks {
+		if ask.Price.GT(upper) {
+			break
+		}
+		weightedPrices = weightedPrices.Add(ask.Price.Mul(ask.Size))
+		sizeSum = sizeSum.Add(ask.Size)
+	}
+
+	if sizeSum.IsZero() {
+		return math.LegacyDec{}, fmt.Errorf("order book has no depth within %d bps of the mid-price", bps)
+	}
+
+	return weightedPrices.Quo(sizeSum), nil
+}
+
+// Price returns the price of the order book for the given pricing.
+func (ob OrderBook) Price(pricing OrderBookPricing) (math.LegacyDec, error) {
+	switch pricing.Source {
+	case config.PriceSourceMid:
+		return ob.MidPrice()
+	case config.PriceSourceDepth:
+		return ob.DepthWeightedPrice(pricing.DepthBps)
+	}
+
+	return math.LegacyDec{}, fmt.Errorf("unsupported order book price source %s", pricing.Source)
+}
+
+func newOrderBookTracker(providerName string) *orderBookTracker {
+	return &orderBookTracker{
+		provider:    providerName,
+		pricing:     map[string]OrderBookPricing{},
+		books:       map[string]OrderBook{},
+		fallingBack: map[string]struct{}{},
+	}
+}
+
+// setPricing sets the order book pricing of the pair symbol.
+func (t *orderBookTracker) setPricing(symbol string, pricing OrderBookPricing) {
+	t.bookMtx.Lock()
+	defer t.bookMtx.Unlock()
+
+	t.pricing[symbol] = pricing
+}
+
+// pricedSymbols returns the symbols priced from their order book.
+func (t *orderBookTracker) pricedSymbols() map[string]struct{} {
+	t.bookMtx.RLock()
+	defer t.bookMtx.RUnlock()
+
+	symbols := make(map[string]struct{}, len(t.pricing))
+	for symbol := range t.pricing {
+		symbols[symbol] = struct{}{}
+	}
+
+	return symbols
+}
+
+// setOrderBook stores the latest order book of the pair symbol.
+func (t *orderBookTracker) setOrderBook(symbol string, book OrderBook) {
+	t.bookMtx.Lock()
+	defer t.bookMtx.Unlock()
+
+	t.books[symbol] = book
+}
+
+// applyOrderBookPricing replaces the price of the ticker by the price of the
+// order book if the pair symbol is priced from its order book. The 24h volume
+// of the ticker is kept so the weight of the provider stays the same. If the
+// order book is missing, stale or can not be priced, the last trade price of
+// the ticker is kept. Every fallback is counted, but only the start and the
+// end of a fallback are logged.
+func (t *orderBookTracker) applyOrderBookPricing(logger zerolog.Logger, symbol string, ticker TickerPrice) TickerPrice {
+	price, err := t.orderBookPrice(symbol)
+	if err != nil {
+		telemetry.IncrCounter(
+			1,
+			"order_book",
+			"fallback",
+			"provider",
+			t.provider,
+		)
+		if t.setFallingBack(symbol, true) {
+			logger.Warn().Err(err).Str("symbol", symbol).Msg("falling back to the last trade price")
+		}
+		return ticker
+	}
+	if price == nil {
+		return ticker
+	}
+
+	if t.setFallingBack(symbol, false) {
+		logger.Info().Str("symbol", symbol).Msg("order book pricing recovered")
+	}
+	return TickerPrice{Price: *price, Volume: ticker.Volume}
+}
+
+// setFallingBack sets whether the pair symbol is priced by its last trade
+// price, returning true if it changed.
+func (t *orderBookTracker) setFallingBack(symbol string, fallingBack bool) bool {
+	t.bookMtx.Lock()
+	defer t.bookMtx.Unlock()
+
+	_, ok := t.fallingBack[symbol]
+	if ok == fallingBack {
+		return false
+	}
+
+	if fallingBack {
+		t.fallingBack[symbol] = struct{}{}
+	} else {
+		delete(t.fallingBack, symbol)
+	}
+	return true
+}
+
+// orderBookPrice returns the price of the order book of the pair symbol, nil
+// if the pair is not priced from its order book.
+func (t *orderBookTracker) orderBookPrice(symbol string) (*math.LegacyDec, error) {
+	t.bookMtx.RLock()
+	defer t.bookMtx.RUnlock()
+
+	pricing, ok := t.pricing[symbol]
+	if !ok {
+		return nil, nil
+	}
+
+	book, ok := t.books[symbol]
+	if !ok {
+		return nil, fmt.Errorf("no order book received for %s", symbol)
+	}
+	if book.TimeStamp < PastUnixTime(orderBookStaleTime) {
+		return nil, fmt.Errorf("order book for %s is stale", symbol)
+	}
+
+	price, err := book.Price(pricing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to price %s from its order book: %w", symbol, err)
+	}
+
+	return &price, nil
+}
@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+func newTestOrderBookLevel(price, size string) OrderBookLevel {
+	return OrderBookLevel{
+		Price: math.LegacyMustNewDecFromStr(price),
+		Size:  math.LegacyMustNewDecFromStr(size),
+	}
+}
+
+func TestNewOrderBook(t *testing.T) {
+	book := NewOrderBook(
+		[]OrderBookLevel{
+			newTestOrderBookLevel("99", "1"),
+			newTestOrderBookLevel("99.5", "2"),
+			newTestOrderBookLevel("98", "0"),
+		},
+		[]OrderBookLevel{
+			newTestOrderBookLevel("101", "1"),
+			newTestOrderBookLevel("100.5", "2"),
+		},
+		0,
+	)
+
+	require.Len(t, book.Bids, 2)
+	require.Equal(t, math.LegacyMustNewDecFromStr("99.5"), book.Bids[0].Price)
+	require.Len(t, book.Asks, 2)
+	require.Equal(t, math.LegacyMustNewDecFromStr("100.5"), book.Asks[0].Price)
+}
+
+func TestOrderBook_Price(t *testing.T) {
+	book := NewOrderBook(
+		[]OrderBookLevel{
+			newTestOrderBookLevel("99.9", "2"),
+			newTestOrderBookLevel("99.8", "4"),
+			newTestOrderBookLevel("95", "100"),
+		},
+		[]OrderBookLevel{
+			newTestOrderBookLevel("100.1", "1"),
+			newTestOrderBookLevel("100.2", "1"),
+			newTestOrderBookLevel("105", "100"),
+		},
+		0,
+	)
+
+	testCases := []struct {
+		name     string
+		book     OrderBook
+		pricing  OrderBookPricing
+		expected math.LegacyDec
+		err      string
+	}{
+		{
+			name:     "mid",
+			book:     book,
+			pricing:  OrderBookPricing{Source: config.PriceSourceMid},
+			expected: math.LegacyMustNewDecFromStr("100"),
+		},
+		{
+			name:    "depth_within_bps",
+			book:    book,
+			pricing: OrderBookPricing{Source: config.PriceSourceDepth, DepthBps: 50},
+			// (99.9*2 + 99.8*4 + 100.1*1 + 100.2*1) / 8
+			expected: math.LegacyMustNewDecFromStr("99.9125"),
+		},
+		{
+			name:    "depth_best_levels_only",
+			book:    book,
+			pricing: OrderBookPricing{Source: config.PriceSourceDepth, DepthBps: 10},
+			// (99.9*2 + 100.1*1) / 3
+			expected: math.LegacyMustNewDecFromStr("99.966666666666666667"),
+		},
+		{
+			name:    "depth_no_levels_within_bps",
+			book:    book,
+			pricing: OrderBookPricing{Source: config.PriceSourceDepth, DepthBps: 0},
+			err:     "no depth within 0 bps",
+		},
+		{
+			name:    "empty_side",
+			book:    NewOrderBook(book.Bids, nil, 0),
+			pricing: OrderBookPricing{Source: config.PriceSourceMid},
+			err:     "empty side",
+		},
+		{
+			name: "crossed_book",
+			book: NewOrderBook(
+				[]OrderBookLevel{newTestOrderBookLevel("101", "1")},
+				[]OrderBookLevel{newTestOrderBookLevel("100", "1")},
+				0,
+			),
+			pricing: OrderBookPricing{Source: config.PriceSourceMid},
+			err:     "crossed",
+		},
+		{
+			name:    "unsupported_source",
+			book:    book,
+			pricing: OrderBookPricing{Source: config.PriceSourceLast},
+			err:     "unsupported order book price source",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			price, err := tc.book.Price(tc.pricing)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, price)
+		})
+	}
+}
+
+func TestOrderBookTracker_ApplyOrderBookPricing(t *testing.T) {
+	const symbol = "TRX-USDT"
+	ticker := TickerPrice{
+		Price:  math.LegacyMustNewDecFromStr("0.25"),
+		Volume: math.LegacyMustNewDecFromStr("1000"),
+	}
+	bids := []OrderBookLevel{newTestOrderBookLevel("0.2400", "10")}
+	asks := []OrderBookLevel{newTestOrderBookLevel("0.2402", "10")}
+
+	tracker := newOrderBookTracker(config.ProviderOkx)
+
+	// pairs without order book pricing keep the last trade price
+	require.Equal(t, ticker, tracker.applyOrderBookPricing(zerolog.Nop(), symbol, ticker))
+
+	// pairs without a usable order book fall back to the last trade price
+	tracker.setPricing(symbol, OrderBookPricing{Source: config.PriceSourceMid})
+	_, err := tracker.orderBookPrice(symbol)
+	require.ErrorContains(t, err, "no order book received")
+	require.Equal(t, ticker, tracker.applyOrderBookPricing(zerolog.Nop(), symbol, ticker))
+
+	tracker.setOrderBook(symbol, NewOrderBook(bids, asks, PastUnixTime(2*orderBookStaleTime)))
+	_, err = tracker.orderBookPrice(symbol)
+	require.ErrorContains(t, err, "stale")
+	require.Equal(t, ticker, tracker.applyOrderBookPricing(zerolog.Nop(), symbol, ticker))
+
+	tracker.setOrderBook(symbol, NewOrderBook(asks, bids, time.Now().UnixMilli()))
+	_, err = tracker.orderBookPrice(symbol)
+	require.ErrorContains(t, err, "crossed")
+	require.Equal(t, ticker, tracker.applyOrderBookPricing(zerolog.Nop(), symbol, ticker))
+
+	tracker.setOrderBook(symbol, NewOrderBook(bids, asks, time.Now().UnixMilli()))
+	price := tracker.applyOrderBookPricing(zerolog.Nop(), symbol, ticker)
+	require.Equal(t, math.LegacyMustNewDecFromStr("0.2401"), price.Price)
+	require.Equal(t, ticker.Volume, price.Volume)
+}
+
+func TestOrderBookTracker_FallbackLogs(t *testing.T) {
+	symbol := "TRX-USDT"
+	ticker := TickerPrice{Price: math.LegacyMustNewDecFromStr("0.25"), Volume: math.LegacyNewDec(1000)}
+
+	var logs bytes.Buffer
+	logger := zerolog.New(&logs)
+
+	tracker := newOrderBookTracker(config.ProviderOkx)
+	tracker.setPricing(symbol, OrderBookPricing{Source: config.PriceSourceMid})
+
+	// only the start of the fallback is logged
+	for i := 0; i < 3; i++ {
+		tracker.applyOrderBookPricing(logger, symbol, ticker)
+	}
+	require.Equal(t, 1, strings.Count(logs.String(), "falling back to the last trade price"))
+
+	// as is its end
+	tracker.setOrderBook(symbol, NewOrderBook(
+		[]OrderBookLevel{newTestOrderBookLevel("0.24", "10")},
+		[]OrderBookLevel{newTestOrderBookLevel("0.2402", "10")},
+		time.Now().UnixMilli(),
+	))
+	for i := 0; i < 3; i++ {
+		tracker.applyOrderBookPricing(logger, symbol, ticker)
+	}
+	require.Equal(t, 1, strings.Count(logs.String(), "order book pricing recovered"))
+}
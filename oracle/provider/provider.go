@@ -8,6 +8,7 @@ import (
 
 	"cosmossdk.io/math"
 
+	"github.com/kiichain/price-feeder/config"
 	"github.com/kiichain/price-feeder/oracle/types"
 )
 
@@ -35,6 +36,30 @@ type Provider interface {
 	SubscribeCurrencyPairs(...types.CurrencyPair) error
 }
 
+// OrderBookProvider defines an interface a provider must implement to report
+// the ticker price of a pair from its L2 order book instead of its last trade.
+type OrderBookProvider interface {
+	Provider
+
+	// SetOrderBookPricing subscribes to the order book of the pair and reports
+	// its ticker price using the given pricing.
+	SetOrderBookPricing(types.CurrencyPair, OrderBookPricing) error
+}
+
+// EndpointProvider defines an interface a provider must implement to report
+// the endpoint it currently uses out of its configured endpoints.
+type EndpointProvider interface {
+	// ActiveEndpoint returns the endpoint currently used by the provider.
+	ActiveEndpoint() config.ProviderEndpoint
+}
+
+// SubscriptionProvider defines an interface a provider must implement to report
+// the subscription state of its pairs.
+type SubscriptionProvider interface {
+	// SubscriptionStatuses returns the subscription status of every pair.
+	SubscriptionStatuses() map[string]SubscriptionStatus
+}
+
 // TickerPrice defines price and volume information for a symbol or ticker
 // exchange rate.
 type TickerPrice struct {
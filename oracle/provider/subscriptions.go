@@ -0,0 +1,288 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-metrics"
+	"github.com/rs/zerolog"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+const (
+	// subscriptionAckTimeout is the time after which a pending subscription
+	// neither acknowledged nor producing data is considered failed.
+	subscriptionAckTimeout = time.Minute
+	// subscriptionRetryInterval is the interval at which failed and silent
+	// subscriptions are retried.
+	subscriptionRetryInterval = time.Minute
+	// maxSubscriptionRetries is the amount of times a failed subscription is
+	// retried before giving up on the pair.
+	maxSubscriptionRetries = 5
+
+	SubscriptionPending SubscriptionState = "pending"
+	SubscriptionActive  SubscriptionState = "active"
+	SubscriptionFailed  SubscriptionState = "failed"
+)
+
+var subscriptionStates = []SubscriptionState{SubscriptionPending, SubscriptionActive, SubscriptionFailed}
+
+type (
+	// SubscriptionState defines the state of the subscription of a pair.
+	SubscriptionState string
+
+	// SubscriptionStatus defines the subscription state of a pair and the
+	// reason it failed, if so.
+	SubscriptionStatus struct {
+		State     SubscriptionState
+		Reason    string    // reason of the failure ex.: "Currency pair not supported"
+		Retries   int       // retries since the pair was last active
+		UpdatedAt time.Time // last state change
+	}
+
+	// subscriptionTracker tracks the subscription state of the pairs of a
+	// provider. A pair is pending once its subscription is sent, active once
+	// the exchange acknowledges it or sends its data, and failed once the
+	// exchange rejects it or does not answer within subscriptionAckTimeout.
+	// An active pair without messages for silenceTimeout is resubscribed.
+	subscriptionTracker struct {
+		subMtx         sync.RWMutex
+		provider       string
+		silenceTimeout time.Duration
+		pairs          map[string]types.CurrencyPair // Symbol => types.CurrencyPair
+		statuses       map[string]SubscriptionStatus // Symbol => SubscriptionStatus
+		lastMessages   map[string]time.Time          // Symbol => time of the last message
+	}
+)
+
+func newSubscriptionTracker(provider string, silenceTimeout time.Duration) *subscriptionTracker {
+	return &subscriptionTracker{
+		provider:       provider,
+		silenceTimeout: silenceTimeout,
+		pairs:          map[string]types.CurrencyPair{},
+		statuses:       map[string]SubscriptionStatus{},
+		lastMessages:   map[string]time.Time{},
+	}
+}
+
+// SubscriptionStatuses returns the subscription status of every pair, keyed
+// by the currency pair symbol ex.: "ATOMUSDT".
+func (t *subscriptionTracker) SubscriptionStatuses() map[string]SubscriptionStatus {
+	t.subMtx.RLock()
+	defer t.subMtx.RUnlock()
+
+	statuses := make(map[string]SubscriptionStatus, len(t.statuses))
+	for symbol, status := range t.statuses {
+		statuses[symbol] = status
+	}
+	return statuses
+}
+
+// trackSubscriptions sets the pairs as pending, their subscription being sent.
+func (t *subscriptionTracker) trackSubscriptions(cps ...types.CurrencyPair) {
+	t.subMtx.Lock()
+	defer t.subMtx.Unlock()
+
+	now := time.Now()
+	for _, cp := range cps {
+		symbol := cp.String()
+		t.pairs[symbol] = cp
+		t.statuses[symbol] = SubscriptionStatus{
+			State:     SubscriptionPending,
+			Retries:   t.statuses[symbol].Retries,
+			UpdatedAt: now,
+		}
+	}
+	t.setSubscriptionGauges()
+}
+
+// setSubscriptionActive sets the pair of the symbol as active and records the
+// time of its last message, ex. when its subscription is acknowledged or its
+// data is received.
+func (t *subscriptionTracker) setSubscriptionActive(symbol string) {
+	t.subMtx.Lock()
+	defer t.subMtx.Unlock()
+
+	status, ok := t.statuses[symbol]
+	if !ok {
+		return
+	}
+
+	t.lastMessages[symbol] = time.Now()
+	if status.State == SubscriptionActive {
+		return
+	}
+
+	t.statuses[symbol] = SubscriptionStatus{
+		State:     SubscriptionActive,
+		UpdatedAt: time.Now(),
+	}
+	t.setSubscriptionGauges()
+}
+
+// setSubscriptionFailed sets the pair of the symbol as failed with the reason
+// given by the exchange.
+func (t *subscriptionTracker) setSubscriptionFailed(symbol, reason string) {
+	t.subMtx.Lock()
+	defer t.subMtx.Unlock()
+
+	t.setSubscriptionFailedLocked(symbol, reason)
+	t.setSubscriptionGauges()
+}
+
+func (t *subscriptionTracker) setSubscriptionFailedLocked(symbol, reason string) {
+	status, ok := t.statuses[symbol]
+	if !ok {
+		return
+	}
+
+	t.statuses[symbol] = SubscriptionStatus{
+		State:     SubscriptionFailed,
+		Reason:    reason,
+		Retries:   status.Retries,
+		UpdatedAt: time.Now(),
+	}
+
+	telemetry.IncrCounterWithLabels(
+		[]string{"websocket", "subscription", "failed"},
+		1,
+		[]metrics.Label{
+			telemetry.NewLabel("provider", t.provider),
+			telemetry.NewLabel("pair", symbol),
+		},
+	)
+}
+
+// subscriptionsToRetry fails the pending subscriptions which timed out and
+// returns the failed ones still allowed to retry, setting them as pending.
+func (t *subscriptionTracker) subscriptionsToRetry() []types.CurrencyPair {
+	t.subMtx.Lock()
+	defer t.subMtx.Unlock()
+
+	now := time.Now()
+	for symbol, status := range t.statuses {
+		if status.State == SubscriptionPending && now.Sub(status.UpdatedAt) > subscriptionAckTimeout {
+			t.setSubscriptionFailedLocked(symbol, "no acknowledgement or data received")
+		}
+	}
+
+	retries := []types.CurrencyPair{}
+	for symbol, status := range t.statuses {
+		if status.State != SubscriptionFailed || status.Retries >= maxSubscriptionRetries {
+			continue
+		}
+
+		retries = append(retries, t.pairs[symbol])
+		t.statuses[symbol] = SubscriptionStatus{
+			State:     SubscriptionPending,
+			Retries:   status.Retries + 1,
+			UpdatedAt: now,
+		}
+	}
+
+	t.setSubscriptionGauges()
+	return retries
+}
+
+// silentSubscriptions returns the active pairs without messages for the
+// silence timeout, setting them as pending to be resubscribed, and reports the
+// age of the last message of every pair.
+func (t *subscriptionTracker) silentSubscriptions() []types.CurrencyPair {
+	t.subMtx.Lock()
+	defer t.subMtx.Unlock()
+
+	now := time.Now()
+	silent := []types.CurrencyPair{}
+	for symbol, lastMessage := range t.lastMessages {
+		age := now.Sub(lastMessage)
+		telemetry.SetGaugeWithLabels(
+			[]string{"websocket", "message", "age"},
+			float32(age.Seconds()),
+			[]metrics.Label{
+				telemetry.NewLabel("provider", t.provider),
+				telemetry.NewLabel("pair", symbol),
+			},
+		)
+
+		status := t.statuses[symbol]
+		if status.State != SubscriptionActive || age <= t.silenceTimeout {
+			continue
+		}
+
+		silent = append(silent, t.pairs[symbol])
+		t.statuses[symbol] = SubscriptionStatus{
+			State:     SubscriptionPending,
+			UpdatedAt: now,
+		}
+		telemetry.IncrCounterWithLabels(
+			[]string{"websocket", "subscription", "silent"},
+			1,
+			[]metrics.Label{
+				telemetry.NewLabel("provider", t.provider),
+				telemetry.NewLabel("pair", symbol),
+			},
+		)
+	}
+
+	t.setSubscriptionGauges()
+	return silent
+}
+
+// retrySubscriptions periodically resubscribes to the failed and silent pairs
+// until the context is done.
+func (t *subscriptionTracker) retrySubscriptions(
+	ctx context.Context,
+	logger zerolog.Logger,
+	subscribe func(...types.CurrencyPair) error,
+) {
+	ticker := time.NewTicker(subscriptionRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if cps := t.silentSubscriptions(); len(cps) > 0 {
+				logger.Warn().Int("pairs", len(cps)).Msg("resubscribing silent pairs")
+				if err := subscribe(cps...); err != nil {
+					logger.Err(err).Msg("failed to resubscribe silent pairs")
+				}
+			}
+
+			cps := t.subscriptionsToRetry()
+			if len(cps) == 0 {
+				continue
+			}
+
+			logger.Info().Int("pairs", len(cps)).Msg("retrying failed subscriptions")
+			if err := subscribe(cps...); err != nil {
+				logger.Err(err).Msg("failed to retry subscriptions")
+			}
+		}
+	}
+}
+
+// setSubscriptionGauges reports the amount of pairs in each state.
+func (t *subscriptionTracker) setSubscriptionGauges() {
+	counts := make(map[SubscriptionState]int, len(subscriptionStates))
+	for _, status := range t.statuses {
+		counts[status.State]++
+	}
+
+	for _, state := range subscriptionStates {
+		telemetry.SetGaugeWithLabels(
+			[]string{"websocket", "subscription", "pairs"},
+			float32(counts[state]),
+			[]metrics.Label{
+				telemetry.NewLabel("provider", t.provider),
+				telemetry.NewLabel("state", string(state)),
+			},
+		)
+	}
+}
@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+func TestSubscriptionTracker(t *testing.T) {
+	atomUSDT := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
+	fooBAR := types.CurrencyPair{Base: "FOO", Quote: "BAR"}
+
+	tracker := newSubscriptionTracker(config.ProviderMock, defaultSilenceTimeout)
+	tracker.trackSubscriptions(atomUSDT, fooBAR)
+
+	statuses := tracker.SubscriptionStatuses()
+	require.Len(t, statuses, 2)
+	require.Equal(t, SubscriptionPending, statuses["ATOMUSDT"].State)
+	require.Equal(t, SubscriptionPending, statuses["FOOBAR"].State)
+
+	tracker.setSubscriptionActive("ATOMUSDT")
+	tracker.setSubscriptionFailed("FOOBAR", "Currency pair not supported")
+	// untracked symbols are ignored
+	tracker.setSubscriptionActive("BTCUSDT")
+	tracker.setSubscriptionFailed("BTCUSDT", "Currency pair not supported")
+
+	statuses = tracker.SubscriptionStatuses()
+	require.Len(t, statuses, 2)
+	require.Equal(t, SubscriptionActive, statuses["ATOMUSDT"].State)
+	require.Equal(t, SubscriptionFailed, statuses["FOOBAR"].State)
+	require.Equal(t, "Currency pair not supported", statuses["FOOBAR"].Reason)
+
+	// the returned map is a copy
+	statuses["ATOMUSDT"] = SubscriptionStatus{State: SubscriptionFailed}
+	require.Equal(t, SubscriptionActive, tracker.SubscriptionStatuses()["ATOMUSDT"].State)
+
+	require.Equal(t, []types.CurrencyPair{fooBAR}, tracker.subscriptionsToRetry())
+	status := tracker.SubscriptionStatuses()["FOOBAR"]
+	require.Equal(t, SubscriptionPending, status.State)
+	require.Equal(t, 1, status.Retries)
+	require.Empty(t, status.Reason)
+
+	// pending subscriptions are retried once they time out
+	require.Empty(t, tracker.subscriptionsToRetry())
+	tracker.statuses["FOOBAR"] = SubscriptionStatus{
+		State:     SubscriptionPending,
+		Retries:   1,
+		UpdatedAt: time.Now().Add(-subscriptionAckTimeout - time.Second),
+	}
+	require.Equal(t, []types.CurrencyPair{fooBAR}, tracker.subscriptionsToRetry())
+	require.Equal(t, 2, tracker.SubscriptionStatuses()["FOOBAR"].Retries)
+
+	// resubscribing keeps the retries until the pair is active
+	tracker.trackSubscriptions(fooBAR)
+	require.Equal(t, 2, tracker.SubscriptionStatuses()["FOOBAR"].Retries)
+	tracker.setSubscriptionActive("FOOBAR")
+	require.Zero(t, tracker.SubscriptionStatuses()["FOOBAR"].Retries)
+}
+
+func TestSubscriptionTracker_MaxRetries(t *testing.T) {
+	fooBAR := types.CurrencyPair{Base: "FOO", Quote: "BAR"}
+
+	tracker := newSubscriptionTracker(config.ProviderMock, defaultSilenceTimeout)
+	tracker.trackSubscriptions(fooBAR)
+
+	for i := 0; i < maxSubscriptionRetries; i++ {
+		tracker.setSubscriptionFailed("FOOBAR", "Currency pair not supported")
+		require.Equal(t, []types.CurrencyPair{fooBAR}, tracker.subscriptionsToRetry())
+	}
+
+	tracker.setSubscriptionFailed("FOOBAR", "Currency pair not supported")
+	require.Empty(t, tracker.subscriptionsToRetry())
+
+	status := tracker.SubscriptionStatuses()["FOOBAR"]
+	require.Equal(t, SubscriptionFailed, status.State)
+	require.Equal(t, maxSubscriptionRetries, status.Retries)
+}
+
+func TestSubscriptionTracker_Silence(t *testing.T) {
+	atomUSDT := types.CurrencyPair{Base: "ATOM", Quote: "USDT"}
+	btcUSDT := types.CurrencyPair{Base: "BTC", Quote: "USDT"}
+
+	tracker := newSubscriptionTracker(config.ProviderMock, time.Minute)
+	tracker.trackSubscriptions(atomUSDT, btcUSDT)
+	tracker.setSubscriptionActive("ATOMUSDT")
+	tracker.setSubscriptionActive("BTCUSDT")
+	require.Empty(t, tracker.silentSubscriptions())
+
+	tracker.lastMessages["ATOMUSDT"] = time.Now().Add(-2 * time.Minute)
+	require.Equal(t, []types.CurrencyPair{atomUSDT}, tracker.silentSubscriptions())
+
+	statuses := tracker.SubscriptionStatuses()
+	require.Equal(t, SubscriptionPending, statuses["ATOMUSDT"].State)
+	require.Equal(t, SubscriptionActive, statuses["BTCUSDT"].State)
+
+	// a pending pair is not resubscribed again until its acknowledgement times out
+	require.Empty(t, tracker.silentSubscriptions())
+
+	tracker.setSubscriptionActive("ATOMUSDT")
+	require.Equal(t, SubscriptionActive, tracker.SubscriptionStatuses()["ATOMUSDT"].State)
+	require.Empty(t, tracker.silentSubscriptions())
+}
@@ -15,6 +15,7 @@ import (
 const (
 	defaultReadNewWSMessage   = 50 * time.Millisecond
 	defaultMaxConnectionTime  = time.Hour * 23 // should be < 24h
+	defaultSilenceTimeout     = 5 * time.Minute
 	disabledPingDuration      = time.Duration(0)
 	startingReconnectDuration = 5 * time.Second
 	maxRetryMultiplier        = 25 // max retry duration: 52m5s
@@ -31,6 +32,7 @@ type (
 		websocketCancelFunc context.CancelFunc
 		providerName        string
 		websocketURL        url.URL
+		endpoints           *EndpointPool
 		subscriptionMsgs    []interface{}
 		messageHandler      MessageHandler
 		pingDuration        time.Duration
@@ -39,8 +41,8 @@ type (
 
 		mtx              sync.Mutex
 		client           *websocket.Conn
+		subscribed       bool
 		reconnectCounter uint
-		dialer           *websocket.Dialer
 	}
 )
 
@@ -50,6 +52,7 @@ func NewWebsocketController(
 	ctx context.Context,
 	providerName string,
 	websocketURL url.URL,
+	endpoints *EndpointPool,
 	subscriptionMsgs []interface{},
 	messageHandler MessageHandler,
 	pingDuration time.Duration,
@@ -60,12 +63,12 @@ func NewWebsocketController(
 		parentCtx:        ctx,
 		providerName:     providerName,
 		websocketURL:     websocketURL,
+		endpoints:        endpoints,
 		subscriptionMsgs: subscriptionMsgs,
 		messageHandler:   messageHandler,
 		pingDuration:     pingDuration,
 		pingMessageType:  pingMessageType,
 		logger:           logger,
-		dialer:           websocket.DefaultDialer,
 	}
 }
 
@@ -92,7 +95,7 @@ func (wsc *WebsocketController) Start() {
 		go wsc.readWebSocket()
 		go wsc.pingLoop()
 
-		if err := wsc.subscribe(wsc.subscriptionMsgs); err != nil {
+		if err := wsc.subscribe(wsc.startSubscription()); err != nil {
 			wsc.logger.Err(err).Send()
 			wsc.close()
 			continue
@@ -107,13 +110,11 @@ func (wsc *WebsocketController) connect() error {
 	defer wsc.mtx.Unlock()
 
 	wsc.logger.Debug().Msg("connecting to websocket")
-	conn, resp, err := wsc.dialer.Dial(wsc.websocketURL.String(), nil)
+	conn, err := wsc.endpoints.DialWebsocket(wsc.websocketURL)
 	if err != nil {
 		return fmt.Errorf("failed to dial WS for %s: %w", wsc.providerName, err)
 	}
 
-	defer resp.Body.Close()
-
 	wsc.client = conn
 	wsc.websocketCtx, wsc.websocketCancelFunc = context.WithCancel(wsc.parentCtx)
 	wsc.client.SetPingHandler(wsc.pingHandler)
@@ -140,15 +141,31 @@ func (wsc *WebsocketController) subscribe(msgs []interface{}) error {
 	return nil
 }
 
-// AddSubscriptionMsgs immediately sends the new subscription messages and
-// adds them to the subscriptionMsgs array if successful
+// AddSubscriptionMsgs adds the new subscription messages to the
+// subscriptionMsgs array, sent on every connection, and immediately sends them
+// if the connection already sent its subscriptions. Otherwise Start sends them
+// with the rest, so they are not sent twice.
 func (wsc *WebsocketController) AddSubscriptionMsgs(msgs []interface{}) error {
-	err := wsc.subscribe(msgs)
-	if err != nil {
-		return err
-	}
+	wsc.mtx.Lock()
 	wsc.subscriptionMsgs = append(wsc.subscriptionMsgs, msgs...)
-	return nil
+	subscribed := wsc.subscribed
+	wsc.mtx.Unlock()
+
+	if !subscribed {
+		return nil
+	}
+	return wsc.subscribe(msgs)
+}
+
+// startSubscription returns a copy of the subscriptionMsgs array and marks the
+// connection as subscribed, so the messages added afterwards are sent by
+// AddSubscriptionMsgs
+func (wsc *WebsocketController) startSubscription() []interface{} {
+	wsc.mtx.Lock()
+	defer wsc.mtx.Unlock()
+
+	wsc.subscribed = true
+	return append([]interface{}{}, wsc.subscriptionMsgs...)
 }
 
 // SendJSON sends a json message to the websocket connection using the Websocket
@@ -227,7 +244,7 @@ func (wsc *WebsocketController) readWebSocket() {
 			return
 
 		case <-time.After(defaultReadNewWSMessage):
-			messageType, bz, err := wsc.client.ReadMessage()
+			messageType, bz, err := wsc.endpoints.ReadMessage(wsc.client)
 			if err != nil {
 				wsc.logger.Err(fmt.Errorf("failed to read WS message for %s: %w", wsc.providerName, err)).Send()
 				wsc.reconnect()
@@ -270,11 +287,13 @@ func (wsc *WebsocketController) close() {
 	}
 
 	wsc.client = nil
+	wsc.subscribed = false
 }
 
 // reconnect closes the current websocket and starts a new connection process
 func (wsc *WebsocketController) reconnect() {
 	wsc.close()
+	wsc.endpoints.ReportDisconnect()
 	go wsc.Start()
 }
 
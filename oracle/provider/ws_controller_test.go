@@ -59,3 +59,16 @@ func TestWebsocketController_readSuccess(t *testing.T) {
 		})
 	}
 }
+
+func TestWebsocketController_AddSubscriptionMsgs(t *testing.T) {
+	c := &WebsocketController{
+		providerName:     config.ProviderMock,
+		subscriptionMsgs: []interface{}{"a"},
+		client:           new(websocket.Conn),
+	}
+
+	// connected but not subscribed yet, the messages are left to Start
+	require.NoError(t, c.AddSubscriptionMsgs([]interface{}{"b"}))
+	require.Equal(t, []interface{}{"a", "b"}, c.startSubscription())
+	require.True(t, c.subscribed)
+}
@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-metrics"
+	"github.com/rs/zerolog"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+type (
+	// SubscriptionMsgsFunc returns the messages subscribing to the channels of
+	// the currency pairs.
+	SubscriptionMsgsFunc func(...types.CurrencyPair) []interface{}
+
+	// WebsocketShards splits the subscriptions of a provider across multiple
+	// websocket connections, each managed by its own WebsocketController so a
+	// failing connection only affects its share of the pairs. Every connection
+	// relays its messages to the same handler, which merges them into the maps
+	// of the provider.
+	WebsocketShards struct {
+		parentCtx        context.Context
+		providerName     string
+		websocketURL     url.URL
+		endpoints        *EndpointPool
+		subscriptionMsgs SubscriptionMsgsFunc
+		messageHandler   MessageHandler
+		pingDuration     time.Duration
+		pingMessageType  uint
+		logger           zerolog.Logger
+
+		mtx        sync.Mutex
+		shards     []*WebsocketController // nil until the shard has pairs
+		shardPairs []int                  // amount of pairs per shard
+		pairShard  map[string]int         // Symbol => shard
+	}
+
+	// shardSubscription holds the messages to send on a shard, built under
+	// the lock and sent after releasing it.
+	shardSubscription struct {
+		controller *WebsocketController
+		msgs       []interface{}
+	}
+)
+
+// NewWebsocketShards returns WebsocketShards with the given amount of
+// connections, at least one. The connections are started once they are
+// assigned their first pairs.
+func NewWebsocketShards(
+	ctx context.Context,
+	providerName string,
+	websocketURL url.URL,
+	endpoints *EndpointPool,
+	connections uint,
+	subscriptionMsgs SubscriptionMsgsFunc,
+	messageHandler MessageHandler,
+	pingDuration time.Duration,
+	pingMessageType uint,
+	logger zerolog.Logger,
+) *WebsocketShards {
+	if connections == 0 {
+		connections = 1
+	}
+
+	return &WebsocketShards{
+		parentCtx:        ctx,
+		providerName:     providerName,
+		websocketURL:     websocketURL,
+		endpoints:        endpoints,
+		subscriptionMsgs: subscriptionMsgs,
+		messageHandler:   messageHandler,
+		pingDuration:     pingDuration,
+		pingMessageType:  pingMessageType,
+		logger:           logger,
+		shards:           make([]*WebsocketController, connections),
+		shardPairs:       make([]int, connections),
+		pairShard:        map[string]int{},
+	}
+}
+
+// SubscribeCurrencyPairs assigns the pairs not subscribed yet to the shards
+// with the fewest pairs and subscribes to their channels.
+func (ws *WebsocketShards) SubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
+	ws.mtx.Lock()
+
+	newPairs := make([][]types.CurrencyPair, len(ws.shards))
+	for _, cp := range cps {
+		if _, ok := ws.pairShard[cp.String()]; ok {
+			continue
+		}
+
+		shard := ws.leastLoadedShard()
+		ws.pairShard[cp.String()] = shard
+		ws.shardPairs[shard]++
+		newPairs[shard] = append(newPairs[shard], cp)
+	}
+
+	shardMsgs := make([]shardSubscription, 0, len(newPairs))
+	for shard, pairs := range newPairs {
+		if len(pairs) == 0 {
+			continue
+		}
+
+		ws.setPairsGauge(shard)
+		msgs := ws.subscriptionMsgs(pairs...)
+		if ws.shards[shard] == nil {
+			ws.startShard(shard, msgs)
+			continue
+		}
+		shardMsgs = append(shardMsgs, shardSubscription{ws.shards[shard], msgs})
+	}
+	ws.mtx.Unlock()
+
+	var err error
+	for _, sub := range shardMsgs {
+		if shardErr := sub.controller.AddSubscriptionMsgs(sub.msgs); shardErr != nil {
+			err = shardErr
+		}
+	}
+
+	return err
+}
+
+// ResubscribeCurrencyPairs sends the subscription messages of already
+// subscribed pairs again on their connections, ex. after the exchange rejected
+// them. Pairs that are not subscribed are skipped.
+func (ws *WebsocketShards) ResubscribeCurrencyPairs(cps ...types.CurrencyPair) error {
+	ws.mtx.Lock()
+	shardPairs := make([][]types.CurrencyPair, len(ws.shards))
+	for _, cp := range cps {
+		shard, ok := ws.pairShard[cp.String()]
+		if !ok {
+			ws.logger.Warn().Str("pair", cp.String()).Msg("skipping resubscription of a pair not subscribed")
+			continue
+		}
+		shardPairs[shard] = append(shardPairs[shard], cp)
+	}
+
+	shardMsgs := make([]shardSubscription, 0, len(shardPairs))
+	for shard, pairs := range shardPairs {
+		if len(pairs) == 0 {
+			continue
+		}
+		shardMsgs = append(shardMsgs, shardSubscription{ws.shards[shard], ws.subscriptionMsgs(pairs...)})
+	}
+	ws.mtx.Unlock()
+
+	var err error
+	for _, sub := range shardMsgs {
+		if shardErr := sub.controller.subscribe(sub.msgs); shardErr != nil {
+			err = shardErr
+		}
+	}
+
+	return err
+}
+
+// AddSubscriptionMsgs sends the messages on the connection of the pair, ex.
+// to subscribe to an additional channel of the pair.
+func (ws *WebsocketShards) AddSubscriptionMsgs(cp types.CurrencyPair, msgs []interface{}) error {
+	ws.mtx.Lock()
+	shard, ok := ws.pairShard[cp.String()]
+	if !ok {
+		ws.mtx.Unlock()
+		return fmt.Errorf("%s is not subscribed to %s", ws.providerName, cp)
+	}
+	controller := ws.shards[shard]
+	ws.mtx.Unlock()
+
+	return controller.AddSubscriptionMsgs(msgs)
+}
+
+// Shard returns the shard of the pair.
+func (ws *WebsocketShards) Shard(cp types.CurrencyPair) (int, bool) {
+	ws.mtx.Lock()
+	defer ws.mtx.Unlock()
+
+	shard, ok := ws.pairShard[cp.String()]
+	return shard, ok
+}
+
+// leastLoadedShard returns the first shard with the fewest pairs.
+func (ws *WebsocketShards) leastLoadedShard() int {
+	leastLoaded := 0
+	for shard, pairs := range ws.shardPairs {
+		if pairs < ws.shardPairs[leastLoaded] {
+			leastLoaded = shard
+		}
+	}
+	return leastLoaded
+}
+
+// startShard starts the connection of the shard, subscribing with the msgs.
+func (ws *WebsocketShards) startShard(shard int, msgs []interface{}) {
+	ws.shards[shard] = NewWebsocketController(
+		ws.parentCtx,
+		ws.providerName,
+		ws.websocketURL,
+		ws.endpoints,
+		msgs,
+		ws.messageHandler,
+		ws.pingDuration,
+		ws.pingMessageType,
+		ws.logger.With().Int("shard", shard).Logger(),
+	)
+
+	go ws.shards[shard].Start()
+}
+
+func (ws *WebsocketShards) setPairsGauge(shard int) {
+	telemetry.SetGaugeWithLabels(
+		[]string{"websocket", "shard", "pairs"},
+		float32(ws.shardPairs[shard]),
+		[]metrics.Label{
+			telemetry.NewLabel("provider", ws.providerName),
+			telemetry.NewLabel("shard", strconv.Itoa(shard)),
+		},
+	)
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+func TestWebsocketShards(t *testing.T) {
+	server := NewMockProviderServer()
+	server.Start()
+	defer server.Close()
+
+	pool, err := NewEndpointPool(config.ProviderEndpoint{
+		Name:      config.ProviderMock,
+		Websocket: server.GetBaseURL(),
+	})
+	require.NoError(t, err)
+
+	// the echo server sends the subscriptions back, merged by the handler
+	var (
+		mtx      sync.Mutex
+		received = map[string]struct{}{}
+	)
+	messageHandler := func(_ int, bz []byte) {
+		var symbols []string
+		require.NoError(t, json.Unmarshal(bz, &symbols))
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		for _, symbol := range symbols {
+			received[symbol] = struct{}{}
+		}
+	}
+	subscriptionMsgs := func(cps ...types.CurrencyPair) []interface{} {
+		symbols := make([]string, len(cps))
+		for i, cp := range cps {
+			symbols[i] = cp.String()
+		}
+		return []interface{}{symbols}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shards := NewWebsocketShards(
+		ctx,
+		config.ProviderMock,
+		url.URL{Scheme: "wss"},
+		pool,
+		3,
+		subscriptionMsgs,
+		messageHandler,
+		disabledPingDuration,
+		websocket.PingMessage,
+		zerolog.Nop(),
+	)
+
+	pairs := []types.CurrencyPair{
+		{Base: "ATOM", Quote: "USDT"},
+		{Base: "BTC", Quote: "USDT"},
+		{Base: "ETH", Quote: "USDT"},
+		{Base: "KII", Quote: "USDT"},
+		{Base: "TRX", Quote: "USDT"},
+	}
+	require.NoError(t, shards.SubscribeCurrencyPairs(pairs...))
+
+	for i, cp := range pairs {
+		shard, ok := shards.Shard(cp)
+		require.True(t, ok)
+		require.Equal(t, i%3, shard)
+	}
+
+	// already subscribed pairs keep their shard and new pairs fill the
+	// least loaded one
+	xaut := types.CurrencyPair{Base: "XAUT", Quote: "USDT"}
+	require.NoError(t, shards.SubscribeCurrencyPairs(pairs[0], xaut))
+	shard, _ := shards.Shard(pairs[0])
+	require.Equal(t, 0, shard)
+	shard, _ = shards.Shard(xaut)
+	require.Equal(t, 2, shard)
+	require.Equal(t, []int{2, 2, 2}, shards.shardPairs)
+
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(received) == len(pairs)+1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	err = shards.AddSubscriptionMsgs(types.CurrencyPair{Base: "FOO", Quote: "BAR"}, nil)
+	require.ErrorContains(t, err, "is not subscribed")
+
+	// unknown pairs are skipped without aborting the other resubscriptions
+	mtx.Lock()
+	received = map[string]struct{}{}
+	mtx.Unlock()
+	require.NoError(t, shards.ResubscribeCurrencyPairs(types.CurrencyPair{Base: "FOO", Quote: "BAR"}, pairs[1]))
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		_, ok := received[pairs[1].String()]
+		return ok
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestWebsocketShards_DefaultConnections(t *testing.T) {
+	shards := NewWebsocketShards(
+		context.Background(),
+		config.ProviderMock,
+		url.URL{},
+		nil,
+		0,
+		nil,
+		nil,
+		disabledPingDuration,
+		websocket.PingMessage,
+		zerolog.Nop(),
+	)
+	require.Len(t, shards.shards, 1)
+}
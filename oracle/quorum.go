@@ -0,0 +1,151 @@
+package oracle
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-metrics"
+	"github.com/rs/zerolog"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+)
+
+type (
+	// QuorumRule defines the MinSources providers which must contribute to
+	// the price of an asset after filtering, and the Action when fewer do.
+	QuorumRule struct {
+		MinSources int
+		Action     string
+	}
+
+	// Quorum defines the quorum Rule of the assets without their own rule,
+	// and the Rules by asset.
+	Quorum struct {
+		Rule  QuorumRule
+		Rules map[string]QuorumRule
+	}
+)
+
+// NewQuorum returns the quorum of the aggregation config.
+func NewQuorum(aggregationConfig config.Aggregation) Quorum {
+	quorum := Quorum{
+		Rule: QuorumRule{
+			MinSources: aggregationConfig.MinSources,
+			Action:     aggregationConfig.QuorumAction,
+		},
+		Rules: make(map[string]QuorumRule, len(aggregationConfig.Assets)),
+	}
+
+	for _, assetAggregation := range aggregationConfig.Assets {
+		rule := quorum.Rule
+		if assetAggregation.MinSources > 0 {
+			rule.MinSources = assetAggregation.MinSources
+		}
+		if len(assetAggregation.QuorumAction) > 0 {
+			rule.Action = assetAggregation.QuorumAction
+		}
+		quorum.Rules[assetAggregation.Base] = rule
+	}
+
+	return quorum
+}
+
+// rule returns the quorum rule of the base.
+func (q Quorum) rule(base string) QuorumRule {
+	if rule, ok := q.Rules[base]; ok {
+		return rule
+	}
+	return q.Rule
+}
+
+// enforce checks the providers contributing to the price of every base,
+// recording their number by base and alerting of the bases priced by fewer
+// providers than their min sources. It returns the providers of the bases
+// below their quorum, which must not be priced by the given samples.
+func (q Quorum) enforce(logger zerolog.Logger, priceType string, sources map[string][]string) map[string][]string {
+	belowQuorum := make(map[string][]string)
+
+	for base, providers := range sources {
+		telemetry.SetGaugeWithLabels([]string{"quorum", "sources"}, float32(len(providers)), []metrics.Label{
+			{Name: "type", Value: priceType},
+			{Name: "base", Value: base},
+		})
+
+		rule := q.rule(base)
+		if len(providers) >= rule.MinSources {
+			continue
+		}
+
+		telemetry.IncrCounterWithLabels([]string{"quorum", "miss"}, 1, []metrics.Label{
+			{Name: "type", Value: priceType},
+			{Name: "base", Value: base},
+			{Name: "action", Value: rule.Action},
+		})
+		logger.Warn().
+			Str("type", priceType).
+			Str("base", base).
+			Strs("sources", providers).
+			Int("min_sources", rule.MinSources).
+			Str("action", rule.Action).
+			Msg("fewer price sources than the quorum")
+
+		belowQuorum[base] = providers
+	}
+
+	return belowQuorum
+}
+
+// candleSources returns the sorted providers contributing candles to the
+// price of every base, counting the routes of a provider once.
+func candleSources(candles provider.AggregatedProviderCandles) map[string][]string {
+	routes := make(map[string][]string)
+	for route, assetCandles := range candles {
+		for base, baseCandles := range assetCandles {
+			if len(baseCandles) > 0 {
+				routes[base] = append(routes[base], route)
+			}
+		}
+	}
+	return routeSources(routes)
+}
+
+// tickerSources returns the sorted providers contributing tickers to the
+// price of every base, counting the routes of a provider once.
+func tickerSources(tickers provider.AggregatedProviderPrices) map[string][]string {
+	routes := make(map[string][]string)
+	for route, assetTickers := range tickers {
+		for base := range assetTickers {
+			routes[base] = append(routes[base], route)
+		}
+	}
+	return routeSources(routes)
+}
+
+// routeSources returns the sorted and distinct providers of the routes of
+// every base.
+func routeSources(routes map[string][]string) map[string][]string {
+	sources := make(map[string][]string, len(routes))
+	for base, baseRoutes := range routes {
+		providers := make(map[string]struct{}, len(baseRoutes))
+		for _, route := range baseRoutes {
+			providers[routeProvider(route)] = struct{}{}
+		}
+
+		for providerName := range providers {
+			sources[base] = append(sources[base], providerName)
+		}
+		sort.Strings(sources[base])
+	}
+	return sources
+}
+
+// formatSources returns the providers separated by commas, or none.
+func formatSources(providers []string) string {
+	if len(providers) == 0 {
+		return "none"
+	}
+	return strings.Join(providers, ", ")
+}
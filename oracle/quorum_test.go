@@ -0,0 +1,132 @@
+package oracle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+	"github.com/kiichain/price-feeder/oracle/types"
+)
+
+func TestNewQuorum(t *testing.T) {
+	quorum := NewQuorum(config.Aggregation{
+		MinSources:   2,
+		QuorumAction: config.QuorumActionAbstain,
+		Assets: []config.AssetAggregation{
+			{Base: "ATOM", MinSources: 3},
+			{Base: "KII", QuorumAction: config.QuorumActionFail},
+		},
+	})
+
+	require.Equal(t, QuorumRule{MinSources: 2, Action: config.QuorumActionAbstain}, quorum.rule("BTC"))
+	require.Equal(t, QuorumRule{MinSources: 3, Action: config.QuorumActionAbstain}, quorum.rule("ATOM"))
+	require.Equal(t, QuorumRule{MinSources: 2, Action: config.QuorumActionFail}, quorum.rule("KII"))
+}
+
+func TestQuorum_Enforce(t *testing.T) {
+	quorum := Quorum{Rule: QuorumRule{MinSources: 2, Action: config.QuorumActionAbstain}}
+	volume := math.LegacyNewDec(100)
+
+	// the routes of a provider are a single source
+	sources := tickerSources(provider.AggregatedProviderPrices{
+		config.ProviderBinance: {"ATOM": {Price: math.LegacyNewDec(10), Volume: volume}},
+		config.ProviderBinance + routeSeparator + "USDC": {
+			"ATOM": {Price: math.LegacyNewDec(10), Volume: volume},
+			"KII":  {Price: math.LegacyOneDec(), Volume: volume},
+		},
+		config.ProviderKraken: {"KII": {Price: math.LegacyOneDec(), Volume: volume}},
+	})
+	require.Equal(t, map[string][]string{
+		"ATOM": {config.ProviderBinance},
+		"KII":  {config.ProviderBinance, config.ProviderKraken},
+	}, sources)
+
+	belowQuorum := quorum.enforce(zerolog.Nop(), "ticker", sources)
+	require.Equal(t, map[string][]string{"ATOM": {config.ProviderBinance}}, belowQuorum)
+
+	// the providers without candles do not contribute
+	sources = candleSources(provider.AggregatedProviderCandles{
+		config.ProviderBinance: {"ATOM": {{Price: math.LegacyNewDec(10), Volume: volume}}},
+		config.ProviderKraken:  {"ATOM": {}},
+	})
+	require.Equal(t, map[string][]string{"ATOM": {config.ProviderBinance}}, sources)
+}
+
+func TestGetComputedPricesQuorum(t *testing.T) {
+	atomPair := types.CurrencyPair{Base: "ATOM", Quote: "USD"}
+	volume := math.LegacyNewDec(100)
+
+	providerPrices := provider.AggregatedProviderPrices{
+		config.ProviderBinance: {atomPair.String(): {Price: math.LegacyNewDec(10), Volume: volume}},
+		config.ProviderKraken:  {atomPair.String(): {Price: math.LegacyNewDec(12), Volume: volume}},
+	}
+	providerPairs := map[string][]types.CurrencyPair{
+		config.ProviderBinance: {atomPair},
+		config.ProviderKraken:  {atomPair},
+	}
+
+	testCases := map[string]struct {
+		candles             provider.AggregatedProviderCandles
+		rule                QuorumRule
+		expectedPrices      map[string]math.LegacyDec
+		expectedAbstentions Abstentions
+		expectedErr         string
+	}{
+		"quorum met": {
+			rule:                QuorumRule{MinSources: 2, Action: config.QuorumActionAbstain},
+			expectedPrices:      map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(11)},
+			expectedAbstentions: Abstentions{},
+		},
+		"abstain": {
+			rule:                QuorumRule{MinSources: 3, Action: config.QuorumActionAbstain},
+			expectedPrices:      map[string]math.LegacyDec{},
+			expectedAbstentions: Abstentions{"ATOM": "quorum"},
+		},
+		"fail": {
+			rule:        QuorumRule{MinSources: 3, Action: config.QuorumActionFail},
+			expectedErr: "ATOM priced by 2 sources below its quorum of 3: binance, kraken",
+		},
+		"tickers priced when candles miss the quorum": {
+			candles: provider.AggregatedProviderCandles{
+				config.ProviderBinance: {atomPair.String(): {{
+					Price:     math.LegacyNewDec(20),
+					Volume:    volume,
+					TimeStamp: provider.PastUnixTime(1 * time.Minute),
+				}}},
+			},
+			rule:                QuorumRule{MinSources: 2, Action: config.QuorumActionFail},
+			expectedPrices:      map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(11)},
+			expectedAbstentions: Abstentions{},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			prices, abstentions, err := GetComputedPrices(
+				zerolog.Nop(),
+				tc.candles,
+				providerPrices,
+				providerPairs,
+				make(map[string]math.LegacyDec),
+				Aggregation{Quorum: Quorum{Rule: tc.rule}},
+				map[string]struct{}{"ATOM": {}},
+			)
+			if len(tc.expectedErr) > 0 {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPrices, prices)
+			require.Equal(t, tc.expectedAbstentions, abstentions)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package oracle
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-metrics"
+	oracletypes "github.com/kiichain/kiichain/v3/x/oracle/types"
+	"github.com/rs/zerolog"
+
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+// RewardBandCheck estimates whether the voted rates fall outside the reward
+// band of the oracle module, using the on-chain exchange rates as the
+// estimate of the weighted median of the next ballot, and applies its Action
+// to the rates outside of it.
+type RewardBandCheck struct {
+	Action string
+}
+
+// NewRewardBandCheck returns the reward band check of the config, only
+// warning of the rates outside the band by default.
+func NewRewardBandCheck(rewardBand config.RewardBand) *RewardBandCheck {
+	action := rewardBand.Action
+	if len(action) == 0 {
+		action = config.RewardBandWarn
+	}
+	return &RewardBandCheck{Action: action}
+}
+
+// Apply returns the rates to vote, given the on-chain exchange rates and the
+// reward band by denom. The module rewards the rates within half the reward
+// band of the weighted median, so a rate outside of it is warned of, dropped
+// from the vote with the "abstain" action, or moved to the nearest edge of
+// the band with the "clamp" action. The rates without an on-chain rate are
+// voted unchecked.
+//
+// The band is only an estimate: the weighted median of the next ballot is
+// not known before the vote, and the on-chain rate is the median of the last
+// one. The "clamp" action is therefore a heuristic, which votes the estimated
+// edge rather than the computed price, and may move an honest vote away from
+// the actual median when the market moves between the ballots.
+func (c *RewardBandCheck) Apply(
+	logger zerolog.Logger,
+	rates sdk.DecCoins,
+	onChainRates map[string]math.LegacyDec,
+	rewardBand math.LegacyDec,
+) sdk.DecCoins {
+	checkedRates := sdk.NewDecCoins()
+
+	for _, rate := range rates {
+		onChainRate, ok := onChainRates[rate.Denom]
+		if !ok || !onChainRate.IsPositive() {
+			checkedRates = checkedRates.Add(rate)
+			continue
+		}
+
+		spread := onChainRate.Mul(rewardBand.QuoInt64(2))
+		lower, upper := onChainRate.Sub(spread), onChainRate.Add(spread)
+		if isBetween(rate.Amount, onChainRate, spread) {
+			checkedRates = checkedRates.Add(rate)
+			continue
+		}
+
+		telemetry.IncrCounterWithLabels([]string{"reward_band", "miss"}, 1, []metrics.Label{
+			{Name: "denom", Value: rate.Denom},
+			{Name: "action", Value: c.Action},
+		})
+		logger.Warn().
+			Str("denom", rate.Denom).
+			Str("rate", rate.Amount.String()).
+			Str("on_chain_rate", onChainRate.String()).
+			Str("lower", lower.String()).
+			Str("upper", upper.String()).
+			Str("action", c.Action).
+			Msg("rate estimated outside the reward band")
+
+		switch c.Action {
+		case config.RewardBandAbstain:
+			continue
+
+		case config.RewardBandClamp:
+			amount := upper
+			if rate.Amount.LT(lower) {
+				amount = lower
+			}
+			checkedRates = checkedRates.Add(sdk.NewDecCoinFromDec(rate.Denom, amount))
+
+		default:
+			checkedRates = checkedRates.Add(rate)
+		}
+	}
+
+	return checkedRates
+}
+
+// checkRewardBand applies the reward band check of the vote policy to the
+// rates to vote, against the on-chain exchange rates of the vote period. If
+// the on-chain rates can not be queried, the rates are voted unchecked.
+func (o *Oracle) checkRewardBand(
+	ctx context.Context,
+	blockHeight int64,
+	params oracletypes.Params,
+	rates sdk.DecCoins,
+) sdk.DecCoins {
+	rewardBandCheck := o.votePolicy.RewardBandCheck
+	if rewardBandCheck == nil {
+		return rates
+	}
+
+	exchangeRates, err := o.GetCachedExchangeRates(ctx, blockHeight, params.VotePeriod)
+	if err != nil {
+		o.logger.Warn().Err(err).Msg("failed to get the on-chain exchange rates of the reward band check")
+		return rates
+	}
+
+	return rewardBandCheck.Apply(o.logger, rates, exchangeRates, params.RewardBand)
+}
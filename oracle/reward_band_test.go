@@ -0,0 +1,91 @@
+package oracle
+
+import (
+	"context"
+	"testing"
+
+	oracletypes "github.com/kiichain/kiichain/v3/x/oracle/types"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/kiichain/price-feeder/config"
+)
+
+func TestRewardBandCheck_Apply(t *testing.T) {
+	// the reward band of 0.02 rewards the rates within 9.9 and 10.1
+	onChainRates := map[string]math.LegacyDec{"uatom": math.LegacyNewDec(10)}
+	rewardBand := math.LegacyMustNewDecFromStr("0.02")
+
+	testCases := map[string]struct {
+		action   string
+		rate     string
+		expected sdk.DecCoins
+	}{
+		"within the band": {
+			action:   config.RewardBandAbstain,
+			rate:     "10.1",
+			expected: sdk.NewDecCoins(sdk.NewDecCoinFromDec("uatom", math.LegacyMustNewDecFromStr("10.1"))),
+		},
+		"warn": {
+			action:   config.RewardBandWarn,
+			rate:     "11",
+			expected: sdk.NewDecCoins(sdk.NewDecCoinFromDec("uatom", math.LegacyNewDec(11))),
+		},
+		"abstain": {
+			action:   config.RewardBandAbstain,
+			rate:     "11",
+			expected: sdk.NewDecCoins(),
+		},
+		"clamp above the band": {
+			action:   config.RewardBandClamp,
+			rate:     "11",
+			expected: sdk.NewDecCoins(sdk.NewDecCoinFromDec("uatom", math.LegacyMustNewDecFromStr("10.1"))),
+		},
+		"clamp below the band": {
+			action:   config.RewardBandClamp,
+			rate:     "9",
+			expected: sdk.NewDecCoins(sdk.NewDecCoinFromDec("uatom", math.LegacyMustNewDecFromStr("9.9"))),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			rewardBandCheck := NewRewardBandCheck(config.RewardBand{Action: tc.action})
+
+			// the rates without an on-chain rate are voted unchecked
+			rates := rewardBandCheck.Apply(
+				zerolog.Nop(),
+				sdk.NewDecCoins(
+					sdk.NewDecCoinFromDec("uatom", math.LegacyMustNewDecFromStr(tc.rate)),
+					sdk.NewDecCoinFromDec("akii", math.LegacyNewDec(100)),
+				),
+				onChainRates,
+				rewardBand,
+			)
+			require.Equal(t, tc.expected.Add(sdk.NewDecCoinFromDec("akii", math.LegacyNewDec(100))), rates)
+		})
+	}
+}
+
+func TestOracle_CheckRewardBand(t *testing.T) {
+	oracle := &Oracle{logger: zerolog.Nop()}
+	rates := sdk.NewDecCoins(sdk.NewDecCoinFromDec("uatom", math.LegacyNewDec(11)))
+	params := oracletypes.Params{VotePeriod: 2, RewardBand: math.LegacyMustNewDecFromStr("0.02")}
+
+	// the rates are voted unchecked without a check
+	require.Equal(t, rates, oracle.checkRewardBand(context.Background(), 10, params, rates))
+
+	// the check only warns by default
+	require.Equal(t, &RewardBandCheck{Action: config.RewardBandWarn}, NewRewardBandCheck(config.RewardBand{}))
+
+	// the on-chain rates of the vote period are cached
+	oracle.votePolicy.RewardBandCheck = NewRewardBandCheck(config.RewardBand{Action: config.RewardBandAbstain})
+	oracle.exchangeRateCache.Update(5, map[string]math.LegacyDec{"uatom": math.LegacyNewDec(10)})
+	require.Equal(t, sdk.NewDecCoins(), oracle.checkRewardBand(context.Background(), 10, params, rates))
+}
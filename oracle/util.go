@@ -19,64 +19,54 @@ const (
 	tvwapCandlePeriod = 5 * time.Minute
 )
 
-// compute VWAP for each base by dividing the Σ {P * V} by Σ {V}
-func vwap(weightedPrices, volumeSum map[string]math.LegacyDec) (map[string]math.LegacyDec, error) {
-	vwap := make(map[string]math.LegacyDec)
-
-	for base, p := range weightedPrices {
-		if !volumeSum[base].Equal(math.LegacyZeroDec()) {
-			if _, ok := vwap[base]; !ok {
-				vwap[base] = math.LegacyZeroDec()
-			}
-
-			vwap[base] = p.Quo(volumeSum[base])
-		}
-	}
-
-	return vwap, nil
-}
-
 // ComputeVWAP computes the volume weighted average price for all price points
 // for each ticker/exchange pair. The provided prices argument reflects a mapping
-// of provider => {<base> => <TickerPrice>, ...}.
+// of provider => {<base> => <TickerPrice>, ...}. The volume of every provider is
+// multiplied by its trust weight and capped to the max share of the weights.
 //
 // Ref: https://en.wikipedia.org/wiki/Volume-weighted_average_price
-func ComputeVWAP(prices provider.AggregatedProviderPrices) (map[string]math.LegacyDec, error) {
-	var (
-		weightedPrices = make(map[string]math.LegacyDec)
-		volumeSum      = make(map[string]math.LegacyDec)
-	)
+func ComputeVWAP(
+	prices provider.AggregatedProviderPrices,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return tickerWeightedPrices(prices).vwap(weights), nil
+}
 
-	for _, providerPrices := range prices {
-		for base, tp := range providerPrices {
-			if _, ok := weightedPrices[base]; !ok {
-				weightedPrices[base] = math.LegacyZeroDec()
-			}
-			if _, ok := volumeSum[base]; !ok {
-				volumeSum[base] = math.LegacyZeroDec()
-			}
+// ComputeTVWAP computes the time volume weighted average price for all points
+// for each exchange pair. Filters out any candles that did not occur within
+// timePeriod. The provided prices argument reflects a mapping of
+// provider => {<base> => <TickerPrice>, ...}. The volume of every provider is
+// multiplied by its trust weight and capped to the max share of the weights.
+//
+// Ref : https://en.wikipedia.org/wiki/Time-weighted_average_price
+func ComputeTVWAP(
+	prices provider.AggregatedProviderCandles,
+	weights ProviderWeights,
+) (map[string]math.LegacyDec, error) {
+	return candleWeightedPrices(prices, true).vwap(weights), nil
+}
 
-			// weightedPrices[base] = Σ {P * V} for all TickerPrice
-			weightedPrices[base] = weightedPrices[base].Add(tp.Price.Mul(tp.Volume))
+// tickerWeightedPrices returns the Σ {P * V} and Σ {V} of the tickers of every
+// provider by base.
+func tickerWeightedPrices(prices provider.AggregatedProviderPrices) weightedPrices {
+	weightedPrices := make(weightedPrices)
 
-			// track total volume for each base
-			volumeSum[base] = volumeSum[base].Add(tp.Volume)
+	for providerName, providerPrices := range prices {
+		for base, tp := range providerPrices {
+			// weightedPrices[base][providerName] = Σ {P * V} and Σ {V}
+			weightedPrices.add(providerName, base, tp.Price, tp.Volume)
 		}
 	}
 
-	return vwap(weightedPrices, volumeSum)
+	return weightedPrices
 }
 
-// ComputeTVWAP computes the time volume weighted average price for all points
-// for each exchange pair. Filters out any candles that did not occur within
-// timePeriod. The provided prices argument reflects a mapping of
-// provider => {<base> => <TickerPrice>, ...}.
-//
-// Ref : https://en.wikipedia.org/wiki/Time-weighted_average_price
-func ComputeTVWAP(prices provider.AggregatedProviderCandles) (map[string]math.LegacyDec, error) {
+// candleWeightedPrices returns the Σ {P * V} and Σ {V} of the candles of every
+// provider by base within the tvwapCandlePeriod. If timeWeighted, the volume
+// of the candles is decreased proportionately by their age.
+func candleWeightedPrices(prices provider.AggregatedProviderCandles, timeWeighted bool) weightedPrices {
 	var (
-		weightedPrices = make(map[string]math.LegacyDec)
-		volumeSum      = make(map[string]math.LegacyDec)
+		weightedPrices = make(weightedPrices)
 		now            = provider.PastUnixTime(0)
 		timePeriod     = provider.PastUnixTime(tvwapCandlePeriod)
 	)
@@ -86,15 +76,11 @@ func ComputeTVWAP(prices provider.AggregatedProviderCandles) (map[string]math.Le
 		now = mockNow
 	}
 
-	for _, providerPrices := range prices {
+	for providerName, providerPrices := range prices {
 		for base := range providerPrices {
 			cp := providerPrices[base]
-
-			if _, ok := weightedPrices[base]; !ok {
-				weightedPrices[base] = math.LegacyZeroDec()
-			}
-			if _, ok := volumeSum[base]; !ok {
-				volumeSum[base] = math.LegacyZeroDec()
+			if len(cp) == 0 {
+				continue
 			}
 
 			// Sort by timestamp old -> new
@@ -116,21 +102,23 @@ func ComputeTVWAP(prices provider.AggregatedProviderCandles) (map[string]math.Le
 			for _, candle := range cp {
 				// we only want candles within the last timePeriod
 				if timePeriod < candle.TimeStamp {
-					// timeDiff = now - candle.TimeStamp
-					timeDiff := math.LegacyNewDec(now - candle.TimeStamp)
-					// volume = candle.Volume * (weightUnit * (period - timeDiff) + minimumTimeWeight)
-					volume := candle.Volume.Mul(
-						weightUnit.Mul(period.Sub(timeDiff).Add(minimumTimeWeight)),
-					)
-					volumeSum[base] = volumeSum[base].Add(volume)
-					weightedPrices[base] = weightedPrices[base].Add(candle.Price.Mul(volume))
+					volume := candle.Volume
+					if timeWeighted {
+						// timeDiff = now - candle.TimeStamp
+						timeDiff := math.LegacyNewDec(now - candle.TimeStamp)
+						// volume = candle.Volume * (weightUnit * (period - timeDiff) + minimumTimeWeight)
+						volume = candle.Volume.Mul(
+							weightUnit.Mul(period.Sub(timeDiff).Add(minimumTimeWeight)),
+						)
+					}
+					weightedPrices.add(providerName, base, candle.Price, volume)
 				}
 			}
 
 		}
 	}
 
-	return vwap(weightedPrices, volumeSum)
+	return weightedPrices
 }
 
 // StandardDeviation returns maps of the standard deviations and means of assets.
@@ -69,7 +69,7 @@ func TestComputeVWAP(t *testing.T) {
 		tc := tc
 
 		t.Run(name, func(t *testing.T) {
-			vwap, err := ComputeVWAP(tc.prices)
+			vwap, err := ComputeVWAP(tc.prices, ProviderWeights{})
 			require.NoError(t, err)
 			require.Len(t, vwap, len(tc.expected))
 
@@ -161,7 +161,7 @@ func TestComputeTVWAP(t *testing.T) {
 			} else {
 				mockNow = 0
 			}
-			tvwap, err := ComputeTVWAP(tc.prices)
+			tvwap, err := ComputeTVWAP(tc.prices, ProviderWeights{})
 			require.NoError(t, err)
 			require.Len(t, tvwap, len(tc.expected))
 
@@ -0,0 +1,104 @@
+package oracle
+
+import (
+	"sort"
+
+	"github.com/hashicorp/go-metrics"
+
+	"cosmossdk.io/math"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+)
+
+// defaultVolatilityMultiplier defines how many times its realized volatility
+// a provider can be away from the mean without being considered faulty, with
+// the adaptive deviation thresholds.
+var defaultVolatilityMultiplier = math.LegacyNewDec(3)
+
+// AdaptiveDeviation derives the acceptance band of the "stddev" filter from
+// the realized volatility of every base, so the band widens in fast markets
+// and narrows in calm ones. The band is bounded by the static deviation
+// threshold of the base and by the maximum deviation threshold, in 𝜎.
+type AdaptiveDeviation struct {
+	// Multiplier is how many times its realized volatility a price can be
+	// away from the mean
+	Multiplier math.LegacyDec
+}
+
+// NewAdaptiveDeviation returns the adaptive deviation of the config, nil if
+// the deviation thresholds are static.
+func NewAdaptiveDeviation(aggregationConfig config.Aggregation) (*AdaptiveDeviation, error) {
+	if aggregationConfig.DeviationMode != config.DeviationModeAdaptive {
+		return nil, nil
+	}
+
+	multiplier, err := decOrDefault(aggregationConfig.VolatilityMultiplier, defaultVolatilityMultiplier)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdaptiveDeviation{Multiplier: multiplier}, nil
+}
+
+// filter returns the VolatilityFilter of a base given its deviation threshold
+// and its realized volatility.
+func (d *AdaptiveDeviation) filter(threshold, volatility math.LegacyDec) VolatilityFilter {
+	return VolatilityFilter{
+		Volatility: volatility,
+		Multiplier: d.Multiplier,
+		Floor:      threshold,
+		Ceiling:    config.MaxDeviationThreshold,
+	}
+}
+
+// realizedVolatilities returns the realized volatility of every base, the
+// root mean square of the returns between the consecutive candles of every
+// route. The bases without any return are skipped.
+func realizedVolatilities(candles provider.AggregatedProviderCandles) (map[string]math.LegacyDec, error) {
+	var (
+		squaredReturns = make(map[string]math.LegacyDec)
+		returnCounts   = make(map[string]int64)
+	)
+
+	for _, routeCandles := range candles {
+		for base, baseCandles := range routeCandles {
+			sorted := make([]provider.CandlePrice, len(baseCandles))
+			copy(sorted, baseCandles)
+			sort.Slice(sorted, func(i, j int) bool {
+				return sorted[i].TimeStamp < sorted[j].TimeStamp
+			})
+
+			for i := 1; i < len(sorted); i++ {
+				previous := sorted[i-1].Price
+				if !previous.IsPositive() {
+					continue
+				}
+
+				priceReturn := sorted[i].Price.Sub(previous).Quo(previous)
+				if _, ok := squaredReturns[base]; !ok {
+					squaredReturns[base] = math.LegacyZeroDec()
+				}
+				squaredReturns[base] = squaredReturns[base].Add(priceReturn.Mul(priceReturn))
+				returnCounts[base]++
+			}
+		}
+	}
+
+	volatilities := make(map[string]math.LegacyDec, len(squaredReturns))
+	for base, sum := range squaredReturns {
+		volatility, err := sum.QuoInt64(returnCounts[base]).ApproxSqrt()
+		if err != nil {
+			return nil, err
+		}
+
+		telemetry.SetGaugeWithLabels([]string{"deviation", "volatility"}, float32(volatility.MustFloat64()), []metrics.Label{
+			{Name: "base", Value: base},
+		})
+		volatilities[base] = volatility
+	}
+
+	return volatilities, nil
+}
@@ -0,0 +1,130 @@
+package oracle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+)
+
+func TestRealizedVolatilities(t *testing.T) {
+	volatilities, err := realizedVolatilities(provider.AggregatedProviderCandles{
+		config.ProviderBinance: {
+			// the candles are sorted by their timestamp
+			"ATOM": {
+				{Price: math.LegacyMustNewDecFromStr("99.99"), TimeStamp: 3},
+				{Price: math.LegacyNewDec(100), TimeStamp: 1},
+				{Price: math.LegacyNewDec(101), TimeStamp: 2},
+			},
+			"KII": {{Price: math.LegacyOneDec(), TimeStamp: 1}},
+		},
+		config.ProviderKraken: {
+			"ATOM": {
+				{Price: math.LegacyNewDec(200), TimeStamp: 1},
+				{Price: math.LegacyNewDec(200), TimeStamp: 2},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// the returns are 0.01, -0.01 and 0
+	expected, err := math.LegacyMustNewDecFromStr("0.0002").QuoInt64(3).ApproxSqrt()
+	require.NoError(t, err)
+	require.Equal(t, map[string]math.LegacyDec{"ATOM": expected}, volatilities)
+}
+
+func TestVolatilityFilter(t *testing.T) {
+	// the mean is 100.275 and 𝜎 is 0.4206
+	prices := map[string]math.LegacyDec{
+		config.ProviderBinance: math.LegacyNewDec(100),
+		config.ProviderKraken:  math.LegacyNewDec(100),
+		config.ProviderHuobi:   math.LegacyMustNewDecFromStr("100.1"),
+		config.ProviderOkx:     math.LegacyNewDec(101),
+	}
+	calm := map[string]struct{}{
+		config.ProviderBinance: {},
+		config.ProviderKraken:  {},
+		config.ProviderHuobi:   {},
+	}
+
+	testCases := map[string]struct {
+		volatility string
+		ceiling    string
+		expected   map[string]struct{}
+	}{
+		"floor": {
+			volatility: "0.0001",
+			ceiling:    "3",
+			expected:   calm,
+		},
+		"realized volatility": {
+			volatility: "0.0025",
+			ceiling:    "3",
+			expected:   acceptAll(prices),
+		},
+		"ceiling": {
+			volatility: "0.01",
+			ceiling:    "1.5",
+			expected:   calm,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			filter := VolatilityFilter{
+				Volatility: math.LegacyMustNewDecFromStr(tc.volatility),
+				Multiplier: math.LegacyNewDec(3),
+				Floor:      math.LegacyOneDec(),
+				Ceiling:    math.LegacyMustNewDecFromStr(tc.ceiling),
+			}
+
+			accepted, err := filter.Filter(prices)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, accepted)
+		})
+	}
+}
+
+func TestAggregation_AdaptiveFilter(t *testing.T) {
+	adaptiveDeviation, err := NewAdaptiveDeviation(
+		config.Aggregation{DeviationMode: config.DeviationModeAdaptive, VolatilityMultiplier: "2"},
+	)
+	require.NoError(t, err)
+
+	aggregation := Aggregation{
+		AdaptiveDeviation: adaptiveDeviation,
+		Volatilities: map[string]math.LegacyDec{
+			"ATOM": math.LegacyMustNewDecFromStr("0.01"),
+			"KII":  math.LegacyMustNewDecFromStr("0.02"),
+		},
+	}
+	deviationThresholds := map[string]math.LegacyDec{
+		"ATOM": math.LegacyMustNewDecFromStr("1.5"),
+	}
+
+	require.Equal(t, VolatilityFilter{
+		Volatility: math.LegacyMustNewDecFromStr("0.01"),
+		Multiplier: math.LegacyNewDec(2),
+		Floor:      math.LegacyMustNewDecFromStr("1.5"),
+		Ceiling:    config.MaxDeviationThreshold,
+	}, aggregation.filter("ATOM", deviationThresholds))
+	require.Equal(t, VolatilityFilter{
+		Volatility: math.LegacyMustNewDecFromStr("0.02"),
+		Multiplier: math.LegacyNewDec(2),
+		Floor:      defaultDeviationThreshold,
+		Ceiling:    config.MaxDeviationThreshold,
+	}, aggregation.filter("KII", deviationThresholds))
+
+	// the bases without candle history use the static threshold
+	require.Equal(t, StdDevFilter{Threshold: defaultDeviationThreshold}, aggregation.filter("BTC", deviationThresholds))
+
+	adaptiveDeviation, err = NewAdaptiveDeviation(config.Aggregation{DeviationMode: config.DeviationModeStatic})
+	require.NoError(t, err)
+	require.Nil(t, adaptiveDeviation)
+}
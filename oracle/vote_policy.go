@@ -0,0 +1,40 @@
+package oracle
+
+import (
+	"github.com/kiichain/price-feeder/config"
+)
+
+// VotePolicy defines how the computed prices are guarded before being voted.
+type VotePolicy struct {
+	// CircuitBreaker guards the prices against sudden changes, disabled if nil
+	CircuitBreaker *CircuitBreaker
+	// RewardBandCheck checks the voted rates against the reward band of the
+	// oracle module, unchecked if nil
+	RewardBandCheck *RewardBandCheck
+	// PartialVote votes the available prices when some required prices are
+	// missing, abstaining from the missing ones
+	PartialVote bool
+	// Fallback reuses the last known good prices when the live prices can
+	// not be computed, disabled if nil
+	Fallback *PriceFallback
+}
+
+// NewVotePolicy returns the vote policy of the config.
+func NewVotePolicy(cfg config.Config) (VotePolicy, error) {
+	circuitBreaker, err := NewCircuitBreaker(cfg.CircuitBreaker)
+	if err != nil {
+		return VotePolicy{}, err
+	}
+
+	fallback, err := NewPriceFallback(cfg.Fallback)
+	if err != nil {
+		return VotePolicy{}, err
+	}
+
+	return VotePolicy{
+		CircuitBreaker:  circuitBreaker,
+		RewardBandCheck: NewRewardBandCheck(cfg.RewardBand),
+		PartialVote:     cfg.Main.PartialVote,
+		Fallback:        fallback,
+	}, nil
+}
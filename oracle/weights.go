@@ -0,0 +1,228 @@
+package oracle
+
+import (
+	"cosmossdk.io/math"
+)
+
+type (
+	// ProviderWeights defines the trust weights multiplying the volume reported
+	// by the providers, and the cap on the share of the total weight of a
+	// single provider, so a provider reporting inflated volumes can not
+	// dominate the aggregated price.
+	ProviderWeights struct {
+		// Providers are the trust weights by provider, the providers without
+		// a weight are trusted with a weight of one
+		Providers map[string]math.LegacyDec
+		// Assets are the trust weights by provider and base, overriding the
+		// weight of the provider for the base
+		Assets map[string]map[string]math.LegacyDec
+		// MaxShare is the maximum share of the total weight of a base that a
+		// single provider can have, a nil or zero share disables the cap
+		MaxShare math.LegacyDec
+	}
+
+	// weightedPrice holds the Σ {P * V} and Σ {V} of a provider for a base.
+	weightedPrice struct {
+		priceSum  math.LegacyDec
+		volumeSum math.LegacyDec
+	}
+
+	// ProviderPrice defines the average price of a provider for a base and
+	// its volume, after applying the trust weights.
+	ProviderPrice struct {
+		Provider string
+		Price    math.LegacyDec
+		Volume   math.LegacyDec
+	}
+
+	// weightedPrices holds the weighted prices by base and provider.
+	weightedPrices map[string]map[string]weightedPrice // base => provider => weightedPrice
+)
+
+// Weight returns the trust weight of the provider for the base.
+func (w ProviderWeights) Weight(providerName, base string) math.LegacyDec {
+	if weight, ok := w.Assets[providerName][base]; ok {
+		return weight
+	}
+	if weight, ok := w.Providers[providerName]; ok {
+		return weight
+	}
+	return math.LegacyOneDec()
+}
+
+// hasMaxShare returns true if the share of the providers is capped.
+func (w ProviderWeights) hasMaxShare() bool {
+	return !w.MaxShare.IsNil() && w.MaxShare.IsPositive() && w.MaxShare.LT(math.LegacyOneDec())
+}
+
+// add adds the price and volume to the weighted price of the provider for
+// the base.
+func (wp weightedPrices) add(providerName, base string, price, volume math.LegacyDec) {
+	if _, ok := wp[base]; !ok {
+		wp[base] = make(map[string]weightedPrice)
+	}
+
+	p, ok := wp[base][providerName]
+	if !ok {
+		p = weightedPrice{priceSum: math.LegacyZeroDec(), volumeSum: math.LegacyZeroDec()}
+	}
+
+	wp[base][providerName] = weightedPrice{
+		priceSum:  p.priceSum.Add(price.Mul(volume)),
+		volumeSum: p.volumeSum.Add(volume),
+	}
+}
+
+// trusted returns the weighted prices of every provider by base with their
+// volume multiplied by the trust weight of the provider, after capping the
+// share of every provider. The routes of a provider through several quotes
+// share the weight of the provider.
+func (wp weightedPrices) trusted(weights ProviderWeights) weightedPrices {
+	trustedPrices := make(weightedPrices, len(wp))
+
+	for base, providerPrices := range wp {
+		totalVolume := math.LegacyZeroDec()
+		for _, p := range providerPrices {
+			totalVolume = totalVolume.Add(p.volumeSum)
+		}
+
+		// the volumes of a base share the same sign, so the shares are
+		// computed over their magnitude
+		sign := math.LegacyOneDec()
+		if totalVolume.IsNegative() {
+			sign = sign.Neg()
+		}
+
+		trusted := make(map[string]weightedPrice, len(providerPrices))
+		for providerName, p := range providerPrices {
+			weight := weights.Weight(routeProvider(providerName), base).Mul(sign)
+			trusted[providerName] = weightedPrice{
+				priceSum:  p.priceSum.Mul(weight),
+				volumeSum: p.volumeSum.Mul(weight),
+			}
+		}
+
+		if weights.hasMaxShare() {
+			trusted = capProviderShares(trusted, weights.MaxShare)
+		}
+
+		trustedPrices[base] = trusted
+	}
+
+	return trustedPrices
+}
+
+// vwap computes the VWAP for each base by dividing the Σ {P * V * W} by
+// Σ {V * W}, where W is the trust weight of the provider, after capping the
+// share of every provider.
+func (wp weightedPrices) vwap(weights ProviderWeights) map[string]math.LegacyDec {
+	vwap := make(map[string]math.LegacyDec)
+
+	for base, providerPrices := range wp.trusted(weights) {
+		priceSum := math.LegacyZeroDec()
+		volumeSum := math.LegacyZeroDec()
+		for _, p := range providerPrices {
+			priceSum = priceSum.Add(p.priceSum)
+			volumeSum = volumeSum.Add(p.volumeSum)
+		}
+
+		if !volumeSum.IsZero() {
+			vwap[base] = priceSum.Quo(volumeSum)
+		}
+	}
+
+	return vwap
+}
+
+// providerPrices returns the average price of every provider by base, along
+// with its trusted volume. The providers without volume are skipped.
+func (wp weightedPrices) providerPrices(weights ProviderWeights) map[string][]ProviderPrice {
+	providerPrices := make(map[string][]ProviderPrice, len(wp))
+
+	for base, trusted := range wp.trusted(weights) {
+		for providerName, p := range trusted {
+			if !p.volumeSum.IsPositive() {
+				continue
+			}
+
+			providerPrices[base] = append(providerPrices[base], ProviderPrice{
+				Provider: providerName,
+				Price:    p.priceSum.Quo(p.volumeSum),
+				Volume:   p.volumeSum,
+			})
+		}
+	}
+
+	return providerPrices
+}
+
+// capProviderShares reduces the volume of the providers exceeding the max
+// share of the total volume to exactly the max share, keeping their average
+// price. Since capping a provider reduces the total volume, the providers are
+// capped until none exceeds the max share. If the max share can not be met,
+// ex. 0.25 with three providers, every provider gets the same volume.
+func capProviderShares(prices map[string]weightedPrice, maxShare math.LegacyDec) map[string]weightedPrice {
+	providers := 0
+	for _, p := range prices {
+		if p.volumeSum.IsPositive() {
+			providers++
+		}
+	}
+	if providers == 0 {
+		return prices
+	}
+
+	capped := make(map[string]struct{})
+	cappedVolume := math.LegacyZeroDec()
+	for {
+		// the capped providers have the max share of the total volume, so
+		// total = uncapped / (1 - capped * maxShare)
+		remainingShare := math.LegacyOneDec().Sub(maxShare.MulInt64(int64(len(capped))))
+		if len(capped) == providers || !remainingShare.IsPositive() {
+			// every provider is capped, so they get the same volume
+			cappedVolume = math.LegacyOneDec()
+			for providerName, p := range prices {
+				if p.volumeSum.IsPositive() {
+					capped[providerName] = struct{}{}
+				}
+			}
+			break
+		}
+
+		uncappedVolume := math.LegacyZeroDec()
+		for providerName, p := range prices {
+			if _, ok := capped[providerName]; !ok && p.volumeSum.IsPositive() {
+				uncappedVolume = uncappedVolume.Add(p.volumeSum)
+			}
+		}
+		totalVolume := uncappedVolume.Quo(remainingShare)
+		cappedVolume = totalVolume.Mul(maxShare)
+
+		exceeded := false
+		for providerName, p := range prices {
+			if _, ok := capped[providerName]; !ok && p.volumeSum.GT(cappedVolume) {
+				capped[providerName] = struct{}{}
+				exceeded = true
+			}
+		}
+		if !exceeded {
+			break
+		}
+	}
+
+	result := make(map[string]weightedPrice, len(prices))
+	for providerName, p := range prices {
+		if _, ok := capped[providerName]; !ok {
+			result[providerName] = p
+			continue
+		}
+
+		// keep the average price of the provider with the capped volume
+		result[providerName] = weightedPrice{
+			priceSum:  p.priceSum.Quo(p.volumeSum).Mul(cappedVolume),
+			volumeSum: cappedVolume,
+		}
+	}
+
+	return result
+}
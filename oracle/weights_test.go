@@ -0,0 +1,211 @@
+package oracle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+
+	"github.com/kiichain/price-feeder/config"
+	"github.com/kiichain/price-feeder/oracle/provider"
+)
+
+func TestProviderWeights_Weight(t *testing.T) {
+	weights := ProviderWeights{
+		Providers: map[string]math.LegacyDec{
+			config.ProviderMexc: math.LegacyMustNewDecFromStr("0.5"),
+		},
+		Assets: map[string]map[string]math.LegacyDec{
+			config.ProviderMexc: {"ATOM": math.LegacyMustNewDecFromStr("0.1")},
+		},
+	}
+
+	require.Equal(t, math.LegacyMustNewDecFromStr("0.1"), weights.Weight(config.ProviderMexc, "ATOM"))
+	require.Equal(t, math.LegacyMustNewDecFromStr("0.5"), weights.Weight(config.ProviderMexc, "KII"))
+	require.Equal(t, math.LegacyOneDec(), weights.Weight(config.ProviderBinance, "ATOM"))
+	require.Equal(t, math.LegacyOneDec(), ProviderWeights{}.Weight(config.ProviderBinance, "ATOM"))
+}
+
+func TestComputeVWAPWithWeights(t *testing.T) {
+	tickerPrice := func(price, volume string) provider.TickerPrice {
+		return provider.TickerPrice{
+			Price:  math.LegacyMustNewDecFromStr(price),
+			Volume: math.LegacyMustNewDecFromStr(volume),
+		}
+	}
+
+	testCases := map[string]struct {
+		prices   provider.AggregatedProviderPrices
+		weights  ProviderWeights
+		expected map[string]math.LegacyDec
+	}{
+		"provider weight": {
+			prices: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "100")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("20", "100")},
+			},
+			weights: ProviderWeights{
+				Providers: map[string]math.LegacyDec{config.ProviderKraken: math.LegacyNewDec(3)},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("17.5")},
+		},
+		"zero weight excludes the provider": {
+			prices: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "100")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("20", "1000000")},
+			},
+			weights: ProviderWeights{
+				Providers: map[string]math.LegacyDec{config.ProviderKraken: math.LegacyZeroDec()},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)},
+		},
+		"routes share the provider weight": {
+			prices: provider.AggregatedProviderPrices{
+				config.ProviderBinance:          {"ATOM": tickerPrice("10", "100")},
+				config.ProviderKraken + "/USDT": {"ATOM": tickerPrice("20", "100")},
+				config.ProviderKraken + "/USDC": {"ATOM": tickerPrice("20", "100")},
+			},
+			weights: ProviderWeights{
+				Providers: map[string]math.LegacyDec{config.ProviderKraken: math.LegacyZeroDec()},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)},
+		},
+		"asset weight overrides the provider weight": {
+			prices: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "100"), "KII": tickerPrice("10", "100")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("20", "100"), "KII": tickerPrice("20", "100")},
+			},
+			weights: ProviderWeights{
+				Providers: map[string]math.LegacyDec{config.ProviderKraken: math.LegacyNewDec(3)},
+				Assets: map[string]map[string]math.LegacyDec{
+					config.ProviderKraken: {"ATOM": math.LegacyZeroDec()},
+				},
+			},
+			expected: map[string]math.LegacyDec{
+				"ATOM": math.LegacyNewDec(10),
+				"KII":  math.LegacyMustNewDecFromStr("17.5"),
+			},
+		},
+		"max share caps the dominant provider": {
+			prices: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "800")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("20", "100")},
+				config.ProviderOkx:     {"ATOM": tickerPrice("30", "100")},
+			},
+			weights:  ProviderWeights{MaxShare: math.LegacyMustNewDecFromStr("0.5")},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("17.5")},
+		},
+		"max share caps the providers exceeding it after capping": {
+			prices: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "700")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("20", "250")},
+				config.ProviderOkx:     {"ATOM": tickerPrice("30", "50")},
+			},
+			weights:  ProviderWeights{MaxShare: math.LegacyMustNewDecFromStr("0.4")},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(18)},
+		},
+		"max share applies after the trust weights": {
+			prices: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "100")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("20", "100")},
+				config.ProviderOkx:     {"ATOM": tickerPrice("30", "100")},
+			},
+			weights: ProviderWeights{
+				Providers: map[string]math.LegacyDec{config.ProviderBinance: math.LegacyNewDec(8)},
+				MaxShare:  math.LegacyMustNewDecFromStr("0.5"),
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("17.5")},
+		},
+		"unreachable max share uses the same weights": {
+			prices: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "900")},
+				config.ProviderKraken:  {"ATOM": tickerPrice("20", "100")},
+			},
+			weights:  ProviderWeights{MaxShare: math.LegacyMustNewDecFromStr("0.25")},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(15)},
+		},
+		"max share with a single provider": {
+			prices: provider.AggregatedProviderPrices{
+				config.ProviderBinance: {"ATOM": tickerPrice("10", "900")},
+			},
+			weights:  ProviderWeights{MaxShare: math.LegacyMustNewDecFromStr("0.5")},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyNewDec(10)},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			vwap, err := ComputeVWAP(tc.prices, tc.weights)
+			require.NoError(t, err)
+			require.Len(t, vwap, len(tc.expected))
+
+			for k, v := range tc.expected {
+				require.Equalf(t, v, vwap[k], "unexpected VWAP for %s", k)
+			}
+		})
+	}
+}
+
+func TestComputeTVWAPWithWeights(t *testing.T) {
+	now := provider.PastUnixTime(0)
+	candlePrice := func(price, volume string) []provider.CandlePrice {
+		return []provider.CandlePrice{{
+			Price:     math.LegacyMustNewDecFromStr(price),
+			Volume:    math.LegacyMustNewDecFromStr(volume),
+			TimeStamp: now,
+		}}
+	}
+
+	testCases := map[string]struct {
+		candles  provider.AggregatedProviderCandles
+		weights  ProviderWeights
+		expected map[string]math.LegacyDec
+	}{
+		"no weights": {
+			candles: provider.AggregatedProviderCandles{
+				config.ProviderBinance: {"ATOM": candlePrice("10", "100")},
+				config.ProviderKraken:  {"ATOM": candlePrice("20", "300")},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("17.5")},
+		},
+		"provider weight": {
+			candles: provider.AggregatedProviderCandles{
+				config.ProviderBinance: {"ATOM": candlePrice("10", "100")},
+				config.ProviderKraken:  {"ATOM": candlePrice("20", "300")},
+			},
+			weights: ProviderWeights{
+				Providers: map[string]math.LegacyDec{config.ProviderKraken: math.LegacyMustNewDecFromStr("0.5")},
+			},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("16")},
+		},
+		"max share": {
+			candles: provider.AggregatedProviderCandles{
+				config.ProviderBinance: {"ATOM": candlePrice("10", "800")},
+				config.ProviderKraken:  {"ATOM": candlePrice("20", "100")},
+				config.ProviderOkx:     {"ATOM": candlePrice("30", "100")},
+			},
+			weights:  ProviderWeights{MaxShare: math.LegacyMustNewDecFromStr("0.5")},
+			expected: map[string]math.LegacyDec{"ATOM": math.LegacyMustNewDecFromStr("17.5")},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			mockNow = now
+			defer func() { mockNow = 0 }()
+
+			tvwap, err := ComputeTVWAP(tc.candles, tc.weights)
+			require.NoError(t, err)
+			require.Len(t, tvwap, len(tc.expected))
+
+			for k, v := range tc.expected {
+				require.Equalf(t, v, tvwap[k], "unexpected TVWAP for %s", k)
+			}
+		})
+	}
+}
@@ -17,10 +17,17 @@ type (
 	HealthZResponse struct {
 		Status string `json:"status" yaml:"status"`
 		Oracle struct {
-			LastSync string `json:"last_sync"`
+			LastSync  string                      `json:"last_sync"`
+			Endpoints map[string]EndpointResponse `json:"endpoints"`
 		} `json:"oracle"`
 	}
 
+	// EndpointResponse defines the endpoint currently used by a provider.
+	EndpointResponse struct {
+		Rest      string `json:"rest"`
+		Websocket string `json:"websocket"`
+	}
+
 	// PricesResponse defines the response type for getting the latest exchange
 	// rates from the oracle.
 	PricesResponse struct {
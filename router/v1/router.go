@@ -94,6 +94,15 @@ func (r *Router) healthzHandler() http.HandlerFunc {
 		// Get the last sync time from the oracle
 		resp.Oracle.LastSync = r.oracle.GetLastPriceSyncTimestamp().Format(time.RFC3339)
 
+		// Get the endpoint used by each provider
+		resp.Oracle.Endpoints = make(map[string]EndpointResponse)
+		for providerName, endpoint := range r.oracle.GetActiveEndpoints() {
+			resp.Oracle.Endpoints[providerName] = EndpointResponse{
+				Rest:      endpoint.Rest,
+				Websocket: endpoint.Websocket,
+			}
+		}
+
 		// Respond on the server
 		httputil.RespondWithJSON(w, http.StatusOK, resp)
 	}
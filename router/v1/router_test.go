@@ -39,6 +39,16 @@ func (m mockOracle) GetPrices() sdk.DecCoins {
 	return mockPrices
 }
 
+func (m mockOracle) GetActiveEndpoints() map[string]config.ProviderEndpoint {
+	return map[string]config.ProviderEndpoint{
+		config.ProviderBinance: {
+			Name:      config.ProviderBinance,
+			Rest:      "https://api2.binance.com",
+			Websocket: "stream.binance.com:443",
+		},
+	}
+}
+
 type mockMetrics struct{}
 
 func (mockMetrics) Gather(format string) (telemetry.GatherResponse, error) {
@@ -89,6 +99,13 @@ func (rts *RouterTestSuite) TestHealthz() {
 	var respBody map[string]interface{}
 	rts.Require().NoError(json.Unmarshal(response.Body.Bytes(), &respBody))
 	rts.Require().Equal(respBody["status"], v1.StatusAvailable)
+
+	var healthz v1.HealthZResponse
+	rts.Require().NoError(json.Unmarshal(response.Body.Bytes(), &healthz))
+	rts.Require().Equal(v1.EndpointResponse{
+		Rest:      "https://api2.binance.com",
+		Websocket: "stream.binance.com:443",
+	}, healthz.Oracle.Endpoints[config.ProviderBinance])
 }
 
 func (rts *RouterTestSuite) TestPrices() {